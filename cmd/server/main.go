@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"expvar"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,20 +11,29 @@ import (
 	"syscall"
 	"time"
 
+	"x402-facilitator-go/internal/compliance"
 	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/devchain"
 	"x402-facilitator-go/internal/handlers"
 	"x402-facilitator-go/internal/middleware"
 	"x402-facilitator-go/internal/service"
+	"x402-facilitator-go/internal/settlement"
+	"x402-facilitator-go/internal/storage"
 	"x402-facilitator-go/internal/verifier"
 	"x402-facilitator-go/internal/verifier/exact"
 	"x402-facilitator-go/internal/web3"
+	"x402-facilitator-go/pkg/attestation"
 
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 func main() {
+	devMode := flag.Bool("dev", false, "Serve the dev networks from an in-process chain with a test EIP-3009 token")
+	flag.Parse()
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -38,6 +49,12 @@ func main() {
 	}
 	defer logger.Sync()
 
+	// Dev mode serves only the dev networks from an in-process chain, otherwise only the RPC networks are served
+	cfg.SelectNetworks(*devMode)
+	if *devMode {
+		applyDevDefaults(cfg)
+	}
+
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
 		logger.Fatal("Invalid configuration", zap.Error(err))
@@ -49,7 +66,12 @@ func main() {
 	)
 
 	// Initialize Web3 client from network configuration
-	web3Client, err := web3.NewClient(cfg.Networks.NetworkInfos, logger)
+	var web3Client web3.Manager
+	if *devMode {
+		web3Client, err = newDevChain(cfg, logger)
+	} else {
+		web3Client, err = web3.NewClient(cfg.Networks.NetworkInfos, cfg.RPC, logger)
+	}
 	if err != nil {
 		logger.Fatal("Failed to initialize Web3 client", zap.Error(err))
 	}
@@ -59,6 +81,32 @@ func main() {
 		}
 	}()
 
+	// Initialize settlement ledger and deferred settlement queue
+	store, err := storage.NewStore(cfg.Storage)
+	if err != nil {
+		logger.Fatal("Failed to initialize storage", zap.Error(err))
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			logger.Error("Error closing storage", zap.Error(err))
+		}
+	}()
+
+	// Delete ledger records and finished deferred settlements past their retention
+	pruner := storage.NewPruner(time.Duration(cfg.Storage.PruneIntervalMinutes)*time.Minute, logger)
+	pruner.Retain("settlements", time.Duration(cfg.Storage.RetentionDays)*24*time.Hour, store.Prune)
+	pruner.Retain("deferred settlements", time.Duration(cfg.Storage.RetentionDays)*24*time.Hour, store.PruneDeferred)
+	pruner.Retain("screenings", time.Duration(cfg.Compliance.RetentionDays)*24*time.Hour, store.PruneScreenings)
+
+	// Cache immutable or slow-changing asset facts shared by the verifiers
+	assetCache := web3.NewAssetCache(web3Client, cfg.Cache)
+
+	// Check authorization validity windows against the host clock or the chain's block timestamps
+	clock := web3.NewClock(web3Client, cfg.Verification, cfg.Networks.NetworkInfos)
+
+	// Count verified and in-flight payments against their payers' balances
+	exposure := settlement.NewExposure(time.Duration(cfg.Verification.ExposureHoldSeconds) * time.Second)
+
 	// Initialize verifiers in explicit order
 	// Verifiers are executed sequentially and any failure stops the verification chain
 	verifiers := []verifier.Verifier{
@@ -66,30 +114,104 @@ func main() {
 		exact.NewGlobalVerifier(logger),
 
 		// Order 2: Payment Context Verifier - Validates protocol version, scheme, and network
-		exact.NewPaymentContextVerifier(logger, web3Client),
+		exact.NewPaymentContextVerifier(logger, web3Client, clock, time.Duration(cfg.Verification.SkewToleranceSeconds)*time.Second),
 
 		// Order 3: EIP-3009 Asset Verifier - Validates token contract supports EIP-3009
-		exact.NewEIP3009AssetVerifier(logger, web3Client),
+		exact.NewEIP3009AssetVerifier(logger, web3Client, assetCache),
 
 		// Order 4: Signature Verifier - Validates EIP-712 authorization signature
-		exact.NewSignatureVerifier(logger, web3Client),
+		exact.NewSignatureVerifier(logger, web3Client, assetCache),
 
-		// Order 5: User Balance Verifier - Validates user has sufficient balance
-		exact.NewUserBalanceVerifier(logger, web3Client),
+		// Order 5: Authorization State Verifier - Validates the authorization nonce is not used or canceled on-chain
+		exact.NewAuthorizationStateVerifier(logger, web3Client),
+	}
+
+	// Order 6: Compliance Verifier - Screens the payer and payTo with the compliance providers, when enabled
+	var screener *compliance.Screener
+	if cfg.Compliance.Enabled {
+		screener, err = compliance.NewScreener(cfg.Compliance, store, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize compliance screening", zap.Error(err))
+		}
+		verifiers = append(verifiers, exact.NewComplianceVerifier(logger, screener))
+	}
+
+	verifiers = append(verifiers,
+		// Order 7: Asset Restriction Verifier - Validates the asset is not paused and blacklists neither payer nor payTo
+		exact.NewAssetRestrictionVerifier(logger, web3Client),
+
+		// Order 8: User Balance Verifier - Validates user has sufficient balance
+		exact.NewUserBalanceVerifier(logger, web3Client, assetCache, exposure),
+	)
+
+	// Order 9: Reservation Verifier - Holds the authorization for the verifying payTo and resource, when enabled
+	var reservations *settlement.Reservations
+	if cfg.Verification.Reservations.Enabled {
+		reservations = settlement.NewReservations(time.Duration(cfg.Verification.Reservations.HoldSeconds) * time.Second)
+		verifiers = append(verifiers, exact.NewReservationVerifier(logger, reservations))
 	}
 
 	// Initialize services
-	verifyService := service.NewVerifyService(verifiers, logger)
-	settleService := service.NewSettleService(verifyService, web3Client, cfg.X402.FacilitatorPrivateKey, logger)
-	supportedService := service.NewSupportedService(cfg.Networks.NetworkInfos)
+	var prefetcher *web3.Prefetcher
+	if cfg.Verification.BatchReads {
+		prefetcher = web3.NewPrefetcher(web3Client, assetCache, cfg.Verification, logger)
+	}
+	verifyService := service.NewVerifyService(verifiers, prefetcher, store, cfg.Storage.RecordsVerifications(), logger)
+	confirmationWatcher := settlement.NewConfirmationWatcher(web3Client, cfg.Settlement, logger)
+	settleTracker := settlement.NewTracker(cfg.Settlement, confirmationWatcher, clock, logger)
+	reorgWatcher := settlement.NewReorgWatcher(web3Client, cfg.Settlement, logger)
+	settleIdempotency := settlement.NewIdempotency(time.Duration(cfg.Settlement.IdempotencyTTLSeconds) * time.Second)
+	var settleBatcher *settlement.Batcher
+	if cfg.Settlement.Batching.Enabled {
+		settleBatcher, err = settlement.NewBatcher(cfg.Settlement.Batching, web3Client, settleTracker, cfg.X402.FacilitatorPrivateKey, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize settlement batcher", zap.Error(err))
+		}
+	}
+	gasMonitor, err := settlement.NewGasMonitor(web3Client, cfg.GasMonitor, cfg.X402.FacilitatorPrivateKey, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize gas monitor", zap.Error(err))
+	}
+	var attester *attestation.Signer
+	if cfg.X402.AttestationPrivateKey != "" {
+		attester, err = attestation.NewSigner(cfg.X402.AttestationPrivateKey)
+		if err != nil {
+			logger.Fatal("Failed to initialize settlement attestation signer", zap.Error(err))
+		}
+		logger.Info("Settlement attestations enabled", zap.String("signer", attester.Address().Hex()))
+	}
+	settleService := service.NewSettleService(
+		verifyService,
+		web3Client,
+		settleTracker,
+		reorgWatcher,
+		settleIdempotency,
+		exposure,
+		reservations,
+		settleBatcher,
+		gasMonitor,
+		store,
+		cfg.Settlement,
+		store,
+		attester,
+		cfg.X402.FacilitatorPrivateKey,
+		logger,
+	)
+	supportedService := service.NewSupportedService(cfg.Networks.NetworkInfos, gasMonitor, attester)
+	ledgerService := service.NewLedgerService(store, store)
 
 	// Initialize handlers
-	verifyHandler := handlers.NewVerifyHandler(verifyService, logger)
-	settleHandler := handlers.NewSettleHandler(settleService, logger)
+	retryAfter := time.Duration(cfg.Server.RetryAfterSeconds) * time.Second
+	verifyHandler := handlers.NewVerifyHandler(verifyService, retryAfter, logger)
+	settleHandler := handlers.NewSettleHandler(settleService, retryAfter, logger)
 	supportedHandler := handlers.NewSupportedHandler(supportedService, logger)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerService, logger)
 
 	// Setup router
-	router := setupRouter(logger, verifyHandler, settleHandler, supportedHandler)
+	if cfg.X402.AdminToken == "" {
+		logger.Warn("X402_ADMIN_TOKEN is not set, the ledger and settlement status endpoints are disabled")
+	}
+	router := setupRouter(logger, cfg.X402.AdminToken, web3Client, gasMonitor, verifyHandler, settleHandler, supportedHandler, ledgerHandler)
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -97,6 +219,20 @@ func main() {
 		Handler: router,
 	}
 
+	// Start background workers, stopped on shutdown
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go web3Client.Run(workerCtx)
+	go confirmationWatcher.Run(workerCtx)
+	go reorgWatcher.Run(workerCtx)
+	go gasMonitor.Run(workerCtx)
+	go settleService.RunDeferred(workerCtx)
+	go settleService.RunResolver(workerCtx)
+	go pruner.Run(workerCtx)
+	if screener != nil {
+		go screener.Run(workerCtx)
+	}
+
 	// Start server in a goroutine
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -112,6 +248,7 @@ func main() {
 	<-quit
 
 	logger.Info("Shutting down server...")
+	stopWorkers()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -124,12 +261,60 @@ func main() {
 	logger.Info("Server exited")
 }
 
+// applyDevDefaults adjusts the configuration to the in-process chain of dev mode
+func applyDevDefaults(cfg *config.Config) {
+	if cfg.X402.FacilitatorPrivateKey == "" {
+		cfg.X402.FacilitatorPrivateKey = devchain.Facilitator().PrivateKey
+	}
+	// The chain starts from genesis on every run, so the ledger must not outlive it
+	cfg.Storage.Driver = storage.DriverMemory
+}
+
+// newDevChain starts the in-process chain of the dev networks and logs its test token and payer accounts
+func newDevChain(cfg *config.Config, logger *zap.Logger) (*web3.Simulated, error) {
+	key, err := crypto.HexToECDSA(cfg.X402.FacilitatorPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid facilitator private key: %w", err)
+	}
+	facilitator := crypto.PubkeyToAddress(key.PublicKey)
+
+	chain, err := devchain.NewChain(cfg.Networks.NetworkInfos, facilitator)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Warn("Dev mode: serving the dev networks from an in-process chain, never use its accounts on a real network",
+		zap.Strings("networks", chain.Networks()),
+		zap.Int("chainId", devchain.ChainID),
+		zap.String("facilitator", facilitator.Hex()),
+	)
+	logger.Info("Dev mode: test EIP-3009 token",
+		zap.String("asset", devchain.TokenAddress.Hex()),
+		zap.String("name", devchain.TokenName),
+		zap.String("version", devchain.TokenVersion),
+		zap.Int("decimals", devchain.TokenDecimals),
+	)
+	for _, payer := range devchain.Payers() {
+		logger.Info("Dev mode: funded payer account",
+			zap.String("address", payer.Address.Hex()),
+			zap.String("privateKey", payer.PrivateKey),
+			zap.String("tokenBalance", devchain.PayerTokenBalance.String()),
+		)
+	}
+	return chain, nil
+}
+
 // setupRouter configures the HTTP router
+// Ledger and settlement status endpoints expose payers, payTos and amounts, they require adminToken
 func setupRouter(
 	logger *zap.Logger,
+	adminToken string,
+	web3Client web3.Manager,
+	gasMonitor *settlement.GasMonitor,
 	verifyHandler *handlers.VerifyHandler,
 	settleHandler *handlers.SettleHandler,
 	supportedHandler *handlers.SupportedHandler,
+	ledgerHandler *handlers.LedgerHandler,
 ) *gin.Engine {
 	// Set Gin mode
 	gin.SetMode(gin.ReleaseMode)
@@ -141,19 +326,48 @@ func setupRouter(
 	router.Use(middleware.Recovery(logger))
 	router.Use(middleware.CORS())
 
-	// Health check endpoint
+	// Health check endpoint, degraded while any network is unavailable, its circuit breaker is open or its settlement signer is underfunded
 	router.GET("/health", func(c *gin.Context) {
+		status := "ok"
+		networks := web3Client.NetworkStatuses()
+		for _, network := range networks {
+			if !network.Available || network.CircuitOpen {
+				status = "degraded"
+			}
+		}
+		balances := gasMonitor.Balances()
+		for _, balance := range balances {
+			if !balance.Funded {
+				status = "degraded"
+			}
+		}
 		c.JSON(http.StatusOK, gin.H{
-			"status": "ok",
+			"status":       status,
+			"networks":     networks,
+			"gasBalances":  balances,
+			"rpcEndpoints": web3Client.EndpointStatuses(),
 		})
 	})
 
+	// Metrics endpoint, expvar JSON
+	router.GET("/metrics", gin.WrapH(expvar.Handler()))
+
 	// API routes
 	api := router.Group("")
 	{
 		api.POST("/verify", verifyHandler.Verify)
 		api.POST("/settle", settleHandler.Settle)
 		api.GET("/supported", supportedHandler.Supported)
+		// Settlement status is scoped by the unguessable settlement ID handed to the settling resource server
+		api.GET("/settle/status/:id", settleHandler.Status)
+	}
+
+	// Admin routes, authenticated with the admin bearer token
+	admin := router.Group("", middleware.AdminAuth(adminToken))
+	{
+		admin.GET("/settlements", ledgerHandler.Query)
+		admin.GET("/settlements/:id", ledgerHandler.Get)
+		admin.GET("/screenings", ledgerHandler.Screenings)
 	}
 
 	return router
@@ -0,0 +1,154 @@
+// Package cache provides a size-bounded read-through cache with per-entry TTLs and expvar metrics
+package cache
+
+import (
+	"container/list"
+	"context"
+	"expvar"
+	"sync"
+	"time"
+)
+
+var (
+	// hitsMetric counts lookups answered from the cache, by cache name
+	hitsMetric = expvar.NewMap("cache_hits")
+	// missesMetric counts lookups that loaded the value, by cache name
+	missesMetric = expvar.NewMap("cache_misses")
+	// sharedLoadsMetric counts lookups that waited on a load started by a concurrent lookup, by cache name
+	sharedLoadsMetric = expvar.NewMap("cache_shared_loads")
+	// evictionsMetric counts entries evicted to stay within the size bound, by cache name
+	evictionsMetric = expvar.NewMap("cache_evictions")
+	// entriesMetric is the number of cached entries, by cache name
+	entriesMetric = expvar.NewMap("cache_entries")
+)
+
+// LoadFunc loads a value missing from the cache and returns how long it may be cached
+// A zero TTL or an error leaves the value uncached
+type LoadFunc func(ctx context.Context) (value interface{}, ttl time.Duration, err error)
+
+// Cache is a read-through cache bounded to a number of entries, evicting the least recently used first
+// Concurrent lookups of the same missing key share one load, which runs detached from the lookups' contexts
+// so that a cancelled lookup does not fail the others
+type Cache struct {
+	name        string
+	maxEntries  int
+	loadTimeout time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	inflight map[string]*load
+	size     *expvar.Int
+}
+
+// entry is a cached value
+type entry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// load is an in-flight load shared by concurrent lookups of a key
+type load struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// New creates a cache reported under name in the metrics and holding at most maxEntries values
+// A load is cancelled after loadTimeout
+func New(name string, maxEntries int, loadTimeout time.Duration) *Cache {
+	size := new(expvar.Int)
+	entriesMetric.Set(name, size)
+	return &Cache{
+		name:        name,
+		maxEntries:  maxEntries,
+		loadTimeout: loadTimeout,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		inflight:    make(map[string]*load),
+		size:        size,
+	}
+}
+
+// Get returns the cached value of key, calling loadFn on a miss or after the value expired
+func (c *Cache) Get(ctx context.Context, key string, loadFn LoadFunc) (interface{}, error) {
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		cached := element.Value.(*entry)
+		if time.Now().Before(cached.expiresAt) {
+			c.lru.MoveToFront(element)
+			c.mu.Unlock()
+			hitsMetric.Add(c.name, 1)
+			return cached.value, nil
+		}
+		c.remove(element)
+	}
+
+	pending, ok := c.inflight[key]
+	if ok {
+		sharedLoadsMetric.Add(c.name, 1)
+	} else {
+		pending = &load{done: make(chan struct{})}
+		c.inflight[key] = pending
+		missesMetric.Add(c.name, 1)
+		go c.load(ctx, key, pending, loadFn)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-pending.done:
+		return pending.value, pending.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Invalidate drops the cached value of key, so that the next lookup loads it again
+func (c *Cache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// load runs loadFn for key and caches its value
+// It keeps the values of the first lookup's ctx, such as prefetched results, but not its cancellation
+func (c *Cache) load(ctx context.Context, key string, pending *load, loadFn LoadFunc) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.loadTimeout)
+	defer cancel()
+
+	value, ttl, err := loadFn(ctx)
+	pending.value, pending.err = value, err
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if err == nil && ttl > 0 {
+		c.add(key, value, ttl)
+	}
+	c.mu.Unlock()
+	close(pending.done)
+}
+
+// add caches a value, evicting the least recently used entries beyond the size bound
+func (c *Cache) add(key string, value interface{}, ttl time.Duration) {
+	c.entries[key] = c.lru.PushFront(&entry{
+		key:       key,
+		value:     value,
+		expiresAt: time.Now().Add(ttl),
+	})
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+		evictionsMetric.Add(c.name, 1)
+	}
+	c.size.Set(int64(c.lru.Len()))
+}
+
+// remove drops a cached entry
+func (c *Cache) remove(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(*entry).key)
+	c.size.Set(int64(c.lru.Len()))
+}
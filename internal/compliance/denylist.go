@@ -0,0 +1,161 @@
+package compliance
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+// denylistReason is the reason of a denylist entry without one, it does not reveal where the denylist is stored
+const denylistReason = "listed in denylist"
+
+// FileProvider lists the addresses of a local denylist file, reloading it when it changes
+// The file holds one address per line, or CSV rows of an address and an optional reason; lines starting with #
+// and rows whose first field is not an address, such as a header, are skipped
+type FileProvider struct {
+	name           string
+	path           string
+	reloadInterval time.Duration
+	logger         *zap.Logger
+
+	mu      sync.RWMutex
+	listed  map[common.Address]string
+	modTime time.Time
+	size    int64
+}
+
+// NewFileProvider creates a FileProvider, failing when the denylist cannot be read
+func NewFileProvider(name, path string, reloadInterval time.Duration, logger *zap.Logger) (*FileProvider, error) {
+	provider := &FileProvider{
+		name:           name,
+		path:           path,
+		reloadInterval: reloadInterval,
+		logger:         logger,
+	}
+	if _, err := provider.reload(); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// Name returns the provider's name
+func (f *FileProvider) Name() string {
+	return f.name
+}
+
+// Screen reports whether the denylist holds address
+func (f *FileProvider) Screen(ctx context.Context, address common.Address) (Match, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	reason, ok := f.listed[address]
+	if !ok {
+		return Match{}, nil
+	}
+	if reason == "" {
+		reason = denylistReason
+	}
+	return Match{Listed: true, Reason: reason}, nil
+}
+
+// Run checks the denylist for changes every reload interval until ctx is cancelled
+// A denylist that fails to load keeps the previous list in effect
+func (f *FileProvider) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := f.reload()
+			if err != nil {
+				f.logger.Error("Failed to reload denylist, keeping the previous list",
+					zap.Error(err),
+					zap.String("provider", f.name),
+					zap.String("path", f.path),
+				)
+			} else if reloaded {
+				f.mu.RLock()
+				count := len(f.listed)
+				f.mu.RUnlock()
+				f.logger.Info("Denylist reloaded",
+					zap.String("provider", f.name),
+					zap.String("path", f.path),
+					zap.Int("addresses", count),
+				)
+			}
+		}
+	}
+}
+
+// reload reads the denylist when its modification time or size changed since the last load
+func (f *FileProvider) reload() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat denylist %s: %w", f.path, err)
+	}
+	f.mu.RLock()
+	unchanged := f.listed != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size
+	f.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	listed, err := readDenylist(f.path)
+	if err != nil {
+		return false, err
+	}
+
+	f.mu.Lock()
+	f.listed = listed
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	f.mu.Unlock()
+	return true, nil
+}
+
+// readDenylist parses a denylist file into its addresses and their reasons
+func readDenylist(path string) (map[common.Address]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open denylist %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	listed := make(map[common.Address]string)
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return listed, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse denylist %s: %w", path, err)
+		}
+
+		address := strings.TrimSpace(row[0])
+		if !common.IsHexAddress(address) {
+			continue
+		}
+		reason := ""
+		if len(row) > 1 {
+			reason = strings.TrimSpace(row[1])
+		}
+		listed[common.HexToAddress(address)] = reason
+	}
+}
@@ -0,0 +1,112 @@
+package compliance
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+var (
+	listedAddress   = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	reasonedAddress = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	mixedAddress    = common.HexToAddress("0xAbCdEf0123456789aBcDeF0123456789AbCdEf01")
+	unlistedAddress = common.HexToAddress("0x3333333333333333333333333333333333333333")
+)
+
+// writeDenylist writes content to the denylist at path
+func writeDenylist(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write denylist: %v", err)
+	}
+}
+
+// newTestFileProvider creates a FileProvider over a temporary denylist holding content
+func newTestFileProvider(t *testing.T, content string) (*FileProvider, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "denylist.csv")
+	writeDenylist(t, path, content)
+	provider, err := NewFileProvider("denylist", path, time.Minute, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new file provider: %v", err)
+	}
+	return provider, path
+}
+
+func TestFileProviderMatchesDenylist(t *testing.T) {
+	provider, _ := newTestFileProvider(t, `address,reason
+# sanctioned addresses
+0x1111111111111111111111111111111111111111
+  0x2222222222222222222222222222222222222222, OFAC SDN
+0xabcdef0123456789abcdef0123456789abcdef01,
+not-an-address,ignored
+`)
+
+	for _, tc := range []struct {
+		name       string
+		address    common.Address
+		wantListed bool
+		wantReason string
+	}{
+		{"address line", listedAddress, true, denylistReason},
+		{"CSV row with a reason", reasonedAddress, true, "OFAC SDN"},
+		{"regardless of case", mixedAddress, true, denylistReason},
+		{"unlisted", unlistedAddress, false, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			match, err := provider.Screen(context.Background(), tc.address)
+			if err != nil {
+				t.Fatalf("screen: %v", err)
+			}
+			if match.Listed != tc.wantListed || match.Reason != tc.wantReason {
+				t.Fatalf("got %+v, want listed %v with reason %q", match, tc.wantListed, tc.wantReason)
+			}
+		})
+	}
+	if count := len(provider.listed); count != 3 {
+		t.Fatalf("%d addresses listed, want the header, comment and invalid rows skipped", count)
+	}
+}
+
+func TestFileProviderReloadsChangedDenylist(t *testing.T) {
+	provider, path := newTestFileProvider(t, "0x1111111111111111111111111111111111111111\n")
+
+	if reloaded, err := provider.reload(); err != nil || reloaded {
+		t.Fatalf("reload of an unchanged denylist: reloaded %v, err %v", reloaded, err)
+	}
+
+	writeDenylist(t, path, "0x3333333333333333333333333333333333333333,added\n0x2222222222222222222222222222222222222222\n")
+	if reloaded, err := provider.reload(); err != nil || !reloaded {
+		t.Fatalf("reload of a changed denylist: reloaded %v, err %v", reloaded, err)
+	}
+	if match, _ := provider.Screen(context.Background(), unlistedAddress); !match.Listed || match.Reason != "added" {
+		t.Fatalf("want the added address listed, got %+v", match)
+	}
+	if match, _ := provider.Screen(context.Background(), listedAddress); match.Listed {
+		t.Fatal("want the removed address no longer listed")
+	}
+
+	// A denylist that disappears keeps the previous list in effect
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("remove denylist: %v", err)
+	}
+	if _, err := provider.reload(); err == nil {
+		t.Fatal("want an error reloading a missing denylist")
+	}
+	if match, _ := provider.Screen(context.Background(), unlistedAddress); !match.Listed {
+		t.Fatal("want the previous list kept after a failed reload")
+	}
+}
+
+func TestNewFileProviderFailsOnMissingDenylist(t *testing.T) {
+	if _, err := NewFileProvider("denylist", filepath.Join(t.TempDir(), "missing.csv"), time.Minute, zap.NewNop()); err == nil {
+		t.Fatal("want an error for a missing denylist")
+	}
+}
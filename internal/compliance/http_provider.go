@@ -0,0 +1,81 @@
+package compliance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ScreeningRequest is the body an HTTP provider is sent for each screened address
+type ScreeningRequest struct {
+	Address string `json:"address"`
+}
+
+// ScreeningResponse is the body an HTTP provider answers with
+type ScreeningResponse struct {
+	Listed bool   `json:"listed"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// HTTPProvider screens addresses with a screening API
+// It POSTs a ScreeningRequest to the endpoint, which answers 200 with a ScreeningResponse, any other answer means
+// the provider is unavailable
+type HTTPProvider struct {
+	name   string
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewHTTPProvider creates an HTTPProvider, apiKey is sent as a bearer token when set
+func NewHTTPProvider(name, url, apiKey string, timeout time.Duration) *HTTPProvider {
+	return &HTTPProvider{
+		name:   name,
+		url:    url,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Name returns the provider's name
+func (h *HTTPProvider) Name() string {
+	return h.name
+}
+
+// Screen asks the screening API whether it lists address
+func (h *HTTPProvider) Screen(ctx context.Context, address common.Address) (Match, error) {
+	body, err := json.Marshal(ScreeningRequest{Address: address.Hex()})
+	if err != nil {
+		return Match{}, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return Match{}, fmt.Errorf("failed to build screening request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if h.apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+h.apiKey)
+	}
+
+	response, err := h.client.Do(request)
+	if err != nil {
+		return Match{}, fmt.Errorf("screening request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+		return Match{}, fmt.Errorf("screening request failed with status %d", response.StatusCode)
+	}
+	var screening ScreeningResponse
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&screening); err != nil {
+		return Match{}, fmt.Errorf("failed to decode screening response: %w", err)
+	}
+	return Match{Listed: screening.Listed, Reason: screening.Reason}, nil
+}
@@ -0,0 +1,257 @@
+package compliance
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"x402-facilitator-go/internal/cache"
+	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/storage"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+// Roles of a screened address in a payment
+const (
+	RolePayer = "payer"
+	RolePayTo = "payTo"
+)
+
+// Match is a provider's answer about an address
+type Match struct {
+	// Listed reports that the provider refuses payments involving the address
+	Listed bool
+	// Reason explains the listing, such as the sanctions list the address is on
+	Reason string
+}
+
+// Provider answers whether an address is listed
+type Provider interface {
+	// Name identifies the provider in logs and screening records
+	Name() string
+	// Screen reports whether the provider lists address, an error means it could not answer
+	Screen(ctx context.Context, address common.Address) (Match, error)
+}
+
+// decisionCacheEntries bounds the number of addresses whose decision is cached
+const decisionCacheEntries = 10000
+
+// reloader is implemented by providers keeping their list up to date in the background
+type reloader interface {
+	Run(ctx context.Context)
+}
+
+// Screener screens the payer and payTo of payments with its providers and records their decisions for audit
+// Allowed and blocked decisions about an address are reused for the cache TTL, an allowed decision served from
+// the cache is not recorded again
+type Screener struct {
+	providers []Provider
+	log       storage.ScreeningLog
+	failOpen  bool
+	decisions *cache.Cache
+	cacheTTL  time.Duration
+	logger    *zap.Logger
+}
+
+// decision is the outcome of screening an address with the providers
+type decision struct {
+	decision storage.ScreeningDecision
+	provider string
+	reason   string
+	failOpen bool
+}
+
+// NewScreener creates a Screener consulting the configured providers in order
+func NewScreener(cfg config.ComplianceConfig, log storage.ScreeningLog, logger *zap.Logger) (*Screener, error) {
+	providers := make([]Provider, 0, len(cfg.Providers))
+	for _, providerCfg := range cfg.Providers {
+		name := providerCfg.Name
+		if name == "" {
+			name = providerCfg.Type
+		}
+
+		switch providerCfg.Type {
+		case config.ScreeningProviderFile:
+			provider, err := NewFileProvider(name, providerCfg.Path, time.Duration(cfg.ReloadIntervalSeconds)*time.Second, logger)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		case config.ScreeningProviderHTTP:
+			apiKey := ""
+			if providerCfg.APIKeyEnv != "" {
+				apiKey = os.Getenv(providerCfg.APIKeyEnv)
+			}
+			providers = append(providers, NewHTTPProvider(name, providerCfg.URL, apiKey, time.Duration(cfg.TimeoutMillis)*time.Millisecond))
+		case config.ScreeningProviderStub:
+			providers = append(providers, NewStubProvider(name, providerCfg.Addresses))
+		default:
+			return nil, fmt.Errorf("unsupported compliance provider type: %s", providerCfg.Type)
+		}
+	}
+
+	// A load consults every provider in turn, each bounded by the HTTP timeout
+	loadTimeout := time.Duration(cfg.TimeoutMillis) * time.Millisecond * time.Duration(len(providers)+1)
+	return &Screener{
+		providers: providers,
+		log:       log,
+		failOpen:  cfg.FailOpen,
+		decisions: cache.New("screening_decisions", decisionCacheEntries, loadTimeout),
+		cacheTTL:  time.Duration(cfg.CacheSeconds) * time.Second,
+		logger:    logger,
+	}, nil
+}
+
+// Run keeps the lists of the providers up to date until ctx is cancelled
+func (s *Screener) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, provider := range s.providers {
+		if r, ok := provider.(reloader); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r.Run(ctx)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// Screen screens the payer and the payTo of a payment concurrently, recording the decision about each
+// It returns the record of the decision refusing the payment, the payer's first, or nil when the payment may proceed
+func (s *Screener) Screen(ctx context.Context, requirements models.PaymentRequirements, authorization models.Authorization) *storage.ScreeningRecord {
+	subjects := []struct {
+		role    string
+		address string
+	}{
+		{RolePayer, authorization.From},
+		{RolePayTo, requirements.PayTo},
+	}
+
+	decisions := make([]decision, len(subjects))
+	cached := make([]bool, len(subjects))
+	var wg sync.WaitGroup
+	for i, subject := range subjects {
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+			decisions[i], cached[i] = s.decide(ctx, address)
+		}(i, subject.address)
+	}
+	wg.Wait()
+
+	for i, subject := range subjects {
+		record := storage.NewScreeningRecord()
+		record.Address = subject.address
+		record.Decision = decisions[i].decision
+		record.Provider = decisions[i].provider
+		record.Reason = decisions[i].reason
+		record.FailOpen = decisions[i].failOpen
+		record.Role = subject.role
+		record.Network = requirements.Network
+		record.Payer = authorization.From
+		record.PayTo = requirements.PayTo
+		record.Asset = requirements.Asset
+		record.Amount = authorization.Value
+		record.Nonce = authorization.Nonce
+		record.Resource = requirements.Resource
+		if !cached[i] || record.Decision != storage.ScreeningAllowed {
+			s.record(ctx, record)
+		}
+
+		if record.Decision == storage.ScreeningBlocked || (record.Decision == storage.ScreeningUnavailable && !record.FailOpen) {
+			return record
+		}
+	}
+	return nil
+}
+
+// decide returns the decision about address, from the cache when it is there, and whether it was cached
+// Unavailable decisions are not cached, so that the next payment asks the providers again
+func (s *Screener) decide(ctx context.Context, address string) (decision, bool) {
+	if s.cacheTTL <= 0 {
+		return s.screen(ctx, address), false
+	}
+
+	loaded := false
+	value, err := s.decisions.Get(ctx, common.HexToAddress(address).Hex(), func(ctx context.Context) (interface{}, time.Duration, error) {
+		loaded = true
+		result := s.screen(ctx, address)
+		if result.decision == storage.ScreeningUnavailable {
+			return result, 0, nil
+		}
+		return result, s.cacheTTL, nil
+	})
+	if err != nil {
+		return decision{
+			decision: storage.ScreeningUnavailable,
+			reason:   err.Error(),
+			failOpen: s.failOpen,
+		}, false
+	}
+	return value.(decision), !loaded
+}
+
+// screen consults the providers about address until one lists it
+// A provider that cannot answer does not stop the others, so that a listing elsewhere still blocks the payment
+func (s *Screener) screen(ctx context.Context, address string) decision {
+	result := decision{decision: storage.ScreeningAllowed}
+
+	for _, provider := range s.providers {
+		match, err := provider.Screen(ctx, common.HexToAddress(address))
+		if err != nil {
+			if result.decision != storage.ScreeningUnavailable {
+				result = decision{
+					decision: storage.ScreeningUnavailable,
+					provider: provider.Name(),
+					reason:   err.Error(),
+					failOpen: s.failOpen,
+				}
+			}
+			continue
+		}
+		if match.Listed {
+			return decision{
+				decision: storage.ScreeningBlocked,
+				provider: provider.Name(),
+				reason:   match.Reason,
+			}
+		}
+	}
+	return result
+}
+
+// record logs a screening decision and saves it to the screening log
+// Failing to save is logged and does not change the decision
+func (s *Screener) record(ctx context.Context, record *storage.ScreeningRecord) {
+	fields := []zap.Field{
+		zap.String("decision", string(record.Decision)),
+		zap.String("role", record.Role),
+		zap.String("address", record.Address),
+		zap.String("provider", record.Provider),
+		zap.String("reason", record.Reason),
+		zap.String("network", record.Network),
+		zap.String("payer", record.Payer),
+	}
+	switch record.Decision {
+	case storage.ScreeningBlocked:
+		s.logger.Warn("Compliance screening blocked payment", fields...)
+	case storage.ScreeningUnavailable:
+		s.logger.Error("Compliance screening provider unavailable", append(fields, zap.Bool("failOpen", record.FailOpen))...)
+	default:
+		s.logger.Debug("Compliance screening allowed address", fields...)
+	}
+
+	if err := s.log.SaveScreening(ctx, record); err != nil {
+		s.logger.Error("Failed to save screening record",
+			zap.Error(err),
+			zap.String("screeningId", record.ID),
+			zap.String("decision", string(record.Decision)),
+			zap.String("address", record.Address),
+		)
+	}
+}
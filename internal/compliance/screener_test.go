@@ -0,0 +1,218 @@
+package compliance
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/storage"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+const (
+	testPayer = "0x1111111111111111111111111111111111111111"
+	testPayTo = "0x2222222222222222222222222222222222222222"
+)
+
+// countingProvider lists a fixed set of addresses, or fails with err, counting the screenings per address
+type countingProvider struct {
+	name   string
+	listed map[common.Address]bool
+	err    error
+
+	mu    sync.Mutex
+	calls map[common.Address]int
+}
+
+// newCountingProvider creates a countingProvider listing addresses
+func newCountingProvider(name string, addresses ...string) *countingProvider {
+	listed := make(map[common.Address]bool, len(addresses))
+	for _, address := range addresses {
+		listed[common.HexToAddress(address)] = true
+	}
+	return &countingProvider{name: name, listed: listed, calls: make(map[common.Address]int)}
+}
+
+// Name returns the provider's name
+func (p *countingProvider) Name() string {
+	return p.name
+}
+
+// Screen counts the screening and reports whether address is listed
+func (p *countingProvider) Screen(ctx context.Context, address common.Address) (Match, error) {
+	p.mu.Lock()
+	p.calls[address]++
+	p.mu.Unlock()
+
+	if p.err != nil {
+		return Match{}, p.err
+	}
+	return Match{Listed: p.listed[address], Reason: "listed by " + p.name}, nil
+}
+
+// screenings returns how many times address was screened
+func (p *countingProvider) screenings(address string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls[common.HexToAddress(address)]
+}
+
+// newTestScreener creates a Screener over providers recording to a memory ledger
+func newTestScreener(t *testing.T, cacheSeconds int, failOpen bool, providers ...Provider) (*Screener, *storage.MemoryLedger) {
+	t.Helper()
+
+	ledger := storage.NewMemoryLedger()
+	screener, err := NewScreener(config.ComplianceConfig{
+		FailOpen:      failOpen,
+		TimeoutMillis: 1000,
+		CacheSeconds:  cacheSeconds,
+	}, ledger, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new screener: %v", err)
+	}
+	screener.providers = providers
+	return screener, ledger
+}
+
+// screenPayment screens a payment from testPayer to testPayTo
+func screenPayment(screener *Screener) *storage.ScreeningRecord {
+	return screener.Screen(context.Background(),
+		models.PaymentRequirements{Network: "local", PayTo: testPayTo},
+		models.Authorization{From: testPayer, Value: "100", Nonce: "0x01"},
+	)
+}
+
+// recorded returns the screening decisions recorded about address
+func recorded(t *testing.T, ledger *storage.MemoryLedger, address string) []storage.ScreeningDecision {
+	t.Helper()
+
+	records, err := ledger.QueryScreenings(context.Background(), storage.ScreeningFilter{Address: address})
+	if err != nil {
+		t.Fatalf("query screenings: %v", err)
+	}
+	decisions := make([]storage.ScreeningDecision, len(records))
+	for i, record := range records {
+		decisions[i] = record.Decision
+	}
+	return decisions
+}
+
+func TestScreenerBlocksListedAddress(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		providers func() []Provider
+		wantBlock bool
+		wantRole  string
+		wantBy    string
+	}{
+		{"allowed", func() []Provider { return []Provider{newCountingProvider("list")} }, false, "", ""},
+		{"payer listed", func() []Provider { return []Provider{newCountingProvider("list", testPayer)} }, true, RolePayer, "list"},
+		{"payTo listed", func() []Provider { return []Provider{newCountingProvider("list", testPayTo)} }, true, RolePayTo, "list"},
+		{"payer first when both are listed", func() []Provider { return []Provider{newCountingProvider("list", testPayer, testPayTo)} }, true, RolePayer, "list"},
+		{"listing by a later provider wins over an unavailable one", func() []Provider {
+			down := newCountingProvider("down")
+			down.err = errors.New("connection refused")
+			return []Provider{down, newCountingProvider("list", testPayer)}
+		}, true, RolePayer, "list"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			screener, _ := newTestScreener(t, 60, false, tc.providers()...)
+			record := screenPayment(screener)
+			if !tc.wantBlock {
+				if record != nil {
+					t.Fatalf("want the payment allowed, got %+v", record)
+				}
+				return
+			}
+			if record == nil || record.Decision != storage.ScreeningBlocked || record.Role != tc.wantRole || record.Provider != tc.wantBy {
+				t.Fatalf("want the %s blocked by %s, got %+v", tc.wantRole, tc.wantBy, record)
+			}
+		})
+	}
+}
+
+func TestScreenerCachesDecisions(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		cacheSeconds   int
+		listed         []string
+		wantScreenings int
+		// wantPayerRecords are the decisions recorded about the payer, newest first
+		wantPayerRecords []storage.ScreeningDecision
+	}{
+		{"cached allowed decision is not recorded again", 60, nil, 1,
+			[]storage.ScreeningDecision{storage.ScreeningAllowed}},
+		{"cached blocked decision is recorded every time", 60, []string{testPayer}, 1,
+			[]storage.ScreeningDecision{storage.ScreeningBlocked, storage.ScreeningBlocked}},
+		{"no cache screens every time", 0, nil, 2,
+			[]storage.ScreeningDecision{storage.ScreeningAllowed, storage.ScreeningAllowed}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := newCountingProvider("list", tc.listed...)
+			screener, ledger := newTestScreener(t, tc.cacheSeconds, false, provider)
+
+			screenPayment(screener)
+			screenPayment(screener)
+
+			if got := provider.screenings(testPayer); got != tc.wantScreenings {
+				t.Fatalf("payer screened %d times, want %d", got, tc.wantScreenings)
+			}
+			got := recorded(t, ledger, testPayer)
+			if len(got) != len(tc.wantPayerRecords) {
+				t.Fatalf("recorded %v about the payer, want %v", got, tc.wantPayerRecords)
+			}
+			for i := range got {
+				if got[i] != tc.wantPayerRecords[i] {
+					t.Fatalf("recorded %v about the payer, want %v", got, tc.wantPayerRecords)
+				}
+			}
+		})
+	}
+}
+
+func TestScreenerDoesNotCacheUnavailableDecisions(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		failOpen  bool
+		wantBlock bool
+	}{
+		{"fail closed refuses the payment", false, true},
+		{"fail open lets the payment through", true, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := newCountingProvider("down")
+			provider.err = errors.New("connection refused")
+			screener, ledger := newTestScreener(t, 60, tc.failOpen, provider)
+
+			for i := 0; i < 2; i++ {
+				record := screenPayment(screener)
+				if blocked := record != nil; blocked != tc.wantBlock {
+					t.Fatalf("screen %d: got %+v, want refused %v", i, record, tc.wantBlock)
+				}
+				if record != nil && (record.Decision != storage.ScreeningUnavailable || record.Provider != "down") {
+					t.Fatalf("screen %d: want an unavailable decision by down, got %+v", i, record)
+				}
+			}
+
+			if got := provider.screenings(testPayer); got != 2 {
+				t.Fatalf("payer screened %d times, want the provider asked again", got)
+			}
+			records, err := ledger.QueryScreenings(context.Background(), storage.ScreeningFilter{Address: testPayer})
+			if err != nil {
+				t.Fatalf("query screenings: %v", err)
+			}
+			if len(records) != 2 {
+				t.Fatalf("recorded %d decisions about the payer, want every unavailable decision", len(records))
+			}
+			for _, record := range records {
+				if record.Decision != storage.ScreeningUnavailable || record.FailOpen != tc.failOpen {
+					t.Fatalf("want unavailable decisions with failOpen %v, got %+v", tc.failOpen, record)
+				}
+			}
+		})
+	}
+}
@@ -2,10 +2,14 @@ package config
 
 import (
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/jinzhu/configor"
 )
 
@@ -13,6 +17,8 @@ import (
 type ServerConfig struct {
 	Host string `yaml:"host"`
 	Port int    `yaml:"port"`
+	// RetryAfterSeconds is the Retry-After of 503 responses to requests that failed on the facilitator's side
+	RetryAfterSeconds int `yaml:"retryAfterSeconds" default:"5"`
 }
 
 // X402Config holds X402 facilitator configuration
@@ -20,6 +26,12 @@ type X402Config struct {
 	// FacilitatorPrivateKey is loaded from environment variable X402_FACILITATOR_PRIVATE_KEY
 	// It is not read from YAML for security reasons
 	FacilitatorPrivateKey string
+	// AttestationPrivateKey is loaded from environment variable X402_ATTESTATION_PRIVATE_KEY
+	// Settlements are returned with an EIP-712 signed attestation when it is set
+	AttestationPrivateKey string
+	// AdminToken is loaded from environment variable X402_ADMIN_TOKEN
+	// It is the bearer token of the ledger and status endpoints, which are refused while it is not set
+	AdminToken string
 }
 
 // LoggingConfig holds logging configuration
@@ -28,12 +40,271 @@ type LoggingConfig struct {
 	Format string `yaml:"format"`
 }
 
+// BatchingConfig holds Multicall3 batched settlement configuration
+type BatchingConfig struct {
+	// Enabled turns on batching, verified authorizations are then settled together per (network, asset)
+	Enabled bool `yaml:"enabled"`
+	// WindowMillis is how long authorizations are gathered before their batch is submitted
+	WindowMillis int `yaml:"windowMillis" default:"2000"`
+	// MaxBatchSize submits a batch early once it holds this many authorizations
+	MaxBatchSize int `yaml:"maxBatchSize" default:"50"`
+	// Multicall3Address is the Multicall3 deployment used to submit batches
+	Multicall3Address string `yaml:"multicall3Address" default:"0xcA11bde05977b3631167028862bE2a173976CA11"`
+}
+
+// DeferredConfig holds deferred settlement configuration
+type DeferredConfig struct {
+	// Enabled turns on deferred settlement, /settle then queues verified payments and settles them later in bulk
+	Enabled bool `yaml:"enabled"`
+	// MaxPendingCount flushes a network's queue once it holds this many payments
+	MaxPendingCount int `yaml:"maxPendingCount" default:"100"`
+	// MaxPendingValue flushes a network's queue once its payments add up to this many atomic token units
+	MaxPendingValue string `yaml:"maxPendingValue" default:"10000000"`
+	// MaxDelaySeconds flushes a network's queue once its oldest payment has waited this long
+	MaxDelaySeconds int `yaml:"maxDelaySeconds" default:"300"`
+	// ExpiryMarginSeconds flushes a network's queue once any payment is this close to its validBefore
+	// Payments accepted with less time left are settled inline
+	ExpiryMarginSeconds int `yaml:"expiryMarginSeconds" default:"120"`
+	// CheckIntervalSeconds is the interval between checks of the flush thresholds, flushed payments not mined yet
+	// are looked up on-chain at the same interval
+	CheckIntervalSeconds int `yaml:"checkIntervalSeconds" default:"5"`
+}
+
+// SettlementConfig holds settlement transaction configuration
+type SettlementConfig struct {
+	// ReplacementDelaySeconds is how long a settlement transaction may stay pending
+	// before it is rebroadcast or replaced with bumped fees
+	ReplacementDelaySeconds int `yaml:"replacementDelaySeconds" default:"30"`
+	// FeeBumpPercent is the percentage by which fees are raised for each replacement
+	// Nodes reject replacements bumped by less than 10%
+	FeeBumpPercent int `yaml:"feeBumpPercent" default:"15"`
+	// MaxReplacements caps the number of fee-bumped replacements, further attempts only rebroadcast
+	MaxReplacements int `yaml:"maxReplacements" default:"5"`
+	// ReceiptPollIntervalMillis is the interval between head polls of networks without a WebSocket endpoint,
+	// and between the replacement and expiry checks of pending transactions
+	ReceiptPollIntervalMillis int `yaml:"receiptPollIntervalMillis" default:"1000"`
+	// MaxTrackingSeconds caps how long a broadcast settlement transaction is tracked, whatever its validBefore
+	// A settlement still unmined by then is answered as pending and its outcome is resolved in the background
+	MaxTrackingSeconds int `yaml:"maxTrackingSeconds" default:"600"`
+	// ResolveIntervalSeconds is the interval between on-chain lookups of settlements answered as pending
+	ResolveIntervalSeconds int `yaml:"resolveIntervalSeconds" default:"15"`
+	// ReorgWatchBlocks is how many blocks past inclusion a settled transaction keeps being re-checked for reorgs
+	ReorgWatchBlocks uint64 `yaml:"reorgWatchBlocks" default:"64"`
+	// ReorgCheckIntervalSeconds is the interval between reorg re-checks of recent settlements
+	ReorgCheckIntervalSeconds int `yaml:"reorgCheckIntervalSeconds" default:"15"`
+	// IdempotencyTTLSeconds is how long settlement outcomes are kept to answer repeated /settle calls
+	IdempotencyTTLSeconds int `yaml:"idempotencyTTLSeconds" default:"86400"`
+	// Batching configures optional Multicall3 batched settlement
+	Batching BatchingConfig `yaml:"batching"`
+	// Deferred configures optional deferred settlement
+	Deferred DeferredConfig `yaml:"deferred"`
+}
+
+// ReadEndpoints returns the RPC endpoints used for reads
+func (n NetworkInfo) ReadEndpoints() []string {
+	if len(n.RPCURLs) > 0 {
+		return n.RPCURLs
+	}
+	if n.RPCURL != "" {
+		return []string{n.RPCURL}
+	}
+	return nil
+}
+
+// BroadcastEndpoints returns the RPC endpoints used to send transactions
+func (n NetworkInfo) BroadcastEndpoints() []string {
+	if len(n.BroadcastRPCURLs) > 0 {
+		return n.BroadcastRPCURLs
+	}
+	return n.ReadEndpoints()
+}
+
+// RPCConfig holds RPC endpoint health checking configuration
+type RPCConfig struct {
+	// HealthCheckIntervalSeconds is the interval between health checks of every RPC endpoint and reconnects of unavailable networks
+	HealthCheckIntervalSeconds int `yaml:"healthCheckIntervalSeconds" default:"15"`
+	// UnhealthyCooldownSeconds is how long a failing endpoint is only used when no healthy endpoint is left
+	UnhealthyCooldownSeconds int `yaml:"unhealthyCooldownSeconds" default:"30"`
+	// Policy is the call policy of every network without its own rpcPolicy
+	Policy RPCPolicy `yaml:"policy"`
+}
+
+// RPCPolicy holds the timeout, retry and circuit breaker policy of a network's RPC calls
+type RPCPolicy struct {
+	// CallTimeoutMillis is the deadline of a single RPC call attempt
+	CallTimeoutMillis int `yaml:"callTimeoutMillis" default:"5000"`
+	// MaxRetries is how many times a failed read is retried, transactions are never retried
+	MaxRetries int `yaml:"maxRetries" default:"2"`
+	// RetryBackoffMillis is the base of the jittered exponential backoff between retries
+	RetryBackoffMillis int `yaml:"retryBackoffMillis" default:"200"`
+	// BreakerFailures is the number of consecutive failed calls that opens the network's circuit breaker
+	BreakerFailures int `yaml:"breakerFailures" default:"5"`
+	// BreakerCooldownSeconds is how long an open circuit breaker rejects calls before a trial call is let through
+	BreakerCooldownSeconds int `yaml:"breakerCooldownSeconds" default:"30"`
+}
+
+// PolicyFor returns the call policy of a network, its rpcPolicy fields override the defaults when set
+func (r RPCConfig) PolicyFor(networkInfo NetworkInfo) RPCPolicy {
+	policy := r.Policy
+	override := networkInfo.RPCPolicy
+	if override.CallTimeoutMillis > 0 {
+		policy.CallTimeoutMillis = override.CallTimeoutMillis
+	}
+	if override.MaxRetries > 0 {
+		policy.MaxRetries = override.MaxRetries
+	}
+	if override.RetryBackoffMillis > 0 {
+		policy.RetryBackoffMillis = override.RetryBackoffMillis
+	}
+	if override.BreakerFailures > 0 {
+		policy.BreakerFailures = override.BreakerFailures
+	}
+	if override.BreakerCooldownSeconds > 0 {
+		policy.BreakerCooldownSeconds = override.BreakerCooldownSeconds
+	}
+	return policy
+}
+
+// GasMonitorConfig holds settlement signer gas balance monitoring configuration
+type GasMonitorConfig struct {
+	// CheckIntervalSeconds is the interval between native balance checks of the settlement signer
+	CheckIntervalSeconds int `yaml:"checkIntervalSeconds" default:"60"`
+}
+
+// Clocks authorization validity windows can be checked against
+const (
+	ClockSystem = "system"
+	ClockBlock  = "block"
+)
+
+// VerificationConfig holds /verify configuration
+type VerificationConfig struct {
+	// BatchReads sends the eth_calls of a verification as one Multicall3 aggregate3 call on networks where it is deployed
+	BatchReads bool `yaml:"batchReads"`
+	// Multicall3Address is the Multicall3 deployment used to batch verification reads
+	Multicall3Address string `yaml:"multicall3Address" default:"0xcA11bde05977b3631167028862bE2a173976CA11"`
+	// Clock is the time authorization validity windows are checked against, "system" for the host clock or
+	// "block" for the latest block timestamp plus the network's inclusion delay, which is what the token checks
+	Clock string `yaml:"clock" default:"system"`
+	// InclusionDelaySeconds is the expected time until a settlement sent now is included, used by the block clock
+	InclusionDelaySeconds int `yaml:"inclusionDelaySeconds" default:"2"`
+	// HeaderCacheMillis is how long the block clock reuses a network's latest block header, about a block interval
+	// of the fastest network, 0 fetches the header on every verification
+	HeaderCacheMillis int `yaml:"headerCacheMillis" default:"1000"`
+	// SkewToleranceSeconds is the disagreement with the chain's clock tolerated at the edges of a validity window,
+	// an authorization is only valid when it is valid throughout the tolerance around the clock's time
+	SkewToleranceSeconds int `yaml:"skewToleranceSeconds" default:"0"`
+	// ExposureHoldSeconds is how long a verified but unsettled payment counts against its payer's balance in later
+	// verifications, at most until its validBefore, payments being settled count until their settlement completes
+	ExposureHoldSeconds int `yaml:"exposureHoldSeconds" default:"300"`
+	// Reservations configures optional holds of verified authorizations
+	Reservations ReservationConfig `yaml:"reservations"`
+}
+
+// ReservationConfig holds configuration of verification reservations
+type ReservationConfig struct {
+	// Enabled makes a successful /verify hold the authorization for the verifying payTo and resource, so that it
+	// fails verification for any other until it is settled or the hold expires
+	Enabled bool `yaml:"enabled"`
+	// HoldSeconds is how long a verification holds the authorization
+	HoldSeconds int `yaml:"holdSeconds" default:"120"`
+}
+
+// InclusionDelayFor returns the expected inclusion delay of a network, its inclusionDelaySeconds overrides the
+// default when set
+func (v VerificationConfig) InclusionDelayFor(networkInfo NetworkInfo) time.Duration {
+	if networkInfo.InclusionDelaySeconds > 0 {
+		return time.Duration(networkInfo.InclusionDelaySeconds) * time.Second
+	}
+	return time.Duration(v.InclusionDelaySeconds) * time.Second
+}
+
+// Screening providers compliance screening can consult
+const (
+	ScreeningProviderFile = "file"
+	ScreeningProviderHTTP = "http"
+	ScreeningProviderStub = "stub"
+)
+
+// ComplianceConfig holds configuration of the compliance screening of payer and payTo addresses
+type ComplianceConfig struct {
+	// Enabled screens the payer and payTo of every verification and settlement with the configured providers
+	Enabled bool `yaml:"enabled"`
+	// FailOpen lets payments through when a provider cannot answer, by default they fail with COMPLIANCE_UNAVAILABLE
+	FailOpen bool `yaml:"failOpen"`
+	// ReloadIntervalSeconds is how often the denylist files of file providers are checked for changes
+	ReloadIntervalSeconds int `yaml:"reloadIntervalSeconds" default:"10"`
+	// TimeoutMillis bounds one screening call to an HTTP provider
+	TimeoutMillis int `yaml:"timeoutMillis" default:"2000"`
+	// CacheSeconds is how long an allowed or blocked decision about an address is reused, 0 screens every time
+	CacheSeconds int `yaml:"cacheSeconds" default:"60"`
+	// RetentionDays is how long screening records are kept, 0 keeps them forever
+	RetentionDays int `yaml:"retentionDays" default:"90"`
+	// Providers are consulted in order, the first one listing an address blocks the payment
+	Providers []ScreeningProviderConfig `yaml:"providers"`
+}
+
+// ScreeningProviderConfig configures one compliance screening provider
+type ScreeningProviderConfig struct {
+	// Name identifies the provider in logs and screening records, it defaults to the type
+	Name string `yaml:"name"`
+	// Type is "file" for a local denylist, "http" for a screening API or "stub" for a fixed in-process list
+	Type string `yaml:"type"`
+	// Path is the denylist of a file provider, one address per line or CSV rows of address and reason
+	Path string `yaml:"path"`
+	// URL is the screening endpoint of an HTTP provider
+	URL string `yaml:"url"`
+	// APIKeyEnv names the environment variable holding the API key sent to an HTTP provider as a bearer token
+	APIKeyEnv string `yaml:"apiKeyEnv"`
+	// Addresses are the listed addresses of a stub provider
+	Addresses []string `yaml:"addresses"`
+}
+
+// CacheConfig holds configuration of the cache of on-chain asset facts used during verification
+type CacheConfig struct {
+	// TTLSeconds is how long asset bytecode, EIP-3009 support, domain and decimals are cached
+	TTLSeconds int `yaml:"ttlSeconds" default:"3600"`
+	// NegativeTTLSeconds is how long a missing contract or an asset without EIP-3009 support is cached
+	NegativeTTLSeconds int `yaml:"negativeTTLSeconds" default:"60"`
+	// MaxEntries bounds the number of cached facts, the least recently used are evicted first
+	MaxEntries int `yaml:"maxEntries" default:"10000"`
+	// LoadTimeoutSeconds bounds a cache miss's on-chain read, shared by concurrent lookups of the same fact
+	LoadTimeoutSeconds int `yaml:"loadTimeoutSeconds" default:"10"`
+}
+
+// StorageConfig holds settlement ledger storage configuration
+type StorageConfig struct {
+	// Driver selects the ledger backend: "bolt" (embedded on-disk) or "memory"
+	Driver string `yaml:"driver" default:"bolt"`
+	// Path is the database file used by the bolt driver
+	Path string `yaml:"path" default:"data/ledger.db"`
+	// RecordVerifications also records /verify attempts in the ledger, settle attempts are always recorded
+	// It is a pointer so that an explicit false is told apart from the unset field the default applies to
+	RecordVerifications *bool `yaml:"recordVerifications" default:"true"`
+	// RetentionDays is how long ledger records and finished deferred settlements are kept, 0 keeps them forever
+	RetentionDays int `yaml:"retentionDays" default:"90"`
+	// PruneIntervalMinutes is the interval between deletions of records past their retention
+	PruneIntervalMinutes int `yaml:"pruneIntervalMinutes" default:"60"`
+}
+
+// RecordsVerifications reports whether /verify attempts are recorded in the ledger
+func (s StorageConfig) RecordsVerifications() bool {
+	return s.RecordVerifications == nil || *s.RecordVerifications
+}
+
 // Config represents the YAML structure for unmarshaling
 type Config struct {
-	Server   ServerConfig  `yaml:"server"`
-	X402     X402Config    `yaml:"x402"`
-	Logging  LoggingConfig `yaml:"logging"`
-	Networks NetworkConfig `yaml:"networks"`
+	Server       ServerConfig       `yaml:"server"`
+	X402         X402Config         `yaml:"x402"`
+	Logging      LoggingConfig      `yaml:"logging"`
+	Networks     NetworkConfig      `yaml:"networks"`
+	RPC          RPCConfig          `yaml:"rpc"`
+	Verification VerificationConfig `yaml:"verification"`
+	Settlement   SettlementConfig   `yaml:"settlement"`
+	GasMonitor   GasMonitorConfig   `yaml:"gasMonitor"`
+	Cache        CacheConfig        `yaml:"cache"`
+	Storage      StorageConfig      `yaml:"storage"`
+	Compliance   ComplianceConfig   `yaml:"compliance"`
 }
 
 // NetworkConfig is used for unmarshaling networks with string chainId
@@ -43,11 +314,43 @@ type NetworkConfig struct {
 
 // NetworkInfo is used for unmarshaling chainId as string
 type NetworkInfo struct {
-	Name        string `yaml:"name"`
-	RPCURL      string `yaml:"rpcURL"`
-	ChainID     int64  `yaml:"chainId"`
-	X402Version int16  `yaml:"X402Version"`
-	Scheme      string `yaml:"scheme"`
+	Name   string `yaml:"name"`
+	RPCURL string `yaml:"rpcURL"`
+	// RPCURLs lists HTTP(S) RPC endpoints used for reads with health checks and failover, RPCURL is used when empty
+	RPCURLs []string `yaml:"rpcURLs"`
+	// BroadcastRPCURLs lists RPC endpoints transactions are sent through, the read endpoints are used when empty
+	BroadcastRPCURLs []string `yaml:"broadcastRPCURLs"`
+	ChainID          int64    `yaml:"chainId"`
+	X402Version      int16    `yaml:"X402Version"`
+	Scheme           string   `yaml:"scheme"`
+	// WSURL is an optional WebSocket RPC endpoint used to subscribe to new heads for confirmation tracking,
+	// heads are polled over the read endpoints when neither it nor rpcURL is a WebSocket endpoint
+	WSURL string `yaml:"wsURL"`
+	// Confirmations is the number of blocks, including the inclusion block,
+	// a settlement transaction must have before it is reported as successful
+	Confirmations uint64 `yaml:"confirmations" default:"1"`
+	// MinGasBalance is the native balance, in wei, below which the settlement signer is considered underfunded
+	// The network is then hidden from /supported and settlements fail fast, "0" disables the guard
+	MinGasBalance string `yaml:"minGasBalance" default:"0"`
+	// Dev marks a network served by the in-process chain of --dev mode, it needs no RPC endpoint and is
+	// skipped when the facilitator runs without --dev
+	Dev bool `yaml:"dev"`
+	// InclusionDelaySeconds overrides verification.inclusionDelaySeconds for this network when set
+	InclusionDelaySeconds int `yaml:"inclusionDelaySeconds"`
+	// RPCPolicy overrides fields of rpc.policy for this network, fields left at zero inherit them
+	// configor does not apply defaults inside lists, so only the fields set here are non-zero
+	RPCPolicy RPCPolicy `yaml:"rpcPolicy"`
+}
+
+// SelectNetworks keeps the dev networks in dev mode and the RPC networks otherwise
+func (c *Config) SelectNetworks(dev bool) {
+	networkInfos := make([]NetworkInfo, 0, len(c.Networks.NetworkInfos))
+	for _, networkInfo := range c.Networks.NetworkInfos {
+		if networkInfo.Dev == dev {
+			networkInfos = append(networkInfos, networkInfo)
+		}
+	}
+	c.Networks.NetworkInfos = networkInfos
 }
 
 // Load loads configuration from config.yaml file and environment variables
@@ -64,6 +367,12 @@ func Load() (*Config, error) {
 	if privateKey := os.Getenv("X402_FACILITATOR_PRIVATE_KEY"); privateKey != "" {
 		config.X402.FacilitatorPrivateKey = privateKey
 	}
+	if privateKey := os.Getenv("X402_ATTESTATION_PRIVATE_KEY"); privateKey != "" {
+		config.X402.AttestationPrivateKey = privateKey
+	}
+	if token := os.Getenv("X402_ADMIN_TOKEN"); token != "" {
+		config.X402.AdminToken = token
+	}
 
 	return config, nil
 }
@@ -121,6 +430,213 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid server port: %d", c.Server.Port)
 	}
 
+	if c.Server.RetryAfterSeconds <= 0 {
+		return fmt.Errorf("invalid server retryAfterSeconds: %d", c.Server.RetryAfterSeconds)
+	}
+
+	if c.Verification.BatchReads && !common.IsHexAddress(c.Verification.Multicall3Address) {
+		return fmt.Errorf("invalid verification multicall3Address: %s", c.Verification.Multicall3Address)
+	}
+
+	if c.Verification.Clock != ClockSystem && c.Verification.Clock != ClockBlock {
+		return fmt.Errorf("verification clock must be %q or %q, got %q", ClockSystem, ClockBlock, c.Verification.Clock)
+	}
+	if c.Verification.InclusionDelaySeconds < 0 {
+		return fmt.Errorf("invalid verification inclusionDelaySeconds: %d", c.Verification.InclusionDelaySeconds)
+	}
+	if c.Verification.HeaderCacheMillis < 0 {
+		return fmt.Errorf("invalid verification headerCacheMillis: %d", c.Verification.HeaderCacheMillis)
+	}
+	if c.Verification.SkewToleranceSeconds < 0 {
+		return fmt.Errorf("invalid verification skewToleranceSeconds: %d", c.Verification.SkewToleranceSeconds)
+	}
+	if c.Verification.ExposureHoldSeconds <= 0 {
+		return fmt.Errorf("invalid verification exposureHoldSeconds: %d", c.Verification.ExposureHoldSeconds)
+	}
+	if c.Verification.Reservations.Enabled && c.Verification.Reservations.HoldSeconds <= 0 {
+		return fmt.Errorf("invalid verification reservations holdSeconds: %d", c.Verification.Reservations.HoldSeconds)
+	}
+
+	if c.Settlement.ReplacementDelaySeconds <= 0 {
+		return fmt.Errorf("invalid settlement replacementDelaySeconds: %d", c.Settlement.ReplacementDelaySeconds)
+	}
+
+	if c.Settlement.FeeBumpPercent < 10 {
+		return fmt.Errorf("settlement feeBumpPercent must be at least 10, got %d", c.Settlement.FeeBumpPercent)
+	}
+
+	if c.Settlement.MaxReplacements < 0 {
+		return fmt.Errorf("invalid settlement maxReplacements: %d", c.Settlement.MaxReplacements)
+	}
+
+	if c.Settlement.ReceiptPollIntervalMillis <= 0 {
+		return fmt.Errorf("invalid settlement receiptPollIntervalMillis: %d", c.Settlement.ReceiptPollIntervalMillis)
+	}
+
+	if c.Settlement.MaxTrackingSeconds <= 0 {
+		return fmt.Errorf("invalid settlement maxTrackingSeconds: %d", c.Settlement.MaxTrackingSeconds)
+	}
+
+	if c.Settlement.ResolveIntervalSeconds <= 0 {
+		return fmt.Errorf("invalid settlement resolveIntervalSeconds: %d", c.Settlement.ResolveIntervalSeconds)
+	}
+
+	if c.Settlement.IdempotencyTTLSeconds <= 0 {
+		return fmt.Errorf("invalid settlement idempotencyTTLSeconds: %d", c.Settlement.IdempotencyTTLSeconds)
+	}
+
+	if c.Settlement.Batching.Enabled {
+		if c.Settlement.Batching.WindowMillis <= 0 {
+			return fmt.Errorf("invalid settlement batching windowMillis: %d", c.Settlement.Batching.WindowMillis)
+		}
+		if c.Settlement.Batching.MaxBatchSize <= 0 {
+			return fmt.Errorf("invalid settlement batching maxBatchSize: %d", c.Settlement.Batching.MaxBatchSize)
+		}
+		if !common.IsHexAddress(c.Settlement.Batching.Multicall3Address) {
+			return fmt.Errorf("invalid settlement batching multicall3Address: %s", c.Settlement.Batching.Multicall3Address)
+		}
+	}
+
+	if deferred := c.Settlement.Deferred; deferred.Enabled {
+		if deferred.MaxPendingCount <= 0 {
+			return fmt.Errorf("invalid settlement deferred maxPendingCount: %d", deferred.MaxPendingCount)
+		}
+		if value, ok := new(big.Int).SetString(deferred.MaxPendingValue, 10); !ok || value.Sign() <= 0 {
+			return fmt.Errorf("invalid settlement deferred maxPendingValue: %s", deferred.MaxPendingValue)
+		}
+		if deferred.MaxDelaySeconds <= 0 {
+			return fmt.Errorf("invalid settlement deferred maxDelaySeconds: %d", deferred.MaxDelaySeconds)
+		}
+		if deferred.ExpiryMarginSeconds <= 0 {
+			return fmt.Errorf("invalid settlement deferred expiryMarginSeconds: %d", deferred.ExpiryMarginSeconds)
+		}
+		if deferred.CheckIntervalSeconds <= 0 {
+			return fmt.Errorf("invalid settlement deferred checkIntervalSeconds: %d", deferred.CheckIntervalSeconds)
+		}
+	}
+
+	if c.Storage.RetentionDays < 0 {
+		return fmt.Errorf("invalid storage retentionDays: %d", c.Storage.RetentionDays)
+	}
+	if c.Storage.PruneIntervalMinutes <= 0 {
+		return fmt.Errorf("invalid storage pruneIntervalMinutes: %d", c.Storage.PruneIntervalMinutes)
+	}
+
+	if c.Settlement.ReorgCheckIntervalSeconds <= 0 {
+		return fmt.Errorf("invalid settlement reorgCheckIntervalSeconds: %d", c.Settlement.ReorgCheckIntervalSeconds)
+	}
+
+	for _, networkInfo := range c.Networks.NetworkInfos {
+		if networkInfo.Confirmations == 0 {
+			return fmt.Errorf("network %s: confirmations must be at least 1", networkInfo.Name)
+		}
+		if len(networkInfo.ReadEndpoints()) == 0 && !networkInfo.Dev {
+			return fmt.Errorf("network %s: rpcURL or rpcURLs is required", networkInfo.Name)
+		}
+		// Failover between endpoints works over HTTP only, a single rpcURL may still be a WebSocket endpoint
+		if len(networkInfo.RPCURLs) > 0 || len(networkInfo.BroadcastRPCURLs) > 0 {
+			endpoints := append([]string{}, networkInfo.ReadEndpoints()...)
+			for _, endpoint := range append(endpoints, networkInfo.BroadcastEndpoints()...) {
+				if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+					return fmt.Errorf("network %s: rpcURLs and broadcastRPCURLs must be HTTP(S) endpoints: %s", networkInfo.Name, endpoint)
+				}
+			}
+		}
+		if networkInfo.InclusionDelaySeconds < 0 {
+			return fmt.Errorf("network %s: invalid inclusionDelaySeconds: %d", networkInfo.Name, networkInfo.InclusionDelaySeconds)
+		}
+		if networkInfo.WSURL != "" && !strings.HasPrefix(networkInfo.WSURL, "ws://") && !strings.HasPrefix(networkInfo.WSURL, "wss://") {
+			return fmt.Errorf("network %s: wsURL must be a WebSocket endpoint: %s", networkInfo.Name, networkInfo.WSURL)
+		}
+		// configor does not apply defaults inside lists, so an omitted minGasBalance is empty and disables the guard
+		if networkInfo.MinGasBalance != "" {
+			if value, ok := new(big.Int).SetString(networkInfo.MinGasBalance, 10); !ok || value.Sign() < 0 {
+				return fmt.Errorf("network %s: invalid minGasBalance: %s", networkInfo.Name, networkInfo.MinGasBalance)
+			}
+		}
+	}
+
+	if c.RPC.HealthCheckIntervalSeconds <= 0 {
+		return fmt.Errorf("invalid rpc healthCheckIntervalSeconds: %d", c.RPC.HealthCheckIntervalSeconds)
+	}
+	if c.RPC.UnhealthyCooldownSeconds <= 0 {
+		return fmt.Errorf("invalid rpc unhealthyCooldownSeconds: %d", c.RPC.UnhealthyCooldownSeconds)
+	}
+	for _, networkInfo := range c.Networks.NetworkInfos {
+		policy := c.RPC.PolicyFor(networkInfo)
+		if policy.CallTimeoutMillis <= 0 {
+			return fmt.Errorf("network %s: invalid rpc policy callTimeoutMillis: %d", networkInfo.Name, policy.CallTimeoutMillis)
+		}
+		if policy.MaxRetries < 0 {
+			return fmt.Errorf("network %s: invalid rpc policy maxRetries: %d", networkInfo.Name, policy.MaxRetries)
+		}
+		if policy.MaxRetries > 0 && policy.RetryBackoffMillis <= 0 {
+			return fmt.Errorf("network %s: invalid rpc policy retryBackoffMillis: %d", networkInfo.Name, policy.RetryBackoffMillis)
+		}
+		if policy.BreakerFailures <= 0 {
+			return fmt.Errorf("network %s: invalid rpc policy breakerFailures: %d", networkInfo.Name, policy.BreakerFailures)
+		}
+		if policy.BreakerCooldownSeconds <= 0 {
+			return fmt.Errorf("network %s: invalid rpc policy breakerCooldownSeconds: %d", networkInfo.Name, policy.BreakerCooldownSeconds)
+		}
+	}
+
+	if c.GasMonitor.CheckIntervalSeconds <= 0 {
+		return fmt.Errorf("invalid gasMonitor checkIntervalSeconds: %d", c.GasMonitor.CheckIntervalSeconds)
+	}
+
+	if c.Cache.TTLSeconds <= 0 {
+		return fmt.Errorf("invalid cache ttlSeconds: %d", c.Cache.TTLSeconds)
+	}
+	if c.Cache.NegativeTTLSeconds < 0 {
+		return fmt.Errorf("invalid cache negativeTTLSeconds: %d", c.Cache.NegativeTTLSeconds)
+	}
+	if c.Cache.MaxEntries <= 0 {
+		return fmt.Errorf("invalid cache maxEntries: %d", c.Cache.MaxEntries)
+	}
+	if c.Cache.LoadTimeoutSeconds <= 0 {
+		return fmt.Errorf("invalid cache loadTimeoutSeconds: %d", c.Cache.LoadTimeoutSeconds)
+	}
+
+	if c.Compliance.Enabled {
+		if len(c.Compliance.Providers) == 0 {
+			return fmt.Errorf("compliance providers are required when compliance screening is enabled")
+		}
+		if c.Compliance.ReloadIntervalSeconds <= 0 {
+			return fmt.Errorf("invalid compliance reloadIntervalSeconds: %d", c.Compliance.ReloadIntervalSeconds)
+		}
+		if c.Compliance.TimeoutMillis <= 0 {
+			return fmt.Errorf("invalid compliance timeoutMillis: %d", c.Compliance.TimeoutMillis)
+		}
+		if c.Compliance.CacheSeconds < 0 {
+			return fmt.Errorf("invalid compliance cacheSeconds: %d", c.Compliance.CacheSeconds)
+		}
+		if c.Compliance.RetentionDays < 0 {
+			return fmt.Errorf("invalid compliance retentionDays: %d", c.Compliance.RetentionDays)
+		}
+		for i, provider := range c.Compliance.Providers {
+			switch provider.Type {
+			case ScreeningProviderFile:
+				if provider.Path == "" {
+					return fmt.Errorf("compliance provider %d: path is required for a file provider", i)
+				}
+			case ScreeningProviderHTTP:
+				if !strings.HasPrefix(provider.URL, "http://") && !strings.HasPrefix(provider.URL, "https://") {
+					return fmt.Errorf("compliance provider %d: url must be an HTTP(S) endpoint: %s", i, provider.URL)
+				}
+			case ScreeningProviderStub:
+				for _, address := range provider.Addresses {
+					if !common.IsHexAddress(address) {
+						return fmt.Errorf("compliance provider %d: invalid address: %s", i, address)
+					}
+				}
+			default:
+				return fmt.Errorf("compliance provider %d: type must be %q, %q or %q, got %q",
+					i, ScreeningProviderFile, ScreeningProviderHTTP, ScreeningProviderStub, provider.Type)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,91 @@
+package devchain
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// program assembles EVM bytecode with named jump destinations
+type program struct {
+	code   []byte
+	labels map[string]int
+	// refs maps the offsets of PUSH2 operands to the labels they are patched with
+	refs map[int]string
+}
+
+// newProgram creates an empty program
+func newProgram() *program {
+	return &program{
+		labels: make(map[string]int),
+		refs:   make(map[int]string),
+	}
+}
+
+// op appends opcodes
+func (p *program) op(ops ...vm.OpCode) *program {
+	for _, op := range ops {
+		p.code = append(p.code, byte(op))
+	}
+	return p
+}
+
+// push appends the smallest PUSH of value
+func (p *program) push(value uint64) *program {
+	return p.pushBytes(new(big.Int).SetUint64(value).Bytes())
+}
+
+// pushBytes appends a PUSH of up to 32 bytes, PUSH1 0 for none
+func (p *program) pushBytes(value []byte) *program {
+	if len(value) == 0 {
+		value = []byte{0}
+	}
+	if len(value) > 32 {
+		panic(fmt.Sprintf("push of %d bytes", len(value)))
+	}
+	p.code = append(p.code, byte(vm.PUSH1)+byte(len(value)-1))
+	p.code = append(p.code, value...)
+	return p
+}
+
+// pushLabel appends a PUSH2 of a label's position, resolved by assemble
+func (p *program) pushLabel(name string) *program {
+	p.code = append(p.code, byte(vm.PUSH2))
+	p.refs[len(p.code)] = name
+	p.code = append(p.code, 0, 0)
+	return p
+}
+
+// jump appends an unconditional jump to a label
+func (p *program) jump(name string) *program {
+	return p.pushLabel(name).op(vm.JUMP)
+}
+
+// jumpIf appends a jump to a label taken when the top of the stack is non-zero
+func (p *program) jumpIf(name string) *program {
+	return p.pushLabel(name).op(vm.JUMPI)
+}
+
+// label marks a jump destination
+func (p *program) label(name string) *program {
+	if _, ok := p.labels[name]; ok {
+		panic(fmt.Sprintf("duplicate label %s", name))
+	}
+	p.labels[name] = len(p.code)
+	return p.op(vm.JUMPDEST)
+}
+
+// assemble resolves the labels and returns the bytecode
+func (p *program) assemble() ([]byte, error) {
+	code := append([]byte{}, p.code...)
+	for offset, name := range p.refs {
+		position, ok := p.labels[name]
+		if !ok {
+			return nil, fmt.Errorf("undefined label %s", name)
+		}
+		code[offset] = byte(position >> 8)
+		code[offset+1] = byte(position)
+	}
+	return code, nil
+}
@@ -0,0 +1,119 @@
+// Package devchain provides the in-process chain of --dev mode: a simulated chain per dev network with a bundled
+// EIP-3009 test token and well-known funded accounts, so that /verify and /settle can be exercised offline
+package devchain
+
+import (
+	"fmt"
+	"math/big"
+	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/web3"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ChainID is the chain ID of the dev networks, fixed by go-ethereum's simulated backend
+const ChainID = 1337
+
+// payerCount is the number of well-known payer accounts
+const payerCount = 4
+
+// blockGasLimit is the gas limit of the dev chain's blocks
+const blockGasLimit = 30_000_000
+
+var (
+	// PayerTokenBalance is each payer's test token balance, 1,000,000 tokens
+	PayerTokenBalance = big.NewInt(1_000_000_000_000)
+	// payerEtherBalance is each payer's ether balance, 100 ether
+	payerEtherBalance = new(big.Int).Mul(big.NewInt(100), big.NewInt(1e18))
+	// facilitatorEtherBalance is the settlement signer's ether balance, 1,000 ether
+	facilitatorEtherBalance = new(big.Int).Mul(big.NewInt(1_000), big.NewInt(1e18))
+)
+
+// Account is a well-known dev chain account
+type Account struct {
+	Address    common.Address
+	PrivateKey string
+}
+
+// Payers returns the well-known payer accounts holding test tokens
+// Their keys are derived from fixed seeds, so they are the same on every run and may be hardcoded in examples
+func Payers() []Account {
+	payers := make([]Account, 0, payerCount)
+	for i := 0; i < payerCount; i++ {
+		payers = append(payers, account(fmt.Sprintf("x402-facilitator dev payer %d", i)))
+	}
+	return payers
+}
+
+// Facilitator returns the well-known settlement signer used in dev mode when no facilitator key is configured
+func Facilitator() Account {
+	return account("x402-facilitator dev facilitator")
+}
+
+// account derives an account from a seed
+func account(seed string) Account {
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte(seed)))
+	if err != nil {
+		panic(fmt.Sprintf("invalid dev account seed %q: %v", seed, err))
+	}
+	return Account{
+		Address:    crypto.PubkeyToAddress(key.PublicKey),
+		PrivateKey: hexutil.Encode(crypto.FromECDSA(key))[2:],
+	}
+}
+
+// NewBackend creates a simulated chain with the test token at TokenAddress, Multicall3 at Multicall3Address,
+// the payers holding test tokens and ether and the facilitator holding ether
+func NewBackend(facilitator common.Address) (*web3.SimulatedBackend, error) {
+	code, err := tokenCode(big.NewInt(ChainID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to assemble test token: %w", err)
+	}
+	multicall, err := multicallCode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to assemble Multicall3: %w", err)
+	}
+
+	alloc := core.GenesisAlloc{
+		facilitator: {Balance: facilitatorEtherBalance},
+	}
+	balances := make(map[common.Address]*big.Int)
+	for _, payer := range Payers() {
+		alloc[payer.Address] = core.GenesisAccount{Balance: payerEtherBalance}
+		balances[payer.Address] = PayerTokenBalance
+	}
+	alloc[TokenAddress] = core.GenesisAccount{
+		Code:    code,
+		Storage: tokenStorage(balances),
+		Balance: new(big.Int),
+	}
+	alloc[Multicall3Address] = core.GenesisAccount{
+		Code:    multicall,
+		Balance: new(big.Int),
+	}
+
+	return web3.NewSimulatedBackend(alloc, blockGasLimit), nil
+}
+
+// NewChain creates a simulated chain for every dev network
+func NewChain(networkInfos []config.NetworkInfo, facilitator common.Address) (*web3.Simulated, error) {
+	networks := make([]web3.SimulatedNetwork, 0, len(networkInfos))
+	for _, networkInfo := range networkInfos {
+		if networkInfo.ChainID != ChainID {
+			return nil, fmt.Errorf("dev network %s: chainId must be %d, got %d", networkInfo.Name, ChainID, networkInfo.ChainID)
+		}
+		backend, err := NewBackend(facilitator)
+		if err != nil {
+			return nil, fmt.Errorf("dev network %s: %w", networkInfo.Name, err)
+		}
+		networks = append(networks, web3.SimulatedNetwork{
+			Name:          networkInfo.Name,
+			Backend:       backend,
+			Confirmations: networkInfo.Confirmations,
+		})
+	}
+	return web3.NewSimulated(networks...), nil
+}
@@ -0,0 +1,93 @@
+package devchain
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Multicall3Address is the address of Multicall3 on every dev network, its canonical deployment address
+var Multicall3Address = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+// Memory layout of aggregate3, the loop variables live below multicallOutput, where the returned results are built
+const (
+	multicallCalls  = 0x00
+	multicallCount  = 0x20
+	multicallIndex  = 0x40
+	multicallWrite  = 0x60
+	multicallCall   = 0x80
+	multicallData   = 0xa0
+	multicallOutput = 0x100
+	// multicallResults is where the offsets of the result tuples start, the tuples are relative to it
+	multicallResults = multicallOutput + 0x40
+)
+
+// multicallCode assembles the runtime bytecode of a minimal Multicall3 implementing only
+// aggregate3((address,bool,bytes)[]) returns ((bool,bytes)[]), which is all settlement batching and
+// verification read batching call
+func multicallCode() ([]byte, error) {
+	p := newProgram()
+
+	p.push(0).op(vm.CALLDATALOAD).push(224).op(vm.SHR)
+	p.op(vm.DUP1).pushBytes(crypto.Keccak256([]byte("aggregate3((address,bool,bytes)[])"))[:4]).op(vm.EQ).jumpIf("aggregate3")
+	p.push(0).op(vm.DUP1, vm.REVERT)
+
+	// calls is the calldata offset of the calls array's length, count its length and write the memory offset
+	// of the next result tuple, after the results' offsets
+	p.label("aggregate3")
+	p.push(0x04).op(vm.CALLDATALOAD).push(0x04).op(vm.ADD).push(multicallCalls).op(vm.MSTORE)
+	p.push(multicallCalls).op(vm.MLOAD, vm.CALLDATALOAD).push(multicallCount).op(vm.MSTORE)
+	p.push(0x20).push(multicallOutput).op(vm.MSTORE)
+	p.push(multicallCount).op(vm.MLOAD).push(multicallOutput + 0x20).op(vm.MSTORE)
+	p.push(multicallCount).op(vm.MLOAD).push(0x20).op(vm.MUL).push(multicallResults).op(vm.ADD).push(multicallWrite).op(vm.MSTORE)
+	p.push(0).push(multicallIndex).op(vm.MSTORE)
+
+	p.label("loop")
+	p.push(multicallCount).op(vm.MLOAD).push(multicallIndex).op(vm.MLOAD, vm.EQ).jumpIf("done")
+
+	// call is the calldata offset of the (target, allowFailure, callData) tuple, data that of its callData's length
+	p.push(multicallIndex).op(vm.MLOAD).push(0x20).op(vm.MUL)
+	p.push(multicallCalls).op(vm.MLOAD).op(vm.ADD).push(0x20).op(vm.ADD, vm.CALLDATALOAD)
+	p.push(multicallCalls).op(vm.MLOAD).op(vm.ADD).push(0x20).op(vm.ADD).push(multicallCall).op(vm.MSTORE)
+	p.push(multicallCall).op(vm.MLOAD).push(0x40).op(vm.ADD, vm.CALLDATALOAD)
+	p.push(multicallCall).op(vm.MLOAD).op(vm.ADD).push(multicallData).op(vm.MSTORE)
+
+	// The callData is copied where the result's returnData goes, which the call overwrites only once it returned
+	p.push(multicallData).op(vm.MLOAD, vm.CALLDATALOAD)
+	p.push(multicallData).op(vm.MLOAD).push(0x20).op(vm.ADD)
+	p.push(multicallWrite).op(vm.MLOAD).push(0x60).op(vm.ADD)
+	p.op(vm.CALLDATACOPY)
+
+	// [success]
+	p.push(0).push(0)
+	p.push(multicallData).op(vm.MLOAD, vm.CALLDATALOAD)
+	p.push(multicallWrite).op(vm.MLOAD).push(0x60).op(vm.ADD)
+	p.push(0)
+	p.push(multicallCall).op(vm.MLOAD, vm.CALLDATALOAD).pushBytes(addressMask).op(vm.AND)
+	p.op(vm.GAS, vm.CALL)
+	p.op(vm.DUP1).jumpIf("result")
+	p.push(multicallCall).op(vm.MLOAD).push(0x20).op(vm.ADD, vm.CALLDATALOAD).jumpIf("result")
+	revertWith(p, "Multicall3: call failed")
+
+	// Write the result's offset and the (success, returnData) tuple
+	p.label("result")
+	p.push(multicallResults).push(multicallWrite).op(vm.MLOAD, vm.SUB)
+	p.push(multicallIndex).op(vm.MLOAD).push(0x20).op(vm.MUL).push(multicallResults).op(vm.ADD, vm.MSTORE)
+	p.push(multicallWrite).op(vm.MLOAD, vm.MSTORE)
+	p.push(0x40).push(multicallWrite).op(vm.MLOAD).push(0x20).op(vm.ADD, vm.MSTORE)
+	p.op(vm.RETURNDATASIZE).push(multicallWrite).op(vm.MLOAD).push(0x40).op(vm.ADD, vm.MSTORE)
+	p.op(vm.RETURNDATASIZE).push(0).push(multicallWrite).op(vm.MLOAD).push(0x60).op(vm.ADD, vm.RETURNDATACOPY)
+	// Clear the padding of the returnData, which may still hold the copied callData
+	p.push(0).op(vm.RETURNDATASIZE).push(multicallWrite).op(vm.MLOAD).push(0x60).op(vm.ADD, vm.ADD, vm.MSTORE)
+	p.push(0x20).push(0x1f).op(vm.RETURNDATASIZE, vm.ADD, vm.DIV).push(0x20).op(vm.MUL)
+	p.push(multicallWrite).op(vm.MLOAD, vm.ADD).push(0x60).op(vm.ADD).push(multicallWrite).op(vm.MSTORE)
+
+	p.push(multicallIndex).op(vm.MLOAD).push(1).op(vm.ADD).push(multicallIndex).op(vm.MSTORE)
+	p.jump("loop")
+
+	p.label("done")
+	p.push(multicallOutput).push(multicallWrite).op(vm.MLOAD, vm.SUB)
+	p.push(multicallOutput).op(vm.RETURN)
+
+	return p.assemble()
+}
@@ -0,0 +1,272 @@
+package devchain
+
+import (
+	"math/big"
+	"x402-facilitator-go/internal/util/eip712"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// TokenName is the name, and EIP-712 domain name, of the test token
+	TokenName = "Test USD Coin"
+	// TokenSymbol is the symbol of the test token
+	TokenSymbol = "TUSDC"
+	// TokenVersion is the EIP-712 domain version of the test token
+	TokenVersion = "2"
+	// TokenDecimals is the number of decimals of the test token
+	TokenDecimals = 6
+)
+
+// TokenAddress is the address of the test token on every dev network
+var TokenAddress = common.HexToAddress("0x0000000000000000000000000000000000003009")
+
+// The test token's storage layout
+// Balances live at keccak256(account . 0) and authorization states at keccak256(authorizer . nonce . 1),
+// whose preimages differ in length so that the two never collide
+const (
+	balancesSlot      = 0
+	authorizationSlot = 1
+	totalSupplySlot   = 2
+)
+
+// addressMask clears the bits above an address in an ABI word
+var addressMask = common.MaxAddress.Bytes()
+
+var (
+	transferWithAuthorizationTypehash = crypto.Keccak256([]byte("TransferWithAuthorization(address from,address to,uint256 value,uint256 validAfter,uint256 validBefore,bytes32 nonce)"))
+	transferTopic                     = crypto.Keccak256([]byte("Transfer(address,address,uint256)"))
+	authorizationUsedTopic            = crypto.Keccak256([]byte("AuthorizationUsed(address,bytes32)"))
+	errorSelector                     = crypto.Keccak256([]byte("Error(string)"))[:4]
+)
+
+// tokenFunctions are the test token's functions and the labels of their implementations
+var tokenFunctions = []struct {
+	signature string
+	label     string
+}{
+	{"name()", "name"},
+	{"symbol()", "symbol"},
+	{"version()", "version"},
+	{"decimals()", "decimals"},
+	{"totalSupply()", "totalSupply"},
+	{"DOMAIN_SEPARATOR()", "domainSeparator"},
+	{"balanceOf(address)", "balanceOf"},
+	{"authorizationState(address,bytes32)", "authorizationState"},
+	{"transferWithAuthorization(address,address,uint256,uint256,uint256,bytes32,uint8,bytes32,bytes32)", "transferWithAuthorizationVRS"},
+	{"transferWithAuthorization(address,address,uint256,uint256,uint256,bytes32,bytes)", "transferWithAuthorizationBytes"},
+}
+
+// tokenDomainSeparator computes the test token's EIP-712 domain separator
+func tokenDomainSeparator(chainID *big.Int) []byte {
+	return eip712.ComputeDomainSeparator(eip712.DomainSeparatorParams{
+		Name:              TokenName,
+		Version:           TokenVersion,
+		ChainID:           chainID,
+		VerifyingContract: TokenAddress,
+	})
+}
+
+// tokenCode assembles the runtime bytecode of the test token, a minimal ERC-20 whose only transfer is
+// EIP-3009 transferWithAuthorization, reverting with the same reasons as USDC
+func tokenCode(chainID *big.Int) ([]byte, error) {
+	p := newProgram()
+
+	// Dispatch on the function selector
+	p.push(0).op(vm.CALLDATALOAD).push(224).op(vm.SHR)
+	for _, function := range tokenFunctions {
+		p.op(vm.DUP1).pushBytes(crypto.Keccak256([]byte(function.signature))[:4]).op(vm.EQ).jumpIf(function.label)
+	}
+	p.push(0).op(vm.DUP1, vm.REVERT)
+
+	p.label("name")
+	returnString(p, TokenName)
+	p.label("symbol")
+	returnString(p, TokenSymbol)
+	p.label("version")
+	returnString(p, TokenVersion)
+	p.label("decimals")
+	p.push(TokenDecimals)
+	returnWord(p)
+	p.label("totalSupply")
+	p.push(totalSupplySlot).op(vm.SLOAD)
+	returnWord(p)
+	p.label("domainSeparator")
+	p.pushBytes(tokenDomainSeparator(chainID))
+	returnWord(p)
+
+	p.label("balanceOf")
+	loadAddress(p, 0x04)
+	balanceSlot(p)
+	p.op(vm.SLOAD)
+	returnWord(p)
+
+	p.label("authorizationState")
+	loadAddress(p, 0x04)
+	p.push(0x24).op(vm.CALLDATALOAD)
+	authorizationStateSlot(p)
+	p.op(vm.SLOAD, vm.ISZERO, vm.ISZERO)
+	returnWord(p)
+
+	// transferWithAuthorization arguments are at 0x04 from, 0x24 to, 0x44 value, 0x64 validAfter,
+	// 0x84 validBefore and 0xa4 nonce, followed by either v, r and s or a packed signature
+	// Both variants store v, r and s at 0x220, 0x240 and 0x260 as ecrecover input
+	p.label("transferWithAuthorizationVRS")
+	p.push(0xc4).op(vm.CALLDATALOAD).push(0x220).op(vm.MSTORE)
+	p.push(0xe4).op(vm.CALLDATALOAD).push(0x240).op(vm.MSTORE)
+	p.push(0x104).op(vm.CALLDATALOAD).push(0x260).op(vm.MSTORE)
+	p.jump("transferWithAuthorization")
+
+	// [signatureOffset], the offset of the packed r . s . v bytes relative to the arguments
+	p.label("transferWithAuthorizationBytes")
+	p.push(0xc4).op(vm.CALLDATALOAD).push(0x04).op(vm.ADD)
+	p.op(vm.DUP1, vm.CALLDATALOAD).push(65).op(vm.EQ).jumpIf("signatureLength")
+	revertWith(p, "ECRecover: invalid signature length")
+	p.label("signatureLength")
+	p.op(vm.DUP1).push(0x20).op(vm.ADD, vm.CALLDATALOAD).push(0x240).op(vm.MSTORE)
+	p.op(vm.DUP1).push(0x40).op(vm.ADD, vm.CALLDATALOAD).push(0x260).op(vm.MSTORE)
+	p.push(0x60).op(vm.ADD, vm.CALLDATALOAD).push(248).op(vm.SHR).push(0x220).op(vm.MSTORE)
+
+	p.label("transferWithAuthorization")
+	p.push(0x64).op(vm.CALLDATALOAD, vm.TIMESTAMP, vm.GT).jumpIf("validAfter")
+	revertWith(p, "FiatTokenV2: authorization is not yet valid")
+	p.label("validAfter")
+	p.push(0x84).op(vm.CALLDATALOAD, vm.TIMESTAMP, vm.LT).jumpIf("validBefore")
+	revertWith(p, "FiatTokenV2: authorization is expired")
+	p.label("validBefore")
+
+	// [authSlot]
+	loadAddress(p, 0x04)
+	p.push(0xa4).op(vm.CALLDATALOAD)
+	authorizationStateSlot(p)
+	p.op(vm.DUP1, vm.SLOAD, vm.ISZERO).jumpIf("unused")
+	revertWith(p, "FiatTokenV2: authorization is used or canceled")
+	p.label("unused")
+
+	// structHash = keccak256(typehash . from . to . value . validAfter . validBefore . nonce)
+	p.pushBytes(transferWithAuthorizationTypehash).push(0x00).op(vm.MSTORE)
+	p.push(0xc0).push(0x04).push(0x20).op(vm.CALLDATACOPY)
+	p.push(0xe0).push(0x00).op(vm.KECCAK256)
+	// digest = keccak256(0x1901 . domainSeparator . structHash)
+	p.pushBytes(common.RightPadBytes([]byte{0x19, 0x01}, 32)).push(0x100).op(vm.MSTORE)
+	p.pushBytes(tokenDomainSeparator(chainID)).push(0x102).op(vm.MSTORE)
+	p.push(0x122).op(vm.MSTORE)
+	p.push(0x42).push(0x100).op(vm.KECCAK256)
+
+	// ecrecover(digest, v, r, s), which returns nothing for an invalid signature
+	p.push(0x200).op(vm.MSTORE)
+	p.push(0).push(0x300).op(vm.MSTORE)
+	p.push(0x20).push(0x300).push(0x80).push(0x200).push(1).op(vm.GAS, vm.STATICCALL, vm.POP)
+	p.push(0x300).op(vm.MLOAD, vm.DUP1, vm.ISZERO).jumpIf("invalidSignature")
+	loadAddress(p, 0x04)
+	p.op(vm.EQ).jumpIf("validSignature")
+	p.label("invalidSignature")
+	revertWith(p, "FiatTokenV2: invalid signature")
+	p.label("validSignature")
+
+	// Mark the authorization used and emit AuthorizationUsed(from, nonce)
+	p.push(1).op(vm.SWAP1, vm.SSTORE)
+	p.push(0xa4).op(vm.CALLDATALOAD)
+	loadAddress(p, 0x04)
+	p.pushBytes(authorizationUsedTopic).push(0).push(0).op(vm.LOG3)
+
+	// [to]
+	loadAddress(p, 0x24)
+	p.op(vm.DUP1).jumpIf("recipient")
+	revertWith(p, "ERC20: transfer to the zero address")
+	p.label("recipient")
+
+	// [value, fromBalance, fromSlot, to]
+	loadAddress(p, 0x04)
+	balanceSlot(p)
+	p.op(vm.DUP1, vm.SLOAD)
+	p.push(0x44).op(vm.CALLDATALOAD)
+	p.op(vm.DUP2, vm.DUP2, vm.GT).op(vm.ISZERO).jumpIf("funded")
+	revertWith(p, "ERC20: transfer amount exceeds balance")
+	p.label("funded")
+	// balances[from] = fromBalance - value, leaving [value, to]
+	p.op(vm.DUP2, vm.DUP2, vm.SWAP1, vm.SUB, vm.DUP4, vm.SSTORE)
+	p.op(vm.SWAP2, vm.POP, vm.POP)
+	// balances[to] += value, leaving [value, to]
+	p.op(vm.DUP2)
+	balanceSlot(p)
+	p.op(vm.DUP1, vm.SLOAD, vm.DUP3, vm.ADD, vm.SWAP1, vm.SSTORE)
+
+	// Emit Transfer(from, to, value)
+	p.push(0).op(vm.MSTORE)
+	loadAddress(p, 0x04)
+	p.pushBytes(transferTopic).push(0x20).push(0).op(vm.LOG3)
+	p.op(vm.STOP)
+
+	return p.assemble()
+}
+
+// loadAddress pushes the address argument at a calldata offset
+func loadAddress(p *program, offset uint64) {
+	p.push(offset).op(vm.CALLDATALOAD).pushBytes(addressMask).op(vm.AND)
+}
+
+// balanceSlot replaces the account on top of the stack with the storage slot of its balance
+func balanceSlot(p *program) {
+	p.push(0x00).op(vm.MSTORE)
+	p.push(balancesSlot).push(0x20).op(vm.MSTORE)
+	p.push(0x40).push(0x00).op(vm.KECCAK256)
+}
+
+// authorizationStateSlot replaces the nonce and authorizer on top of the stack with the storage slot of
+// the authorization's state
+func authorizationStateSlot(p *program) {
+	p.push(0x20).op(vm.MSTORE)
+	p.push(0x00).op(vm.MSTORE)
+	p.push(authorizationSlot).push(0x40).op(vm.MSTORE)
+	p.push(0x60).push(0x00).op(vm.KECCAK256)
+}
+
+// returnWord returns the word on top of the stack
+func returnWord(p *program) {
+	p.push(0x00).op(vm.MSTORE)
+	p.push(0x20).push(0x00).op(vm.RETURN)
+}
+
+// returnString returns an ABI encoded string of at most 32 bytes
+func returnString(p *program, value string) {
+	p.push(0x20).push(0x00).op(vm.MSTORE)
+	p.push(uint64(len(value))).push(0x20).op(vm.MSTORE)
+	p.pushBytes(common.RightPadBytes([]byte(value), 32)).push(0x40).op(vm.MSTORE)
+	p.push(0x60).push(0x00).op(vm.RETURN)
+}
+
+// revertWith reverts with an Error(string) reason
+func revertWith(p *program, reason string) {
+	p.pushBytes(common.RightPadBytes(errorSelector, 32)).push(0x00).op(vm.MSTORE)
+	p.push(0x20).push(0x04).op(vm.MSTORE)
+	p.push(uint64(len(reason))).push(0x24).op(vm.MSTORE)
+	offset := uint64(0x44)
+	for chunk := []byte(reason); len(chunk) > 0; offset += 32 {
+		n := len(chunk)
+		if n > 32 {
+			n = 32
+		}
+		p.pushBytes(common.RightPadBytes(chunk[:n], 32)).push(offset).op(vm.MSTORE)
+		chunk = chunk[n:]
+	}
+	p.push(offset).push(0x00).op(vm.REVERT)
+}
+
+// tokenStorage returns the test token's genesis storage holding the given balances
+func tokenStorage(balances map[common.Address]*big.Int) map[common.Hash]common.Hash {
+	storage := make(map[common.Hash]common.Hash, len(balances)+1)
+	totalSupply := new(big.Int)
+	for account, balance := range balances {
+		slot := crypto.Keccak256Hash(
+			common.LeftPadBytes(account.Bytes(), 32),
+			common.LeftPadBytes(big.NewInt(balancesSlot).Bytes(), 32),
+		)
+		storage[slot] = common.BigToHash(balance)
+		totalSupply.Add(totalSupply, balance)
+	}
+	storage[common.BigToHash(big.NewInt(totalSupplySlot))] = common.BigToHash(totalSupply)
+	return storage
+}
@@ -0,0 +1,258 @@
+package devchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+	"x402-facilitator-go/internal/settlement"
+	"x402-facilitator-go/internal/util/eip3009"
+	"x402-facilitator-go/internal/web3"
+	"x402-facilitator-go/internal/web3/contract"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testPayTo is the recipient of the token tests' transfers
+var testPayTo = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+
+// testToken is the test token deployed on a fresh simulated chain, with the facilitator as transactor
+type testToken struct {
+	backend *web3.SimulatedBackend
+	token   *contract.EIP3009Token
+	erc20   *contract.ERC20
+	opts    *bind.TransactOpts
+}
+
+// newTestToken creates a simulated chain holding the test token
+func newTestToken(t *testing.T) *testToken {
+	t.Helper()
+
+	facilitator := Facilitator()
+	backend, err := NewBackend(facilitator.Address)
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	t.Cleanup(func() { backend.Close() })
+
+	token, err := contract.NewEIP3009Token(TokenAddress, backend)
+	if err != nil {
+		t.Fatalf("bind token: %v", err)
+	}
+	erc20, err := contract.NewERC20(TokenAddress, backend)
+	if err != nil {
+		t.Fatalf("bind erc20: %v", err)
+	}
+	key, err := crypto.HexToECDSA(facilitator.PrivateKey)
+	if err != nil {
+		t.Fatalf("facilitator key: %v", err)
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(ChainID))
+	if err != nil {
+		t.Fatalf("transactor: %v", err)
+	}
+	return &testToken{backend: backend, token: token, erc20: erc20, opts: opts}
+}
+
+// signAuthorization signs a transfer of value from payer to testPayTo, valid until validBefore
+func signAuthorization(t *testing.T, payer Account, value int64, validBefore time.Time, nonce common.Hash) settlement.Authorization {
+	t.Helper()
+
+	validAfter := big.NewInt(time.Now().Add(-time.Hour).Unix())
+	hash := eip3009.ComputeTransferWithAuthorizationHash(eip3009.TransferWithAuthorizationParams{
+		ChainId:           big.NewInt(ChainID),
+		VerifyingContract: TokenAddress.Hex(),
+		DomainName:        TokenName,
+		DomainVersion:     TokenVersion,
+		From:              payer.Address.Hex(),
+		To:                testPayTo.Hex(),
+		Value:             big.NewInt(value).String(),
+		ValidAfter:        validAfter.String(),
+		ValidBefore:       big.NewInt(validBefore.Unix()).String(),
+		Nonce:             nonce.Hex(),
+	})
+	key, err := crypto.HexToECDSA(payer.PrivateKey)
+	if err != nil {
+		t.Fatalf("payer key: %v", err)
+	}
+	signature, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatalf("sign authorization: %v", err)
+	}
+	signature[64] += 27
+
+	return settlement.Authorization{
+		From:        payer.Address,
+		To:          testPayTo,
+		Value:       big.NewInt(value),
+		ValidAfter:  validAfter,
+		ValidBefore: big.NewInt(validBefore.Unix()),
+		Nonce:       nonce,
+		Signature:   signature,
+	}
+}
+
+// transfer sends transferWithAuthorization and returns its receipt
+func (tt *testToken) transfer(t *testing.T, auth settlement.Authorization) (*types.Receipt, error) {
+	t.Helper()
+
+	tx, err := tt.token.TransferWithAuthorization(tt.opts, auth.From, auth.To, auth.Value,
+		auth.ValidAfter, auth.ValidBefore, auth.Nonce, auth.Signature)
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := tt.backend.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		t.Fatalf("receipt: %v", err)
+	}
+	return receipt, nil
+}
+
+// balance returns the test token balance of account
+func (tt *testToken) balance(t *testing.T, account common.Address) *big.Int {
+	t.Helper()
+
+	balance, err := tt.erc20.BalanceOf(&bind.CallOpts{}, account)
+	if err != nil {
+		t.Fatalf("balanceOf: %v", err)
+	}
+	return balance
+}
+
+// expectRevert checks that err is a revert with reason
+func expectRevert(t *testing.T, err error, reason string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("transfer succeeded, want revert %q", reason)
+	}
+	if got := settlement.RevertReason(err); got != reason {
+		t.Fatalf("revert reason %q, want %q (error: %v)", got, reason, err)
+	}
+}
+
+func TestTokenMetadata(t *testing.T) {
+	tt := newTestToken(t)
+	opts := &bind.CallOpts{}
+
+	name, err := tt.erc20.Name(opts)
+	if err != nil || name != TokenName {
+		t.Fatalf("name %q (%v), want %q", name, err, TokenName)
+	}
+	decimals, err := tt.erc20.Decimals(opts)
+	if err != nil || int(decimals) != TokenDecimals {
+		t.Fatalf("decimals %d (%v), want %d", decimals, err, TokenDecimals)
+	}
+	for _, payer := range Payers() {
+		if balance := tt.balance(t, payer.Address); balance.Cmp(PayerTokenBalance) != 0 {
+			t.Fatalf("payer %s balance %s, want %s", payer.Address.Hex(), balance, PayerTokenBalance)
+		}
+	}
+}
+
+func TestTransferWithAuthorization(t *testing.T) {
+	tt := newTestToken(t)
+	payer := Payers()[0]
+	auth := signAuthorization(t, payer, 1_500_000, time.Now().Add(time.Hour), common.HexToHash("0x01"))
+
+	receipt, err := tt.transfer(t, auth)
+	if err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("receipt status %d, want success", receipt.Status)
+	}
+
+	want := new(big.Int).Sub(PayerTokenBalance, auth.Value)
+	if balance := tt.balance(t, payer.Address); balance.Cmp(want) != 0 {
+		t.Fatalf("payer balance %s, want %s", balance, want)
+	}
+	if balance := tt.balance(t, testPayTo); balance.Cmp(auth.Value) != 0 {
+		t.Fatalf("payTo balance %s, want %s", balance, auth.Value)
+	}
+
+	used, err := tt.token.AuthorizationState(&bind.CallOpts{}, payer.Address, auth.Nonce)
+	if err != nil || !used {
+		t.Fatalf("authorizationState %v (%v), want used", used, err)
+	}
+}
+
+func TestTransferWithAuthorizationEvents(t *testing.T) {
+	tt := newTestToken(t)
+	payer := Payers()[1]
+	auth := signAuthorization(t, payer, 42, time.Now().Add(time.Hour), common.HexToHash("0x02"))
+
+	receipt, err := tt.transfer(t, auth)
+	if err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	if len(receipt.Logs) != 2 {
+		t.Fatalf("%d logs, want AuthorizationUsed and Transfer", len(receipt.Logs))
+	}
+
+	authorizationUsed := receipt.Logs[0]
+	if authorizationUsed.Address != TokenAddress || len(authorizationUsed.Topics) != 3 ||
+		authorizationUsed.Topics[0] != common.BytesToHash(authorizationUsedTopic) ||
+		common.BytesToAddress(authorizationUsed.Topics[1].Bytes()) != payer.Address ||
+		authorizationUsed.Topics[2] != auth.Nonce {
+		t.Fatalf("unexpected AuthorizationUsed log %+v", authorizationUsed)
+	}
+
+	transfer := receipt.Logs[1]
+	if transfer.Address != TokenAddress || len(transfer.Topics) != 3 ||
+		transfer.Topics[0] != common.BytesToHash(transferTopic) ||
+		common.BytesToAddress(transfer.Topics[1].Bytes()) != payer.Address ||
+		common.BytesToAddress(transfer.Topics[2].Bytes()) != testPayTo ||
+		new(big.Int).SetBytes(transfer.Data).Cmp(auth.Value) != 0 {
+		t.Fatalf("unexpected Transfer log %+v", transfer)
+	}
+
+	if err := settlement.VerifyReceiptEvents(receipt, TokenAddress, auth); err != nil {
+		t.Fatalf("VerifyReceiptEvents: %v", err)
+	}
+}
+
+func TestTransferWithAuthorizationRejectsBadSignature(t *testing.T) {
+	tt := newTestToken(t)
+	payer := Payers()[2]
+	auth := signAuthorization(t, payer, 1_000, time.Now().Add(time.Hour), common.HexToHash("0x03"))
+	auth.Value = big.NewInt(2_000)
+
+	_, err := tt.transfer(t, auth)
+	expectRevert(t, err, "FiatTokenV2: invalid signature")
+
+	impostor := signAuthorization(t, Payers()[3], 1_000, time.Now().Add(time.Hour), common.HexToHash("0x03"))
+	impostor.From = payer.Address
+	_, err = tt.transfer(t, impostor)
+	expectRevert(t, err, "FiatTokenV2: invalid signature")
+
+	if balance := tt.balance(t, payer.Address); balance.Cmp(PayerTokenBalance) != 0 {
+		t.Fatalf("payer balance %s, want %s", balance, PayerTokenBalance)
+	}
+}
+
+func TestTransferWithAuthorizationRejectsExpired(t *testing.T) {
+	tt := newTestToken(t)
+	auth := signAuthorization(t, Payers()[0], 1_000, time.Now().Add(-time.Minute), common.HexToHash("0x04"))
+
+	_, err := tt.transfer(t, auth)
+	expectRevert(t, err, "FiatTokenV2: authorization is expired")
+}
+
+func TestTransferWithAuthorizationRejectsReuse(t *testing.T) {
+	tt := newTestToken(t)
+	auth := signAuthorization(t, Payers()[0], 1_000, time.Now().Add(time.Hour), common.HexToHash("0x05"))
+
+	if _, err := tt.transfer(t, auth); err != nil {
+		t.Fatalf("first transfer: %v", err)
+	}
+	_, err := tt.transfer(t, auth)
+	expectRevert(t, err, "FiatTokenV2: authorization is used or canceled")
+
+	if balance := tt.balance(t, testPayTo); balance.Cmp(auth.Value) != 0 {
+		t.Fatalf("payTo balance %s, want a single transfer of %s", balance, auth.Value)
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"time"
+	"x402-facilitator-go/internal/middleware"
+	"x402-facilitator-go/internal/service"
+	"x402-facilitator-go/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// LedgerHandler handles settlement ledger queries
+type LedgerHandler struct {
+	ledgerService *service.LedgerService
+	logger        *zap.Logger
+}
+
+// NewLedgerHandler creates a new LedgerHandler
+func NewLedgerHandler(ledgerService *service.LedgerService, logger *zap.Logger) *LedgerHandler {
+	return &LedgerHandler{
+		ledgerService: ledgerService,
+		logger:        logger,
+	}
+}
+
+// Query handles GET /settlements requests
+// Supported query parameters: kind, network, payer, payTo, asset, nonce, txHash, status,
+// since and until (RFC 3339) and limit
+func (h *LedgerHandler) Query(c *gin.Context) {
+	requestLogger := middleware.GetRequestLogger(c, h.logger)
+
+	filter := storage.Filter{
+		Kind:    storage.Kind(c.Query("kind")),
+		Network: c.Query("network"),
+		Payer:   c.Query("payer"),
+		PayTo:   c.Query("payTo"),
+		Asset:   c.Query("asset"),
+		Nonce:   c.Query("nonce"),
+		TxHash:  c.Query("txHash"),
+		Status:  storage.Status(c.Query("status")),
+	}
+
+	var err error
+	if filter.Since, err = parseTimeQuery(c, "since"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since parameter", "details": err.Error()})
+		return
+	}
+	if filter.Until, err = parseTimeQuery(c, "until"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid until parameter", "details": err.Error()})
+		return
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter", "details": err.Error()})
+			return
+		}
+	}
+
+	records, err := h.ledgerService.Query(c.Request.Context(), filter)
+	if err != nil {
+		requestLogger.Error("Failed to query ledger", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query ledger"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"records": records})
+}
+
+// Get handles GET /settlements/:id requests
+func (h *LedgerHandler) Get(c *gin.Context) {
+	requestLogger := middleware.GetRequestLogger(c, h.logger)
+
+	record, err := h.ledgerService.Get(c.Request.Context(), c.Param("id"))
+	if stderrors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+		return
+	}
+	if err != nil {
+		requestLogger.Error("Failed to read ledger record", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read ledger record"})
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// Screenings handles GET /screenings requests
+// Supported query parameters: address, decision, network, since and until (RFC 3339) and limit
+func (h *LedgerHandler) Screenings(c *gin.Context) {
+	requestLogger := middleware.GetRequestLogger(c, h.logger)
+
+	filter := storage.ScreeningFilter{
+		Address:  c.Query("address"),
+		Decision: storage.ScreeningDecision(c.Query("decision")),
+		Network:  c.Query("network"),
+	}
+
+	var err error
+	if filter.Since, err = parseTimeQuery(c, "since"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since parameter", "details": err.Error()})
+		return
+	}
+	if filter.Until, err = parseTimeQuery(c, "until"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid until parameter", "details": err.Error()})
+		return
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter", "details": err.Error()})
+			return
+		}
+	}
+
+	records, err := h.ledgerService.QueryScreenings(c.Request.Context(), filter)
+	if err != nil {
+		requestLogger.Error("Failed to query screening log", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query screening log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"records": records})
+}
+
+// parseTimeQuery parses an optional RFC 3339 query parameter
+func parseTimeQuery(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
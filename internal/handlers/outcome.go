@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"x402-facilitator-go/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeOutcome writes a verification or settlement outcome
+// An outcome failed on the facilitator's side is sent as 503 Service Unavailable with Retry-After, so that clients
+// retry the payment instead of rejecting it, any other outcome as 200 OK
+func writeOutcome(c *gin.Context, reason errors.X402Error, retryAfter time.Duration, response interface{}) {
+	if reason.FacilitatorSide() {
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
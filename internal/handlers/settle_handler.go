@@ -1,25 +1,35 @@
 package handlers
 
 import (
+	stderrors "errors"
 	"net/http"
+	"time"
 	"x402-facilitator-go/internal/middleware"
 	"x402-facilitator-go/internal/models"
 	"x402-facilitator-go/internal/service"
+	"x402-facilitator-go/internal/storage"
+	"x402-facilitator-go/pkg/errors"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// IdempotencyKeyHeader is the optional request header that makes /settle retries idempotent
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 // SettleHandler handles settlement requests
 type SettleHandler struct {
 	settleService *service.SettleService
+	retryAfter    time.Duration
 	logger        *zap.Logger
 }
 
 // NewSettleHandler creates a new SettleHandler
-func NewSettleHandler(settleService *service.SettleService, logger *zap.Logger) *SettleHandler {
+// retryAfter is the Retry-After of responses failed on the facilitator's side
+func NewSettleHandler(settleService *service.SettleService, retryAfter time.Duration, logger *zap.Logger) *SettleHandler {
 	return &SettleHandler{
 		settleService: settleService,
+		retryAfter:    retryAfter,
 		logger:        logger,
 	}
 }
@@ -40,9 +50,37 @@ func (h *SettleHandler) Settle(c *gin.Context) {
 		return
 	}
 
-	// Call the settlement service
+	// Call the settlement service, retries carrying the same Idempotency-Key get the original outcome
 	ctx := c.Request.Context()
-	response := h.settleService.Settle(ctx, &request)
+	response := h.settleService.Settle(ctx, &request, c.GetHeader(IdempotencyKeyHeader))
+
+	// A payment queued for deferred settlement, or broadcast but not yet mined, is accepted but not settled yet
+	if response.Status == models.SettleStatusPending {
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+	// Once a transaction was broadcast the outcome is the transaction's, retrying the payment later cannot change it
+	if response.Transaction != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	writeOutcome(c, errors.X402Error(response.ErrorReason), h.retryAfter, response)
+}
+
+// Status handles GET /settle/status/:id requests for deferred settlements
+func (h *SettleHandler) Status(c *gin.Context) {
+	requestLogger := middleware.GetRequestLogger(c, h.logger)
+
+	response, err := h.settleService.SettlementStatus(c.Request.Context(), c.Param("id"))
+	if stderrors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Settlement not found"})
+		return
+	}
+	if err != nil {
+		requestLogger.Error("Failed to read deferred settlement", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read deferred settlement"})
+		return
+	}
 
 	c.JSON(http.StatusOK, response)
 }
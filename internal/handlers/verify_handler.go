@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"x402-facilitator-go/internal/middleware"
 	"x402-facilitator-go/internal/models"
@@ -15,13 +16,16 @@ import (
 // VerifyHandler handles verification requests
 type VerifyHandler struct {
 	verifyService *service.VerifyService
+	retryAfter    time.Duration
 	logger        *zap.Logger
 }
 
 // NewVerifyHandler creates a new VerifyHandler
-func NewVerifyHandler(verifyService *service.VerifyService, logger *zap.Logger) *VerifyHandler {
+// retryAfter is the Retry-After of responses failed on the facilitator's side
+func NewVerifyHandler(verifyService *service.VerifyService, retryAfter time.Duration, logger *zap.Logger) *VerifyHandler {
 	return &VerifyHandler{
 		verifyService: verifyService,
+		retryAfter:    retryAfter,
 		logger:        logger,
 	}
 }
@@ -46,5 +50,5 @@ func (h *VerifyHandler) Verify(c *gin.Context) {
 	ctx := c.Request.Context()
 	response := h.verifyService.Verify(ctx, &request)
 
-	c.JSON(http.StatusOK, response)
+	writeOutcome(c, errors.X402Error(response.InvalidReason), h.retryAfter, response)
 }
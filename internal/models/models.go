@@ -1,6 +1,10 @@
 package models
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+	"x402-facilitator-go/pkg/attestation"
+)
 
 // VerifyRequest represents a payment verification request
 type VerifyRequest struct {
@@ -66,6 +70,9 @@ type SettleRequest struct {
 	PaymentRequirements PaymentRequirements `json:"paymentRequirements" binding:"required"`
 }
 
+// SettleStatusPending marks a payment accepted for deferred settlement, or broadcast, but not yet settled on-chain
+const SettleStatusPending = "pending"
+
 // SettleResponse represents a settlement response
 type SettleResponse struct {
 	Success     bool    `json:"success"`
@@ -73,6 +80,32 @@ type SettleResponse struct {
 	Transaction *string `json:"transaction,omitempty"`
 	Network     string  `json:"network"`
 	Payer       string  `json:"payer"`
+	// Retryable reports that a failed settlement may succeed when the same payment is settled again later,
+	// otherwise a new payment authorization is needed
+	Retryable bool `json:"retryable,omitempty"`
+	// Status is SettleStatusPending when the payment was queued for deferred settlement, or when its transaction
+	// was broadcast but its outcome is not known yet
+	Status string `json:"status,omitempty"`
+	// SettlementID identifies a deferred settlement for status lookups
+	SettlementID string `json:"settlementId,omitempty"`
+	// Attestation is the facilitator's signed statement of a successful settlement, when attestations are enabled
+	Attestation *attestation.Attestation `json:"attestation,omitempty"`
+}
+
+// SettlementStatusResponse reports the progress of a deferred settlement
+type SettlementStatusResponse struct {
+	SettlementID string `json:"settlementId"`
+	Status       string `json:"status"`
+	ErrorReason  string `json:"errorReason,omitempty"`
+	// Retryable reports that a failed settlement may succeed when the same payment is settled again later
+	Retryable   bool      `json:"retryable,omitempty"`
+	Transaction *string   `json:"transaction,omitempty"`
+	Network     string    `json:"network"`
+	Payer       string    `json:"payer"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	// Attestation is the facilitator's signed statement of the settlement once it succeeded
+	Attestation *attestation.Attestation `json:"attestation,omitempty"`
 }
 
 // SupportedKind represents a supported payment kind
@@ -85,4 +118,6 @@ type SupportedKind struct {
 // SupportedResponse represents the supported schemes and networks
 type SupportedResponse struct {
 	Kinds []SupportedKind `json:"kinds"`
+	// AttestationSigner is the address settlement attestations are signed by, when attestations are enabled
+	AttestationSigner string `json:"attestationSigner,omitempty"`
 }
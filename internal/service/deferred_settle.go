@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"math/big"
+	"sync"
+	"time"
+	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/settlement"
+	"x402-facilitator-go/internal/storage"
+	"x402-facilitator-go/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// deferredFlushConcurrency caps the number of deferred payments settled at the same time by a flush
+const deferredFlushConcurrency = 16
+
+// deferrable reports whether the payment leaves enough time before its validBefore to be queued
+// Payments too close to expiry are settled inline instead
+func (s *SettleService) deferrable(request *models.SettleRequest) bool {
+	if _, ok := new(big.Int).SetString(request.PaymentPayload.Payload.Authorization.ValidBefore, 10); !ok {
+		// Let verification report the malformed authorization
+		return true
+	}
+	margin := time.Duration(s.deferred.ExpiryMarginSeconds) * time.Second
+	return time.Until(authorizationValidBefore(request)) > margin
+}
+
+// accept verifies a payment and queues it for deferred settlement
+func (s *SettleService) accept(ctx context.Context, request *models.SettleRequest, record *storage.SettlementRecord) *models.SettleResponse {
+	verifyRequest := &models.VerifyRequest{
+		X402Version:         request.X402Version,
+		PaymentPayload:      request.PaymentPayload,
+		PaymentRequirements: request.PaymentRequirements,
+	}
+
+	verifyResponse := s.verifyService.verify(ctx, verifyRequest)
+	if !verifyResponse.IsValid {
+		return &models.SettleResponse{
+			Success:     false,
+			Network:     request.PaymentRequirements.Network,
+			ErrorReason: verifyResponse.InvalidReason,
+			Payer:       verifyResponse.Payer,
+		}
+	}
+	record.Transition(storage.StatusVerified, "")
+	s.exposure.Settling(settlement.NewHold(request.PaymentRequirements, request.PaymentPayload.Payload.Authorization))
+
+	networkStr := request.PaymentRequirements.Network
+	if response := s.checkGas(networkStr, verifyResponse.Payer); response != nil {
+		return response
+	}
+	item := storage.NewDeferredSettlement(record.ID, *request, authorizationValidBefore(request))
+	if err := s.queue.SaveDeferred(ctx, item); err != nil {
+		s.logger.Error("Failed to queue deferred settlement",
+			zap.Error(err),
+			zap.String("network", networkStr),
+			zap.String("payer", verifyResponse.Payer),
+		)
+		return &models.SettleResponse{
+			Success:     false,
+			Network:     networkStr,
+			ErrorReason: errors.ErrorUnexpectedSettle.Code(),
+			Payer:       verifyResponse.Payer,
+		}
+	}
+
+	s.logger.Info("Payment queued for deferred settlement",
+		zap.String("settlementId", item.ID),
+		zap.String("network", networkStr),
+		zap.String("payer", verifyResponse.Payer),
+	)
+
+	// The payment is not settled yet, so the response is not a success
+	return &models.SettleResponse{
+		Success:      false,
+		Network:      networkStr,
+		Payer:        verifyResponse.Payer,
+		Status:       models.SettleStatusPending,
+		SettlementID: item.ID,
+	}
+}
+
+// SettlementStatus returns the progress of a deferred settlement, or storage.ErrNotFound
+func (s *SettleService) SettlementStatus(ctx context.Context, id string) (*models.SettlementStatusResponse, error) {
+	item, err := s.queue.GetDeferred(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.SettlementStatusResponse{
+		SettlementID: item.ID,
+		Status:       string(item.Status),
+		ErrorReason:  item.ErrorReason,
+		Network:      item.Network,
+		Payer:        item.Payer,
+		CreatedAt:    item.CreatedAt,
+		UpdatedAt:    item.UpdatedAt,
+		Attestation:  item.Attestation,
+	}
+	if item.Status == storage.DeferredFailed {
+		response.Retryable = errors.X402Error(item.ErrorReason).Retryable()
+	}
+	if item.TxHash != "" {
+		txHash := item.TxHash
+		response.Transaction = &txHash
+	}
+	return response, nil
+}
+
+// RunDeferred periodically resolves the deferred settlements being settled and flushes the deferred settlement
+// queue until ctx is cancelled
+// It returns immediately when deferred settlement is disabled
+func (s *SettleService) RunDeferred(ctx context.Context) {
+	if !s.deferred.Enabled {
+		return
+	}
+
+	s.holdDeferred(ctx)
+	s.resolveDeferred(ctx)
+
+	ticker := time.NewTicker(time.Duration(s.deferred.CheckIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.resolveDeferred(ctx)
+			s.flushDeferred(ctx)
+		}
+	}
+}
+
+// resolveDeferred finishes the deferred settlements that were broadcast but not mined when their flush ended,
+// including those left settling by a previous run
+// A payment is queued again only when its transaction was dropped and its authorization is still unused on-chain
+func (s *SettleService) resolveDeferred(ctx context.Context) {
+	items, err := s.queue.ListDeferred(ctx, storage.DeferredSettling)
+	if err != nil {
+		s.logger.Error("Failed to list deferred settlements being settled", zap.Error(err))
+		return
+	}
+
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return
+		}
+		if s.flushing(item.Network) {
+			// The flush settling the payment records its outcome
+			continue
+		}
+		s.resolveDeferredItem(ctx, item)
+	}
+}
+
+// resolveDeferredItem looks up the outcome of a deferred settlement being settled and records it once known
+func (s *SettleService) resolveDeferredItem(ctx context.Context, item *storage.DeferredSettlement) {
+	record, err := s.ledger.Get(ctx, item.RecordID)
+	switch {
+	case stderrors.Is(err, storage.ErrNotFound):
+		record = newLedgerRecord(storage.KindSettle, item.Request.PaymentPayload, item.Request.PaymentRequirements)
+		record.ID = item.RecordID
+		record.TxHash = item.TxHash
+	case err != nil:
+		s.logger.Warn("Failed to read ledger record of deferred settlement",
+			zap.Error(err),
+			zap.String("settlementId", item.ID),
+			zap.String("network", item.Network),
+		)
+		return
+	}
+
+	response := s.lookupSettlement(ctx, record, authorizationValidBefore(&item.Request))
+	switch {
+	case response == nil:
+		return
+	case response.ErrorReason == errors.ErrorSettlementDropped.Code():
+		// The payment stays held and reserved, the next flush settles it again
+		record.Transition(storage.StatusQueued, response.ErrorReason)
+		saveLedgerRecord(ctx, s.ledger, record, s.logger)
+		item.Status = storage.DeferredPending
+		item.TxHash = ""
+		item.UpdatedAt = time.Now().UTC()
+		s.saveDeferred(ctx, item)
+	default:
+		s.finishDeferred(ctx, item, record, response)
+	}
+
+	s.logger.Info("Resolved deferred settlement",
+		zap.String("settlementId", item.ID),
+		zap.String("status", string(item.Status)),
+		zap.String("errorReason", response.ErrorReason),
+		zap.String("network", item.Network),
+		zap.String("payer", item.Payer),
+	)
+}
+
+// holdDeferred counts the payments queued or being settled by a previous run against their payers' balances and
+// keeps them reserved
+func (s *SettleService) holdDeferred(ctx context.Context) {
+	for _, status := range []storage.DeferredStatus{storage.DeferredPending, storage.DeferredSettling} {
+		items, err := s.queue.ListDeferred(ctx, status)
+		if err != nil {
+			s.logger.Error("Failed to list deferred settlements", zap.Error(err), zap.String("status", string(status)))
+			return
+		}
+
+		for _, item := range items {
+			s.exposure.Settling(settlement.NewHold(item.Request.PaymentRequirements, item.Request.PaymentPayload.Payload.Authorization))
+			if s.reservations != nil {
+				authorizationKey, owner := reservationOf(&item.Request)
+				s.reservations.Extend(authorizationKey, owner, authorizationValidBefore(&item.Request))
+			}
+		}
+	}
+}
+
+// flushDeferred settles the queued payments of every network that reached a flush threshold
+// Networks are flushed concurrently, so that a slow network does not hold back the others, and a network is not
+// flushed again while its previous flush is still settling
+func (s *SettleService) flushDeferred(ctx context.Context) {
+	items, err := s.queue.ListDeferred(ctx, storage.DeferredPending)
+	if err != nil {
+		s.logger.Error("Failed to list deferred settlements", zap.Error(err))
+		return
+	}
+
+	byNetwork := make(map[string][]*storage.DeferredSettlement)
+	for _, item := range items {
+		byNetwork[item.Network] = append(byNetwork[item.Network], item)
+	}
+
+	for networkStr, networkItems := range byNetwork {
+		reason := s.flushReason(networkItems)
+		if reason == "" || s.flushing(networkStr) {
+			continue
+		}
+		if !s.web3Client.Available(networkStr) {
+			// Keep the payments queued until the network reconnects
+			s.logger.Warn("Deferred settlements held back, network unavailable",
+				zap.String("network", networkStr),
+				zap.String("reason", reason),
+				zap.Int("count", len(networkItems)),
+			)
+			continue
+		}
+		if !s.gasMonitor.Funded(networkStr) {
+			// Keep the payments queued until the settlement signer is funded again
+			s.logger.Warn("Deferred settlements held back, facilitator gas balance below threshold",
+				zap.String("network", networkStr),
+				zap.String("reason", reason),
+				zap.Int("count", len(networkItems)),
+			)
+			continue
+		}
+
+		s.logger.Info("Flushing deferred settlements",
+			zap.String("network", networkStr),
+			zap.String("reason", reason),
+			zap.Int("count", len(networkItems)),
+		)
+		s.startFlush(networkStr)
+		go func(networkStr string, networkItems []*storage.DeferredSettlement) {
+			defer s.finishFlush(networkStr)
+			s.settleDeferred(ctx, networkItems)
+		}(networkStr, networkItems)
+	}
+}
+
+// flushing reports whether a flush of the network is still settling
+func (s *SettleService) flushing(networkStr string) bool {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	return s.flushes[networkStr]
+}
+
+// startFlush marks a flush of the network as settling
+func (s *SettleService) startFlush(networkStr string) {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.flushes[networkStr] = true
+}
+
+// finishFlush marks the flush of the network as done
+func (s *SettleService) finishFlush(networkStr string) {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	delete(s.flushes, networkStr)
+}
+
+// flushReason returns why a network's queued payments must be settled now, or "" if they can wait
+func (s *SettleService) flushReason(items []*storage.DeferredSettlement) string {
+	if len(items) >= s.deferred.MaxPendingCount {
+		return "count"
+	}
+
+	now := time.Now()
+	margin := time.Duration(s.deferred.ExpiryMarginSeconds) * time.Second
+	maxDelay := time.Duration(s.deferred.MaxDelaySeconds) * time.Second
+	maxValue, _ := new(big.Int).SetString(s.deferred.MaxPendingValue, 10)
+
+	total := new(big.Int)
+	for _, item := range items {
+		if !authorizationValidBefore(&item.Request).After(now.Add(margin)) {
+			return "expiry"
+		}
+		if now.Sub(item.CreatedAt) >= maxDelay {
+			return "delay"
+		}
+		if value, ok := new(big.Int).SetString(item.Value, 10); ok {
+			total.Add(total, value)
+		}
+	}
+	if maxValue != nil && total.Cmp(maxValue) >= 0 {
+		return "value"
+	}
+	return ""
+}
+
+// settleDeferred settles queued payments concurrently, so that a configured batcher can group them
+func (s *SettleService) settleDeferred(ctx context.Context, items []*storage.DeferredSettlement) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, deferredFlushConcurrency)
+
+	for _, item := range items {
+		item.Status = storage.DeferredSettling
+		item.UpdatedAt = time.Now().UTC()
+		s.saveDeferred(ctx, item)
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(item *storage.DeferredSettlement) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			s.settleDeferredItem(ctx, item)
+		}(item)
+	}
+	wg.Wait()
+}
+
+// settleDeferredItem settles one queued payment and records its outcome
+func (s *SettleService) settleDeferredItem(ctx context.Context, item *storage.DeferredSettlement) {
+	// Settlement outlives shutdown of the scheduler so that broadcast transactions are tracked to the end
+	ctx = context.WithoutCancel(ctx)
+
+	record, err := s.ledger.Get(ctx, item.RecordID)
+	if err != nil {
+		s.logger.Warn("Ledger record of deferred settlement not found, creating a new one",
+			zap.Error(err),
+			zap.String("settlementId", item.ID),
+			zap.String("network", item.Network),
+			zap.String("payer", item.Payer),
+		)
+		record = newLedgerRecord(storage.KindSettle, item.Request.PaymentPayload, item.Request.PaymentRequirements)
+	}
+
+	response := s.settle(ctx, &item.Request, record, nil)
+	s.finishDeferred(ctx, item, record, response)
+}
+
+// finishDeferred records the outcome of a deferred settlement, a settlement broadcast but not mined yet stays
+// settling until resolveDeferred finds its outcome
+func (s *SettleService) finishDeferred(ctx context.Context, item *storage.DeferredSettlement, record *storage.SettlementRecord, response *models.SettleResponse) {
+	s.attest(&item.Request, response)
+	s.finishRecord(ctx, record, response)
+	// The payment's hold was moved to settling when it was accepted
+	s.releaseExposure(&item.Request, response)
+	s.consumeReservation(&item.Request, response)
+	response.Retryable = retryable(response)
+	if response.Status != models.SettleStatusPending {
+		// Repeats of the payment got the queued response until now
+		s.idempotency.Resolve(authorizationKeyOf(&item.Request), response, outcomeOf(response))
+	}
+
+	switch {
+	case response.Success:
+		item.Status = storage.DeferredSettled
+	case response.Status == models.SettleStatusPending:
+		// Broadcast but not yet mined, resolveDeferred looks up its outcome
+		item.Status = storage.DeferredSettling
+	default:
+		item.Status = storage.DeferredFailed
+	}
+	item.ErrorReason = response.ErrorReason
+	if response.Transaction != nil {
+		item.TxHash = *response.Transaction
+	}
+	item.Attestation = response.Attestation
+	item.UpdatedAt = time.Now().UTC()
+	s.saveDeferred(ctx, item)
+}
+
+// saveDeferred stores a deferred settlement, logging failures
+func (s *SettleService) saveDeferred(ctx context.Context, item *storage.DeferredSettlement) {
+	if err := s.queue.SaveDeferred(context.WithoutCancel(ctx), item); err != nil {
+		s.logger.Error("Failed to save deferred settlement",
+			zap.Error(err),
+			zap.String("settlementId", item.ID),
+			zap.String("status", string(item.Status)),
+			zap.String("network", item.Network),
+			zap.String("payer", item.Payer),
+		)
+	}
+}
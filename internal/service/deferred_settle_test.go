@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+	"x402-facilitator-go/internal/devchain"
+	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/storage"
+	"x402-facilitator-go/pkg/errors"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// settlingItem queues request as a deferred settlement being settled under record, broadcast in txHash
+func (f *flow) settlingItem(t *testing.T, request *models.SettleRequest, record *storage.SettlementRecord, txHash string) *storage.DeferredSettlement {
+	t.Helper()
+
+	record.Status = storage.StatusSubmitted
+	record.TxHash = txHash
+	record.TxHashes = []string{txHash}
+	if err := f.ledger.Save(context.Background(), record); err != nil {
+		t.Fatalf("save record: %v", err)
+	}
+	item := storage.NewDeferredSettlement(record.ID, *request, authorizationValidBefore(request))
+	item.Status = storage.DeferredSettling
+	item.TxHash = txHash
+	if err := f.ledger.SaveDeferred(context.Background(), item); err != nil {
+		t.Fatalf("save deferred settlement: %v", err)
+	}
+	return item
+}
+
+func TestResolveDeferredSettlingItems(t *testing.T) {
+	f := newFlow(t)
+	ctx := context.Background()
+	payer := devchain.Payers()[2]
+
+	// A settlement mined after its flush ended
+	minedRequest := paymentRequest(t, payer, 1_000, common.HexToHash("0x21"))
+	response := f.settle.Settle(ctx, minedRequest, "")
+	if !response.Success {
+		t.Fatalf("settle: failed with %s", response.ErrorReason)
+	}
+	records, err := f.ledger.Query(ctx, storage.Filter{TxHash: *response.Transaction})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("query ledger: %v, %d records", err, len(records))
+	}
+	mined := f.settlingItem(t, minedRequest, records[0], *response.Transaction)
+
+	// A settlement whose transaction the node no longer knows, with its authorization unused
+	droppedRequest := paymentRequest(t, payer, 1_000, common.HexToHash("0x22"))
+	droppedRecord := newLedgerRecord(storage.KindSettle, droppedRequest.PaymentPayload, droppedRequest.PaymentRequirements)
+	dropped := f.settlingItem(t, droppedRequest, droppedRecord, common.HexToHash("0xdead").Hex())
+
+	f.settle.resolveDeferred(ctx)
+
+	for _, tc := range []struct {
+		name         string
+		item         *storage.DeferredSettlement
+		wantStatus   storage.DeferredStatus
+		wantTx       bool
+		wantRecorded storage.Status
+	}{
+		{"mined settlement is settled", mined, storage.DeferredSettled, true, storage.StatusConfirmed},
+		{"dropped settlement is queued again", dropped, storage.DeferredPending, false, storage.StatusQueued},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			item, err := f.ledger.GetDeferred(ctx, tc.item.ID)
+			if err != nil {
+				t.Fatalf("get deferred settlement: %v", err)
+			}
+			if item.Status != tc.wantStatus || (item.TxHash != "") != tc.wantTx {
+				t.Fatalf("deferred settlement %+v, want %s with transaction %v", item, tc.wantStatus, tc.wantTx)
+			}
+			record, err := f.ledger.Get(ctx, item.RecordID)
+			if err != nil {
+				t.Fatalf("get record: %v", err)
+			}
+			if record.Status != tc.wantRecorded {
+				t.Fatalf("record status %s, want %s", record.Status, tc.wantRecorded)
+			}
+		})
+	}
+	if item, _ := f.ledger.GetDeferred(ctx, dropped.ID); item.ErrorReason != "" {
+		t.Fatalf("queued again with error reason %s", item.ErrorReason)
+	}
+	if records, _ := f.ledger.Query(ctx, storage.Filter{Nonce: droppedRecord.Nonce}); records[0].ErrorReason != errors.ErrorSettlementDropped.Code() {
+		t.Fatalf("record error reason %s, want %s", records[0].ErrorReason, errors.ErrorSettlementDropped.Code())
+	}
+}
+
+func TestDeferredFarFutureValidBefore(t *testing.T) {
+	f := newFlow(t)
+	request := paymentRequest(t, devchain.Payers()[0], 1_000, common.HexToHash("0x23"))
+	// Beyond int64, as some clients sign authorizations that never expire
+	request.PaymentPayload.Payload.Authorization.ValidBefore = "115792089237316195423570985008687907853269984665640564039457584007913129639935"
+
+	if !f.settle.deferrable(request) {
+		t.Fatal("want a payment that never expires deferrable")
+	}
+	item := storage.NewDeferredSettlement("record", *request, authorizationValidBefore(request))
+	if !time.Unix(item.ValidBefore, 0).After(time.Now().AddDate(100, 0, 0)) {
+		t.Fatalf("validBefore stored as %d, want it clamped to the far future", item.ValidBefore)
+	}
+	if reason := f.settle.flushReason([]*storage.DeferredSettlement{item}); reason != "" {
+		t.Fatalf("flushed for %q, want the payment kept queued", reason)
+	}
+}
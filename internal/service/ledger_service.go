@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// newLedgerRecord builds a ledger record describing the payment of a request
+func newLedgerRecord(kind storage.Kind, payload models.PaymentPayload, requirements models.PaymentRequirements) *storage.SettlementRecord {
+	authorization := payload.Payload.Authorization
+
+	record := storage.NewSettlementRecord(kind)
+	record.Fingerprint = requestFingerprint(payload, requirements)
+	record.Network = requirements.Network
+	record.Payer = authorization.From
+	record.PayTo = requirements.PayTo
+	record.Asset = requirements.Asset
+	record.Amount = authorization.Value
+	record.Nonce = authorization.Nonce
+	record.Resource = requirements.Resource
+	return record
+}
+
+// requestFingerprint hashes the payment payload and requirements of a request
+func requestFingerprint(payload models.PaymentPayload, requirements models.PaymentRequirements) string {
+	data, _ := json.Marshal(struct {
+		PaymentPayload      models.PaymentPayload      `json:"paymentPayload"`
+		PaymentRequirements models.PaymentRequirements `json:"paymentRequirements"`
+	}{payload, requirements})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// saveLedgerRecord persists a record, ledger failures are logged and never fail the payment
+func saveLedgerRecord(ctx context.Context, ledger storage.Ledger, record *storage.SettlementRecord, logger *zap.Logger) {
+	if err := ledger.Save(ctx, record); err != nil {
+		logger.Error("Failed to save ledger record",
+			zap.Error(err),
+			zap.String("recordId", record.ID),
+			zap.String("status", string(record.Status)),
+			zap.String("network", record.Network),
+			zap.String("payer", record.Payer),
+		)
+	}
+}
+
+// LedgerService answers queries against the settlement ledger and the compliance screening log
+type LedgerService struct {
+	ledger     storage.Ledger
+	screenings storage.ScreeningLog
+}
+
+// NewLedgerService creates a new LedgerService
+func NewLedgerService(ledger storage.Ledger, screenings storage.ScreeningLog) *LedgerService {
+	return &LedgerService{
+		ledger:     ledger,
+		screenings: screenings,
+	}
+}
+
+// Query returns the ledger records matching the filter, newest first
+func (s *LedgerService) Query(ctx context.Context, filter storage.Filter) ([]*storage.SettlementRecord, error) {
+	return s.ledger.Query(ctx, filter)
+}
+
+// Get returns a single ledger record
+func (s *LedgerService) Get(ctx context.Context, id string) (*storage.SettlementRecord, error) {
+	return s.ledger.Get(ctx, id)
+}
+
+// QueryScreenings returns the compliance screening records matching the filter, newest first
+func (s *LedgerService) QueryScreenings(ctx context.Context, filter storage.ScreeningFilter) ([]*storage.ScreeningRecord, error) {
+	return s.screenings.QueryScreenings(ctx, filter)
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"math/big"
+	"time"
+	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/settlement"
+	"x402-facilitator-go/internal/storage"
+	"x402-facilitator-go/internal/web3"
+	"x402-facilitator-go/internal/web3/contract"
+	"x402-facilitator-go/pkg/errors"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.uber.org/zap"
+)
+
+// unresolvedSettlement is a broadcast settlement whose tracking stopped before its outcome was known
+type unresolvedSettlement struct {
+	record *storage.SettlementRecord
+	// request is the settled payment, nil for a settlement left submitted by a previous run
+	request *models.SettleRequest
+}
+
+// handOff leaves a broadcast settlement whose outcome is not known yet to the resolver
+func (s *SettleService) handOff(record *storage.SettlementRecord, request *models.SettleRequest) {
+	s.resolveMu.Lock()
+	defer s.resolveMu.Unlock()
+
+	s.unresolved[record.ID] = &unresolvedSettlement{record: record, request: request}
+}
+
+// RunResolver periodically looks up the outcome of the settlements handed off by their tracker until ctx is
+// cancelled, settlements left submitted by a previous run are picked up from the ledger first
+func (s *SettleService) RunResolver(ctx context.Context) {
+	s.recoverSubmitted(ctx)
+
+	ticker := time.NewTicker(s.resolveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.resolveHandedOff(ctx)
+		}
+	}
+}
+
+// recoverSubmitted hands off the settlements a previous run left submitted
+// Deferred settlements are left to resolveDeferred, which also updates their queue item
+func (s *SettleService) recoverSubmitted(ctx context.Context) {
+	deferred := make(map[string]bool)
+	if s.deferred.Enabled {
+		items, err := s.queue.ListDeferred(ctx, storage.DeferredSettling)
+		if err != nil {
+			s.logger.Error("Failed to list deferred settlements being settled", zap.Error(err))
+			return
+		}
+		for _, item := range items {
+			deferred[item.RecordID] = true
+		}
+	}
+
+	filter := storage.Filter{Kind: storage.KindSettle, Status: storage.StatusSubmitted}
+	recovered := 0
+	for {
+		records, err := s.ledger.Query(ctx, filter)
+		if err != nil {
+			s.logger.Error("Failed to list submitted settlements", zap.Error(err))
+			return
+		}
+		for _, record := range records {
+			if deferred[record.ID] {
+				continue
+			}
+			s.handOff(record, nil)
+			recovered++
+		}
+		if len(records) < storage.DefaultQueryLimit {
+			break
+		}
+		// Records are returned newest first, the next page ends before the oldest one
+		filter.Until = records[len(records)-1].CreatedAt.Add(-time.Nanosecond)
+	}
+
+	if recovered > 0 {
+		s.logger.Warn("Recovered submitted settlements of a previous run", zap.Int("count", recovered))
+	}
+}
+
+// resolveHandedOff finishes the handed-off settlements whose outcome is now known on-chain
+func (s *SettleService) resolveHandedOff(ctx context.Context) {
+	s.resolveMu.Lock()
+	unresolved := make([]*unresolvedSettlement, 0, len(s.unresolved))
+	for _, pending := range s.unresolved {
+		unresolved = append(unresolved, pending)
+	}
+	s.resolveMu.Unlock()
+
+	for _, pending := range unresolved {
+		if ctx.Err() != nil {
+			return
+		}
+
+		var validBefore time.Time
+		if pending.request != nil {
+			validBefore = authorizationValidBefore(pending.request)
+		}
+		response := s.lookupSettlement(ctx, pending.record, validBefore)
+		if response == nil {
+			continue
+		}
+
+		if pending.request != nil {
+			s.attest(pending.request, response)
+		}
+		s.finishRecord(ctx, pending.record, response)
+		if pending.request != nil {
+			// The attempt handing the settlement off had moved its hold to settling
+			s.releaseExposure(pending.request, response)
+			s.consumeReservation(pending.request, response)
+			response.Retryable = retryable(response)
+			s.idempotency.Resolve(authorizationKeyOf(pending.request), response, outcomeOf(response))
+		}
+
+		s.resolveMu.Lock()
+		delete(s.unresolved, pending.record.ID)
+		s.resolveMu.Unlock()
+
+		s.logger.Info("Resolved settlement handed off by its tracker",
+			zap.String("recordId", pending.record.ID),
+			zap.String("status", string(pending.record.Status)),
+			zap.String("errorReason", response.ErrorReason),
+			zap.String("txHash", pending.record.TxHash),
+			zap.String("network", pending.record.Network),
+			zap.String("payer", pending.record.Payer),
+		)
+	}
+}
+
+// lookupSettlement returns the outcome of the settlement broadcast in record once it is known on-chain, or nil
+// while it is not. A settlement is known to be dropped only when no broadcast is known to the node any more and
+// the authorization is still unused, so that settling the payment again cannot transfer it twice, a record
+// without any broadcast is dropped as soon as its authorization is found unused
+// validBefore is the authorization's expiry, zero when it is not known
+func (s *SettleService) lookupSettlement(ctx context.Context, record *storage.SettlementRecord, validBefore time.Time) *models.SettleResponse {
+	client, err := s.web3Client.GetClient(record.Network)
+	if err != nil {
+		return nil
+	}
+
+	hashes := make([]common.Hash, 0, len(record.TxHashes)+1)
+	for _, txHash := range record.TxHashes {
+		hashes = append(hashes, common.HexToHash(txHash))
+	}
+	if len(hashes) == 0 && record.TxHash != "" {
+		hashes = append(hashes, common.HexToHash(record.TxHash))
+	}
+	if len(hashes) > 0 {
+		receipts, err := client.TransactionReceipts(ctx, hashes)
+		if err != nil {
+			s.logger.Debug("Failed to read receipts of pending settlement",
+				zap.Error(err),
+				zap.String("recordId", record.ID),
+				zap.String("network", record.Network),
+			)
+			return nil
+		}
+		for _, receipt := range receipts {
+			if receipt != nil {
+				return s.minedOutcome(ctx, client, record, receipt)
+			}
+		}
+	}
+
+	if !validBefore.IsZero() && !time.Now().Before(validBefore) {
+		response := &models.SettleResponse{
+			Success:     false,
+			Network:     record.Network,
+			ErrorReason: errors.ErrorInvalidExactEVMPayloadAuthorizationValidBefore.Code(),
+			Payer:       record.Payer,
+		}
+		if record.TxHash != "" {
+			txHash := record.TxHash
+			response.Transaction = &txHash
+		}
+		return response
+	}
+
+	for _, hash := range hashes {
+		if _, _, err := client.TransactionByHash(ctx, hash); !stderrors.Is(err, ethereum.NotFound) {
+			// Still known to the node, or its state could not be read
+			return nil
+		}
+	}
+	token, err := contract.NewEIP3009TokenCaller(common.HexToAddress(record.Asset), client)
+	if err != nil {
+		return nil
+	}
+	used, err := token.AuthorizationState(&bind.CallOpts{Context: ctx}, common.HexToAddress(record.Payer), common.HexToHash(record.Nonce))
+	if err != nil {
+		return nil
+	}
+
+	errorReason := errors.ErrorSettlementDropped
+	if used {
+		// None of the broadcasts was mined, another transaction used the authorization
+		errorReason = errors.ErrorAuthorizationUsed
+	}
+	s.logger.Warn("Settlement transaction no longer known to the node",
+		zap.String("recordId", record.ID),
+		zap.Strings("txHashes", record.TxHashes),
+		zap.Bool("authorizationUsed", used),
+		zap.String("network", record.Network),
+		zap.String("payer", record.Payer),
+	)
+	return &models.SettleResponse{
+		Success:     false,
+		Network:     record.Network,
+		ErrorReason: errorReason.Code(),
+		Payer:       record.Payer,
+	}
+}
+
+// minedOutcome returns the outcome of a settlement mined in receipt once it has the network's confirmations, or nil
+func (s *SettleService) minedOutcome(ctx context.Context, client web3.Backend, record *storage.SettlementRecord, receipt *types.Receipt) *models.SettleResponse {
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return nil
+	}
+	required, _ := s.web3Client.GetConfirmations(record.Network)
+	confirmations := uint64(1)
+	if block := receipt.BlockNumber.Uint64(); head > block {
+		confirmations = head - block + 1
+	}
+	if confirmations < required {
+		return nil
+	}
+
+	txHash := receipt.TxHash.Hex()
+	record.TxHash = txHash
+	record.BlockNumber = receipt.BlockNumber.Uint64()
+	record.GasUsed = receipt.GasUsed
+
+	value, _ := new(big.Int).SetString(record.Amount, 10)
+	if value == nil {
+		value = new(big.Int)
+	}
+	asset := common.HexToAddress(record.Asset)
+	authorization := settlement.Authorization{
+		From:  common.HexToAddress(record.Payer),
+		To:    common.HexToAddress(record.PayTo),
+		Value: value,
+		Nonce: common.HexToHash(record.Nonce),
+	}
+
+	errorReason := errors.X402Error("")
+	switch {
+	case receipt.Status != types.ReceiptStatusSuccessful:
+		revertReason := ""
+		if tx, _, err := client.TransactionByHash(ctx, receipt.TxHash); err == nil {
+			revertReason = settlement.ReplayRevertReason(ctx, client, s.facilitatorAddress(), tx, receipt.BlockNumber)
+		}
+		errorReason = errors.FromRevertReason(revertReason)
+	case !settlement.AuthorizationUsed(receipt, asset, authorization):
+		// A batch lets sub-calls fail, one that reverted leaves no AuthorizationUsed event
+		errorReason = errors.ErrorInvalidTransactionState
+	case settlement.VerifyReceiptEvents(receipt, asset, authorization) != nil:
+		errorReason = errors.ErrorSettlementEventMismatch
+	}
+	if errorReason != "" {
+		return &models.SettleResponse{
+			Success:     false,
+			Network:     record.Network,
+			ErrorReason: errorReason.Code(),
+			Transaction: &txHash,
+			Payer:       record.Payer,
+		}
+	}
+	return s.confirmed(record.Network, record.Payer, receipt, confirmations)
+}
+
+// facilitatorAddress returns the address of the settlement signer, the zero address when its key is invalid
+func (s *SettleService) facilitatorAddress() common.Address {
+	key, err := crypto.HexToECDSA(s.privateKey)
+	if err != nil {
+		return common.Address{}
+	}
+	return crypto.PubkeyToAddress(key.PublicKey)
+}
+
+// authorizationValidBefore returns when the payment's authorization expires
+func authorizationValidBefore(request *models.SettleRequest) time.Time {
+	validBefore, _ := new(big.Int).SetString(request.PaymentPayload.Payload.Authorization.ValidBefore, 10)
+	return settlement.ValidBeforeTime(validBefore)
+}
+
+// authorizationKeyOf returns the key the payment's settlement attempts are shared by
+func authorizationKeyOf(request *models.SettleRequest) string {
+	auth := request.PaymentPayload.Payload.Authorization
+	return settlement.AuthorizationKey(request.PaymentRequirements.Network, request.PaymentRequirements.Asset, auth.From, auth.Nonce)
+}
+
+// outcomeOf tells what repeats of a settlement get from its response
+// A payment queued or broadcast but not settled yet stays pending until its outcome is resolved
+func outcomeOf(response *models.SettleResponse) settlement.Outcome {
+	switch {
+	case response.Status == models.SettleStatusPending:
+		return settlement.OutcomePending
+	case response.Success || response.Transaction != nil:
+		return settlement.OutcomeFinal
+	default:
+		return settlement.OutcomeRetry
+	}
+}
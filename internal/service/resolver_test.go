@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"x402-facilitator-go/internal/devchain"
+	"x402-facilitator-go/internal/storage"
+	"x402-facilitator-go/pkg/errors"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestResolverFinishesMinedSettlement(t *testing.T) {
+	f := newFlow(t)
+	ctx := context.Background()
+	request := paymentRequest(t, devchain.Payers()[0], 1_000, common.HexToHash("0x11"))
+
+	response := f.settle.Settle(ctx, request, "")
+	if !response.Success {
+		t.Fatalf("settle: failed with %s", response.ErrorReason)
+	}
+
+	// The settlement is left submitted, as by a tracker that stopped before the receipt
+	records, err := f.ledger.Query(ctx, storage.Filter{TxHash: *response.Transaction})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("query ledger: %v, %d records", err, len(records))
+	}
+	record := records[0]
+	record.Status = storage.StatusSubmitted
+	if err := f.ledger.Save(ctx, record); err != nil {
+		t.Fatalf("save record: %v", err)
+	}
+
+	f.settle.recoverSubmitted(ctx)
+	f.settle.resolveHandedOff(ctx)
+
+	resolved, err := f.ledger.Get(ctx, record.ID)
+	if err != nil {
+		t.Fatalf("get record: %v", err)
+	}
+	if resolved.Status != storage.StatusConfirmed || resolved.BlockNumber == 0 {
+		t.Fatalf("record %+v, want it confirmed with its block", resolved)
+	}
+	if len(f.settle.unresolved) != 0 {
+		t.Fatalf("%d settlements left unresolved, want none", len(f.settle.unresolved))
+	}
+}
+
+func TestLookupSettlementOfDroppedTransaction(t *testing.T) {
+	f := newFlow(t)
+	ctx := context.Background()
+	payer := devchain.Payers()[1]
+	settled := paymentRequest(t, payer, 1_000, common.HexToHash("0x12"))
+	if response := f.settle.Settle(ctx, settled, ""); !response.Success {
+		t.Fatalf("settle: failed with %s", response.ErrorReason)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		nonce      common.Hash
+		wantReason errors.X402Error
+	}{
+		{"unused authorization can be settled again", common.HexToHash("0x13"), errors.ErrorSettlementDropped},
+		{"authorization used by another transaction", common.HexToHash("0x12"), errors.ErrorAuthorizationUsed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			request := paymentRequest(t, payer, 1_000, tc.nonce)
+			record := newLedgerRecord(storage.KindSettle, request.PaymentPayload, request.PaymentRequirements)
+			// A broadcast the node no longer knows
+			record.TxHash = common.HexToHash("0xdead").Hex()
+			record.TxHashes = []string{record.TxHash}
+
+			response := f.settle.lookupSettlement(ctx, record, authorizationValidBefore(request))
+			if response == nil || response.Success || response.ErrorReason != tc.wantReason.Code() || response.Transaction != nil {
+				t.Fatalf("got %+v, want %s without a transaction", response, tc.wantReason.Code())
+			}
+		})
+	}
+}
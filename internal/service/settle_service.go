@@ -2,14 +2,22 @@ package service
 
 import (
 	"context"
+	stderrors "errors"
 	"math/big"
+	"sync"
+	"time"
+	"x402-facilitator-go/internal/config"
 	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/settlement"
+	"x402-facilitator-go/internal/storage"
 	"x402-facilitator-go/internal/web3"
 	"x402-facilitator-go/internal/web3/contract"
+	"x402-facilitator-go/pkg/attestation"
 	"x402-facilitator-go/pkg/errors"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"go.uber.org/zap"
@@ -18,28 +26,219 @@ import (
 // SettleService handles payment settlement
 type SettleService struct {
 	verifyService *VerifyService
-	web3Client    *web3.Client
+	web3Client    web3.Chain
+	tracker       *settlement.Tracker
+	reorgWatcher  *settlement.ReorgWatcher
+	idempotency   *settlement.Idempotency
+	exposure      *settlement.Exposure
+	reservations  *settlement.Reservations
+	batcher       *settlement.Batcher
+	gasMonitor    *settlement.GasMonitor
+	ledger        storage.Ledger
+	deferred      config.DeferredConfig
+	queue         storage.DeferredQueue
+	attester      *attestation.Signer
 	privateKey    string
 	logger        *zap.Logger
+
+	flushMu sync.Mutex
+	flushes map[string]bool
+
+	resolveInterval time.Duration
+	resolveMu       sync.Mutex
+	unresolved      map[string]*unresolvedSettlement
 }
 
 // NewSettleService creates a new SettleService
+// reservations is nil unless verification reservations are enabled
+// batcher is nil unless Multicall3 batched settlement is enabled
+// queue holds payments accepted for deferred settlement and is only used when deferred settlement is enabled in cfg
+// attester is nil unless settlement attestations are enabled
 func NewSettleService(
 	verifyService *VerifyService,
-	web3Client *web3.Client,
+	web3Client web3.Chain,
+	tracker *settlement.Tracker,
+	reorgWatcher *settlement.ReorgWatcher,
+	idempotency *settlement.Idempotency,
+	exposure *settlement.Exposure,
+	reservations *settlement.Reservations,
+	batcher *settlement.Batcher,
+	gasMonitor *settlement.GasMonitor,
+	ledger storage.Ledger,
+	cfg config.SettlementConfig,
+	queue storage.DeferredQueue,
+	attester *attestation.Signer,
 	privateKey string,
 	logger *zap.Logger,
 ) *SettleService {
-	return &SettleService{
-		verifyService: verifyService,
-		web3Client:    web3Client,
-		privateKey:    privateKey,
-		logger:        logger,
+	s := &SettleService{
+		verifyService:   verifyService,
+		web3Client:      web3Client,
+		tracker:         tracker,
+		reorgWatcher:    reorgWatcher,
+		idempotency:     idempotency,
+		exposure:        exposure,
+		reservations:    reservations,
+		batcher:         batcher,
+		gasMonitor:      gasMonitor,
+		ledger:          ledger,
+		deferred:        cfg.Deferred,
+		queue:           queue,
+		attester:        attester,
+		privateKey:      privateKey,
+		logger:          logger,
+		flushes:         make(map[string]bool),
+		resolveInterval: time.Duration(cfg.ResolveIntervalSeconds) * time.Second,
+		unresolved:      make(map[string]*unresolvedSettlement),
 	}
+	reorgWatcher.OnReorg(s.markReorged)
+	return s
 }
 
 // Settle settles a payment request
-func (s *SettleService) Settle(ctx context.Context, request *models.SettleRequest) *models.SettleResponse {
+// Settlement is idempotent on the authorization (network, asset, from, nonce) and on the optional idempotencyKey:
+// a repeat with the same payment requirements returns the original transaction's outcome and concurrent duplicates
+// wait on the in-flight attempt, a repeat with different requirements is refused
+// The attempt outlives ctx, a caller giving up first is answered with a pending response
+// With deferred settlement enabled, verified payments are queued and answered with a pending response
+func (s *SettleService) Settle(ctx context.Context, request *models.SettleRequest, idempotencyKey string) *models.SettleResponse {
+	auth := request.PaymentPayload.Payload.Authorization
+	authorizationKey := settlement.AuthorizationKey(
+		request.PaymentRequirements.Network,
+		request.PaymentRequirements.Asset,
+		auth.From,
+		auth.Nonce,
+	)
+
+	response, err := s.idempotency.Do(ctx, authorizationKey, settlement.RequirementsFingerprint(request.PaymentRequirements), idempotencyKey, func(broadcast func(*models.SettleResponse)) (*models.SettleResponse, settlement.Outcome) {
+		// The attempt outlives the caller's request so that retries can pick up its outcome
+		attemptCtx := context.WithoutCancel(ctx)
+		record := newLedgerRecord(storage.KindSettle, request.PaymentPayload, request.PaymentRequirements)
+		saveLedgerRecord(attemptCtx, s.ledger, record, s.logger)
+
+		var response *models.SettleResponse
+		if s.deferred.Enabled && s.deferrable(request) {
+			response = s.accept(attemptCtx, request, record)
+		} else {
+			response = s.settle(attemptCtx, request, record, func(txHash string) {
+				broadcast(tracked(request.PaymentRequirements.Network, auth.From, txHash))
+			})
+			s.attest(request, response)
+		}
+		// Verification moved the hold to settling when it passed, a payment it refused may share its hold with
+		// another caller's verified payment of the same authorization, which must keep counting
+		settling := record.Status != storage.StatusReceived
+		s.finishRecord(attemptCtx, record, response)
+		if settling {
+			s.releaseExposure(request, response)
+		}
+		s.consumeReservation(request, response)
+		response.Retryable = retryable(response)
+		if response.Status == models.SettleStatusPending && response.Transaction != nil {
+			s.handOff(record, request)
+		}
+
+		// Repeats of a queued or broadcast payment get the pending response until its outcome is resolved
+		return response, outcomeOf(response)
+	})
+	if stderrors.Is(err, context.Canceled) || stderrors.Is(err, context.DeadlineExceeded) {
+		// The caller gave up before the attempt sent anything, a repeat waits on the same attempt
+		return &models.SettleResponse{
+			Success: false,
+			Network: request.PaymentRequirements.Network,
+			Payer:   auth.From,
+			Status:  models.SettleStatusPending,
+		}
+	}
+	if err != nil {
+		errorReason := errors.ErrorUnexpectedSettle
+		switch {
+		case stderrors.Is(err, settlement.ErrIdempotencyKeyReused):
+			errorReason = errors.ErrorIdempotencyKeyReused
+		case stderrors.Is(err, settlement.ErrRequirementsMismatch):
+			errorReason = errors.ErrorSettlementRequirementsMismatch
+		}
+		s.logger.Warn("Idempotent settlement lookup failed",
+			zap.Error(err),
+			zap.String("network", request.PaymentRequirements.Network),
+			zap.String("payer", auth.From),
+			zap.String("idempotencyKey", idempotencyKey),
+		)
+		return &models.SettleResponse{
+			Success:     false,
+			Network:     request.PaymentRequirements.Network,
+			ErrorReason: errorReason.Code(),
+			Payer:       auth.From,
+			Retryable:   errorReason.Retryable(),
+		}
+	}
+
+	return response
+}
+
+// retryable reports whether a failed settlement may succeed when the same payment is settled again later
+func retryable(response *models.SettleResponse) bool {
+	return !response.Success && response.ErrorReason != "" && errors.X402Error(response.ErrorReason).Retryable()
+}
+
+// finishRecord moves record to the final status matching the settlement response and saves it
+func (s *SettleService) finishRecord(ctx context.Context, record *storage.SettlementRecord, response *models.SettleResponse) {
+	switch {
+	case response.Status == models.SettleStatusPending && response.Transaction != nil:
+		// Broadcast but not yet mined, the record stays submitted
+	case response.Status == models.SettleStatusPending:
+		record.Transition(storage.StatusQueued, "")
+	case response.Success:
+		record.Transition(storage.StatusConfirmed, "")
+	case record.Status == storage.StatusReceived:
+		record.Transition(storage.StatusInvalid, response.ErrorReason)
+	default:
+		record.Transition(storage.StatusFailed, response.ErrorReason)
+	}
+	saveLedgerRecord(ctx, s.ledger, record, s.logger)
+}
+
+// releaseExposure stops counting a payment against its payer's balance once its settlement is done with it
+// It must only be called by the settlement that moved the payment's hold to settling
+// Payments queued for deferred settlement and failures after a broadcast stay held until their validBefore,
+// since their transfer may still happen, unless the transfer was already seen mined
+func (s *SettleService) releaseExposure(request *models.SettleRequest, response *models.SettleResponse) {
+	if response.Status == models.SettleStatusPending || (!response.Success && response.Transaction != nil) {
+		return
+	}
+	s.exposure.Release(settlement.NewHold(request.PaymentRequirements, request.PaymentPayload.Payload.Authorization))
+}
+
+// consumeReservation ends the verification reservation of a payment once its settlement is done with it
+// A settled payment leaves a tombstone and a payment queued for deferred settlement stays reserved, both until
+// its validBefore, a failed payment is released
+func (s *SettleService) consumeReservation(request *models.SettleRequest, response *models.SettleResponse) {
+	if s.reservations == nil {
+		return
+	}
+	authorizationKey, owner := reservationOf(request)
+	hold := settlement.NewHold(request.PaymentRequirements, request.PaymentPayload.Payload.Authorization)
+	switch {
+	case response.Success:
+		s.reservations.Consume(authorizationKey, owner, hold.ValidBefore)
+	case response.Status == models.SettleStatusPending:
+		s.reservations.Extend(authorizationKey, owner, hold.ValidBefore)
+	default:
+		s.reservations.Release(authorizationKey, owner)
+	}
+}
+
+// reservationOf returns the authorization key and reservation owner of a payment
+func reservationOf(request *models.SettleRequest) (string, string) {
+	auth := request.PaymentPayload.Payload.Authorization
+	authorizationKey := settlement.AuthorizationKey(request.PaymentRequirements.Network, request.PaymentRequirements.Asset, auth.From, auth.Nonce)
+	return authorizationKey, settlement.ReservationOwner(request.PaymentRequirements)
+}
+
+// settle verifies and settles a payment request on-chain, tracking its progress in record
+// onBroadcast, when set, is called with the transaction hash once the settlement transaction is sent, batched
+// settlements only learn it with their outcome
+func (s *SettleService) settle(ctx context.Context, request *models.SettleRequest, record *storage.SettlementRecord, onBroadcast func(txHash string)) *models.SettleResponse {
 	// Verify the request first
 	verifyRequest := &models.VerifyRequest{
 		X402Version:         request.X402Version,
@@ -47,7 +246,7 @@ func (s *SettleService) Settle(ctx context.Context, request *models.SettleReques
 		PaymentRequirements: request.PaymentRequirements,
 	}
 
-	verifyResponse := s.verifyService.Verify(ctx, verifyRequest)
+	verifyResponse := s.verifyService.verify(ctx, verifyRequest)
 	if !verifyResponse.IsValid {
 		return &models.SettleResponse{
 			Success:     false,
@@ -56,9 +255,15 @@ func (s *SettleService) Settle(ctx context.Context, request *models.SettleReques
 			Payer:       verifyResponse.Payer,
 		}
 	}
+	record.Transition(storage.StatusVerified, "")
+	hold := settlement.NewHold(request.PaymentRequirements, request.PaymentPayload.Payload.Authorization)
+	s.exposure.Settling(hold)
 
 	networkStr := request.PaymentRequirements.Network
 	payer := verifyResponse.Payer
+	if response := s.checkGas(networkStr, payer); response != nil {
+		return response
+	}
 	auth := request.PaymentPayload.Payload.Authorization
 	value, _ := new(big.Int).SetString(auth.Value, 10)
 	validAfter, _ := new(big.Int).SetString(auth.ValidAfter, 10)
@@ -69,8 +274,36 @@ func (s *SettleService) Settle(ctx context.Context, request *models.SettleReques
 	fromAddr := common.HexToAddress(auth.From)
 	toAddr := common.HexToAddress(request.PaymentRequirements.PayTo)
 
-	client, _ := s.web3Client.GetClient(networkStr)
+	authorization := settlement.Authorization{
+		From:        fromAddr,
+		To:          toAddr,
+		Value:       value,
+		ValidAfter:  validAfter,
+		ValidBefore: validBefore,
+		Nonce:       nonceBytes,
+		Signature:   signatureBytes,
+	}
+
+	if s.batcher != nil {
+		return s.settleBatched(ctx, networkStr, payer, contractAddress, authorization, hold, record)
+	}
+
+	client, err := s.web3Client.GetClient(networkStr)
+	if err != nil {
+		s.logger.Warn("Network unavailable for settlement",
+			zap.Error(err),
+			zap.String("network", networkStr),
+			zap.String("payer", payer),
+		)
+		return &models.SettleResponse{
+			Success:     false,
+			Network:     networkStr,
+			ErrorReason: errors.ErrorNetworkUnavailable.Code(),
+			Payer:       payer,
+		}
+	}
 	chainID, _ := s.web3Client.GetChainID(networkStr)
+	confirmations, _ := s.web3Client.GetConfirmations(networkStr)
 
 	// Parse private key and create transactor
 	privateKey, err := crypto.HexToECDSA(s.privateKey)
@@ -102,6 +335,8 @@ func (s *SettleService) Settle(ctx context.Context, request *models.SettleReques
 		}
 	}
 	transactOpts.Context = ctx
+	// Sign without sending, so that the transaction hash is known before the broadcast
+	transactOpts.NoSend = true
 
 	// Create token contract instance
 	tokenContract, err := contract.NewEIP3009Token(contractAddress, client)
@@ -120,7 +355,7 @@ func (s *SettleService) Settle(ctx context.Context, request *models.SettleReques
 		}
 	}
 
-	// Execute transfer
+	// Build and sign the transfer
 	tx, err := tokenContract.TransferWithAuthorization(
 		transactOpts,
 		fromAddr,
@@ -131,9 +366,17 @@ func (s *SettleService) Settle(ctx context.Context, request *models.SettleReques
 		nonceBytes,
 		signatureBytes,
 	)
-	if err != nil {
-		s.logger.Warn("Token transferWithAuthorization reverted",
+	if err == nil {
+		err = client.SendTransaction(ctx, tx)
+	}
+	if err != nil && !stderrors.Is(err, web3.ErrBroadcastUnknown) {
+		revertReason := settlement.RevertReason(err)
+		errorReason := sendErrorReason(err, revertReason)
+		s.logger.Warn("Token transferWithAuthorization failed",
 			zap.Error(err),
+			zap.String("revertReason", revertReason),
+			zap.String("errorReason", errorReason.Code()),
+			zap.Bool("retryable", errorReason.Retryable()),
 			zap.String("network", networkStr),
 			zap.String("payer", payer),
 			zap.String("contract", contractAddress.Hex()),
@@ -141,10 +384,27 @@ func (s *SettleService) Settle(ctx context.Context, request *models.SettleReques
 		return &models.SettleResponse{
 			Success:     false,
 			Network:     networkStr,
-			ErrorReason: errors.ErrorInvalidTransactionState.Code(),
+			ErrorReason: errorReason.Code(),
 			Payer:       payer,
 		}
 	}
+	if err != nil {
+		// The node may have accepted the transaction, tracking its hash rebroadcasts it if it was lost
+		s.logger.Warn("Settlement broadcast outcome unknown, tracking the signed transaction",
+			zap.Error(err),
+			zap.String("txHash", tx.Hash().Hex()),
+			zap.String("network", networkStr),
+			zap.String("payer", payer),
+		)
+	}
+
+	record.TxHash = tx.Hash().Hex()
+	record.TxHashes = []string{record.TxHash}
+	record.Transition(storage.StatusSubmitted, "")
+	saveLedgerRecord(ctx, s.ledger, record, s.logger)
+	if onBroadcast != nil {
+		onBroadcast(record.TxHash)
+	}
 
 	// Wait for confirmation
 	s.logger.Info("Transaction sent, waiting for confirmation",
@@ -153,7 +413,36 @@ func (s *SettleService) Settle(ctx context.Context, request *models.SettleReques
 		zap.String("payer", payer),
 	)
 
-	receipt, err := bind.WaitMined(ctx, client, tx)
+	trackResult, err := s.tracker.Track(ctx, settlement.TrackRequest{
+		Network:       networkStr,
+		Backend:       client,
+		Signer:        privateKey,
+		ChainID:       chainID,
+		Tx:            tx,
+		ValidBefore:   settlement.ValidBeforeTime(validBefore),
+		Confirmations: confirmations,
+		OnMined: func(receipt *types.Receipt) {
+			// The mined transfer already lowered the payer's balance, keeping the hold would count it twice
+			if receipt.Status == types.ReceiptStatusSuccessful {
+				s.exposure.Release(hold)
+			}
+		},
+	})
+	record.TxHashes = record.TxHashes[:0]
+	for _, attempt := range trackResult.Attempts {
+		if attempt.Replacement || len(record.TxHashes) == 0 {
+			record.TxHashes = append(record.TxHashes, attempt.Hash.Hex())
+		}
+	}
+	if len(trackResult.Attempts) > 1 {
+		s.logger.Info("Settlement transaction broadcast history",
+			zap.String("txHash", tx.Hash().Hex()),
+			zap.Any("attempts", trackResult.Attempts),
+			zap.String("network", networkStr),
+			zap.String("payer", payer),
+			zap.String("nonce", auth.Nonce),
+		)
+	}
 	if err != nil {
 		s.logger.Warn("Failed while waiting for tx receipt",
 			zap.String("txHash", tx.Hash().Hex()),
@@ -161,33 +450,245 @@ func (s *SettleService) Settle(ctx context.Context, request *models.SettleReques
 			zap.String("network", networkStr),
 			zap.String("payer", payer),
 		)
+		sentTxHash := tx.Hash().Hex()
+		if !stderrors.Is(err, settlement.ErrAuthorizationExpired) {
+			return tracked(networkStr, payer, sentTxHash)
+		}
 		return &models.SettleResponse{
 			Success:     false,
 			Network:     networkStr,
-			ErrorReason: errors.ErrorUnexpectedSettle.Code(),
+			ErrorReason: errors.ErrorInvalidExactEVMPayloadAuthorizationValidBefore.Code(),
+			Transaction: &sentTxHash,
 			Payer:       payer,
 		}
 	}
+	receipt := trackResult.Receipt
 
+	txHash := receipt.TxHash.Hex()
+	record.TxHash = txHash
+	record.BlockNumber = receipt.BlockNumber.Uint64()
+	record.GasUsed = receipt.GasUsed
 	if receipt.Status == types.ReceiptStatusFailed {
+		// Receipts carry no revert reason, replaying the call at the inclusion block recovers it
+		revertReason := settlement.ReplayRevertReason(ctx, client, transactOpts.From, tx, receipt.BlockNumber)
+		errorReason := errors.FromRevertReason(revertReason)
 		s.logger.Warn("Settlement transaction failed on-chain",
-			zap.String("txHash", tx.Hash().Hex()),
+			zap.String("txHash", txHash),
 			zap.Uint64("blockNumber", receipt.BlockNumber.Uint64()),
+			zap.String("revertReason", revertReason),
+			zap.String("errorReason", errorReason.Code()),
+			zap.Bool("retryable", errorReason.Retryable()),
+			zap.String("network", networkStr),
+			zap.String("payer", payer),
+		)
+		return &models.SettleResponse{
+			Success:     false,
+			Network:     networkStr,
+			ErrorReason: errorReason.Code(),
+			Transaction: &txHash,
+			Payer:       payer,
+		}
+	}
+
+	if err := settlement.VerifyReceiptEvents(receipt, contractAddress, authorization); err != nil {
+		s.logger.Error("Settlement receipt events do not match the authorization",
+			zap.Error(err),
+			zap.String("txHash", txHash),
 			zap.String("network", networkStr),
 			zap.String("payer", payer),
+			zap.String("contract", contractAddress.Hex()),
 		)
 		return &models.SettleResponse{
 			Success:     false,
 			Network:     networkStr,
-			ErrorReason: errors.ErrorInvalidTransactionState.Code(),
+			ErrorReason: errors.ErrorSettlementEventMismatch.Code(),
+			Transaction: &txHash,
 			Payer:       payer,
 		}
 	}
 
-	txHash := tx.Hash().Hex()
+	return s.confirmed(networkStr, payer, receipt, trackResult.Confirmations)
+}
+
+// sendErrorReason maps a failure to build, sign or send a settlement transaction to an error code
+func sendErrorReason(err error, revertReason string) errors.X402Error {
+	switch {
+	case stderrors.Is(err, web3.ErrNetworkUnavailable):
+		return errors.ErrorNetworkUnavailable
+	case stderrors.Is(err, web3.ErrRPCFailure):
+		return errors.ErrorRPCFailure
+	}
+	return errors.FromRevertReason(revertReason)
+}
+
+// attest adds a signed attestation to a successful settlement response when attestations are enabled
+func (s *SettleService) attest(request *models.SettleRequest, response *models.SettleResponse) {
+	if s.attester == nil || !response.Success || response.Transaction == nil {
+		return
+	}
+
+	chainID, err := s.web3Client.GetChainID(response.Network)
+	if err != nil {
+		s.logger.Error("Skipping settlement attestation, unknown network chain ID",
+			zap.Error(err),
+			zap.String("txHash", *response.Transaction),
+			zap.String("network", response.Network),
+			zap.String("payer", response.Payer),
+		)
+		return
+	}
+	auth := request.PaymentPayload.Payload.Authorization
+	signed, err := s.attester.Sign(attestation.Settlement{
+		Network: response.Network,
+		ChainID: chainID.Int64(),
+		TxHash:  *response.Transaction,
+		Payer:   auth.From,
+		PayTo:   request.PaymentRequirements.PayTo,
+		Asset:   request.PaymentRequirements.Asset,
+		Amount:  auth.Value,
+		Nonce:   auth.Nonce,
+	})
+	if err != nil {
+		s.logger.Error("Failed to sign settlement attestation",
+			zap.Error(err),
+			zap.String("txHash", *response.Transaction),
+			zap.String("network", response.Network),
+			zap.String("payer", response.Payer),
+		)
+		return
+	}
+	response.Attestation = signed
+}
+
+// checkGas fails the settlement fast when the settlement signer cannot pay for gas on the network
+func (s *SettleService) checkGas(networkStr, payer string) *models.SettleResponse {
+	if s.gasMonitor.Funded(networkStr) {
+		return nil
+	}
+
+	s.logger.Warn("Settlement rejected, facilitator gas balance below threshold",
+		zap.String("network", networkStr),
+		zap.String("payer", payer),
+	)
+	return &models.SettleResponse{
+		Success:     false,
+		Network:     networkStr,
+		ErrorReason: errors.ErrorFacilitatorInsufficientGas.Code(),
+		Payer:       payer,
+	}
+}
+
+// settleBatched settles a verified authorization as part of a Multicall3 settlement batch
+func (s *SettleService) settleBatched(
+	ctx context.Context,
+	networkStr string,
+	payer string,
+	asset common.Address,
+	authorization settlement.Authorization,
+	hold settlement.Hold,
+	record *storage.SettlementRecord,
+) *models.SettleResponse {
+	result := s.batcher.Submit(ctx, networkStr, asset, authorization, func() { s.exposure.Release(hold) })
+
+	if result.TxHash == (common.Hash{}) {
+		// Never broadcast: either the sub-call failed simulation or the batch could not be sent
+		revertReason := settlement.DecodeRevertData(result.RevertData)
+		errorReason := errors.ErrorUnexpectedSettle
+		switch {
+		case stderrors.Is(result.Err, settlement.ErrBatchCallFailed):
+			errorReason = errors.FromRevertReason(revertReason)
+		case stderrors.Is(result.Err, web3.ErrNetworkUnavailable):
+			errorReason = errors.ErrorNetworkUnavailable
+		case stderrors.Is(result.Err, web3.ErrRPCFailure):
+			errorReason = errors.ErrorRPCFailure
+		}
+		s.logger.Warn("Authorization was not settled in batch",
+			zap.Error(result.Err),
+			zap.String("network", networkStr),
+			zap.String("payer", payer),
+			zap.String("revertData", hexutil.Encode(result.RevertData)),
+			zap.String("revertReason", revertReason),
+			zap.String("errorReason", errorReason.Code()),
+			zap.Bool("retryable", errorReason.Retryable()),
+		)
+		return &models.SettleResponse{
+			Success:     false,
+			Network:     networkStr,
+			ErrorReason: errorReason.Code(),
+			Payer:       payer,
+		}
+	}
+
+	txHash := result.TxHash.Hex()
+	record.TxHash = txHash
+	for _, attempt := range result.Attempts {
+		if attempt.Replacement || len(record.TxHashes) == 0 {
+			record.TxHashes = append(record.TxHashes, attempt.Hash.Hex())
+		}
+	}
+	record.Transition(storage.StatusSubmitted, "")
+	if result.Receipt != nil {
+		record.BlockNumber = result.Receipt.BlockNumber.Uint64()
+		record.GasUsed = result.Receipt.GasUsed
+	}
+
+	if result.Err != nil {
+		s.logger.Warn("Batched settlement failed",
+			zap.Error(result.Err),
+			zap.String("txHash", txHash),
+			zap.String("network", networkStr),
+			zap.String("payer", payer),
+		)
+		var errorReason errors.X402Error
+		switch {
+		case stderrors.Is(result.Err, settlement.ErrAuthorizationExpired):
+			errorReason = errors.ErrorInvalidExactEVMPayloadAuthorizationValidBefore
+		case stderrors.Is(result.Err, settlement.ErrBatchCallFailed):
+			errorReason = errors.ErrorInvalidTransactionState
+		case stderrors.Is(result.Err, settlement.ErrReceiptEventMismatch):
+			errorReason = errors.ErrorSettlementEventMismatch
+		default:
+			return tracked(networkStr, payer, txHash)
+		}
+		return &models.SettleResponse{
+			Success:     false,
+			Network:     networkStr,
+			ErrorReason: errorReason.Code(),
+			Transaction: &txHash,
+			Payer:       payer,
+		}
+	}
+
+	return s.confirmed(networkStr, payer, result.Receipt, result.Confirmations)
+}
+
+// tracked reports a broadcast settlement whose outcome is not known yet, as when waiting for its receipt failed
+// The transaction may still be mined, so the payment is neither settled nor failed
+func tracked(networkStr, payer, txHash string) *models.SettleResponse {
+	return &models.SettleResponse{
+		Success:     false,
+		Network:     networkStr,
+		Transaction: &txHash,
+		Payer:       payer,
+		Status:      models.SettleStatusPending,
+	}
+}
+
+// confirmed reports a successful settlement and watches its receipt for reorgs
+func (s *SettleService) confirmed(networkStr, payer string, receipt *types.Receipt, confirmations uint64) *models.SettleResponse {
+	s.reorgWatcher.Watch(settlement.WatchedSettlement{
+		Network:     networkStr,
+		TxHash:      receipt.TxHash,
+		BlockNumber: receipt.BlockNumber.Uint64(),
+		BlockHash:   receipt.BlockHash,
+		Payer:       payer,
+	})
+
+	txHash := receipt.TxHash.Hex()
 	s.logger.Info("Settlement transaction confirmed",
 		zap.String("txHash", txHash),
 		zap.Uint64("blockNumber", receipt.BlockNumber.Uint64()),
+		zap.Uint64("confirmations", confirmations),
 		zap.String("network", networkStr),
 		zap.String("payer", payer),
 	)
@@ -199,3 +700,25 @@ func (s *SettleService) Settle(ctx context.Context, request *models.SettleReques
 		Payer:       payer,
 	}
 }
+
+// markReorged flags the ledger record of a settlement whose receipt vanished after a reorg
+func (s *SettleService) markReorged(watched settlement.WatchedSettlement) {
+	ctx := context.Background()
+	records, err := s.ledger.Query(ctx, storage.Filter{
+		Kind:   storage.KindSettle,
+		TxHash: watched.TxHash.Hex(),
+		Limit:  1,
+	})
+	if err != nil || len(records) == 0 {
+		s.logger.Error("Failed to find ledger record of reorged settlement",
+			zap.Error(err),
+			zap.String("network", watched.Network),
+			zap.String("txHash", watched.TxHash.Hex()),
+		)
+		return
+	}
+
+	record := records[0]
+	record.Transition(storage.StatusReorged, "receipt vanished after reorg")
+	saveLedgerRecord(ctx, s.ledger, record, s.logger)
+}
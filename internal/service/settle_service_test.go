@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/devchain"
+	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/settlement"
+	"x402-facilitator-go/internal/storage"
+	"x402-facilitator-go/internal/util/eip3009"
+	"x402-facilitator-go/internal/verifier"
+	"x402-facilitator-go/internal/verifier/exact"
+	"x402-facilitator-go/internal/web3"
+	"x402-facilitator-go/internal/web3/contract"
+	"x402-facilitator-go/pkg/errors"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/jinzhu/configor"
+	"go.uber.org/zap"
+)
+
+// testNetwork is the dev network the flow tests settle on
+const testNetwork = "local"
+
+// testPayTo is the recipient of the flow tests' payments
+var testPayTo = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+
+// flow is the verify and settle services wired over a simulated dev chain, as cmd/server wires them
+type flow struct {
+	chain  *web3.Simulated
+	verify *VerifyService
+	settle *SettleService
+	ledger *storage.MemoryLedger
+}
+
+// newFlow wires the services with the configuration defaults, changed by configure, over a fresh simulated dev chain
+func newFlow(t *testing.T, configure ...func(cfg *config.Config)) *flow {
+	t.Helper()
+
+	cfg := &config.Config{}
+	if err := configor.Load(cfg); err != nil {
+		t.Fatalf("load config defaults: %v", err)
+	}
+	for _, apply := range configure {
+		apply(cfg)
+	}
+	cfg.Networks.NetworkInfos = []config.NetworkInfo{{
+		Name:          testNetwork,
+		Dev:           true,
+		ChainID:       devchain.ChainID,
+		X402Version:   1,
+		Scheme:        exact.ExactScheme,
+		Confirmations: 1,
+	}}
+	cfg.X402.FacilitatorPrivateKey = devchain.Facilitator().PrivateKey
+
+	chain, err := devchain.NewChain(cfg.Networks.NetworkInfos, devchain.Facilitator().Address)
+	if err != nil {
+		t.Fatalf("new dev chain: %v", err)
+	}
+	t.Cleanup(func() { chain.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	logger := zap.NewNop()
+	ledger := storage.NewMemoryLedger()
+	assetCache := web3.NewAssetCache(chain, cfg.Cache)
+	clock := web3.NewClock(chain, cfg.Verification, cfg.Networks.NetworkInfos)
+	exposure := settlement.NewExposure(time.Duration(cfg.Verification.ExposureHoldSeconds) * time.Second)
+	verifiers := []verifier.Verifier{
+		exact.NewGlobalVerifier(logger),
+		exact.NewPaymentContextVerifier(logger, chain, clock, time.Duration(cfg.Verification.SkewToleranceSeconds)*time.Second),
+		exact.NewEIP3009AssetVerifier(logger, chain, assetCache),
+		exact.NewSignatureVerifier(logger, chain, assetCache),
+		exact.NewAuthorizationStateVerifier(logger, chain),
+		exact.NewAssetRestrictionVerifier(logger, chain),
+		exact.NewUserBalanceVerifier(logger, chain, assetCache, exposure),
+	}
+	var reservations *settlement.Reservations
+	if cfg.Verification.Reservations.Enabled {
+		reservations = settlement.NewReservations(time.Duration(cfg.Verification.Reservations.HoldSeconds) * time.Second)
+		verifiers = append(verifiers, exact.NewReservationVerifier(logger, reservations))
+	}
+	verifyService := NewVerifyService(verifiers, nil, ledger, false, logger)
+
+	confirmationWatcher := settlement.NewConfirmationWatcher(chain, cfg.Settlement, logger)
+	go confirmationWatcher.Run(ctx)
+	gasMonitor, err := settlement.NewGasMonitor(chain, cfg.GasMonitor, cfg.X402.FacilitatorPrivateKey, logger)
+	if err != nil {
+		t.Fatalf("new gas monitor: %v", err)
+	}
+	settleService := NewSettleService(
+		verifyService,
+		chain,
+		settlement.NewTracker(cfg.Settlement, confirmationWatcher, clock, logger),
+		settlement.NewReorgWatcher(chain, cfg.Settlement, logger),
+		settlement.NewIdempotency(time.Duration(cfg.Settlement.IdempotencyTTLSeconds)*time.Second),
+		exposure,
+		reservations,
+		nil,
+		gasMonitor,
+		ledger,
+		cfg.Settlement,
+		ledger,
+		nil,
+		cfg.X402.FacilitatorPrivateKey,
+		logger,
+	)
+
+	return &flow{chain: chain, verify: verifyService, settle: settleService, ledger: ledger}
+}
+
+// paymentRequest builds a settle request for value test token units, signed by payer
+func paymentRequest(t *testing.T, payer devchain.Account, value int64, nonce common.Hash) *models.SettleRequest {
+	t.Helper()
+
+	authorization := models.Authorization{
+		From:        payer.Address.Hex(),
+		To:          testPayTo.Hex(),
+		Value:       strconv.FormatInt(value, 10),
+		ValidAfter:  strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10),
+		ValidBefore: strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10),
+		Nonce:       nonce.Hex(),
+	}
+	hash := eip3009.ComputeTransferWithAuthorizationHash(eip3009.TransferWithAuthorizationParams{
+		ChainId:           big.NewInt(devchain.ChainID),
+		VerifyingContract: devchain.TokenAddress.Hex(),
+		DomainName:        devchain.TokenName,
+		DomainVersion:     devchain.TokenVersion,
+		From:              authorization.From,
+		To:                authorization.To,
+		Value:             authorization.Value,
+		ValidAfter:        authorization.ValidAfter,
+		ValidBefore:       authorization.ValidBefore,
+		Nonce:             authorization.Nonce,
+	})
+	key, err := crypto.HexToECDSA(payer.PrivateKey)
+	if err != nil {
+		t.Fatalf("payer key: %v", err)
+	}
+	signature, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatalf("sign authorization: %v", err)
+	}
+	signature[64] += 27
+
+	return &models.SettleRequest{
+		X402Version: 1,
+		PaymentPayload: models.PaymentPayload{
+			X402Version: 1,
+			Scheme:      exact.ExactScheme,
+			Network:     testNetwork,
+			Payload: models.Payload{
+				Signature:     hexutil.Encode(signature),
+				Authorization: authorization,
+			},
+		},
+		PaymentRequirements: models.PaymentRequirements{
+			Scheme:            exact.ExactScheme,
+			Network:           testNetwork,
+			MaxAmountRequired: authorization.Value,
+			Resource:          "https://example.com/resource",
+			PayTo:             authorization.To,
+			MaxTimeoutSeconds: 60,
+			Asset:             devchain.TokenAddress.Hex(),
+			Extra: models.Extra{
+				Name:    devchain.TokenName,
+				Version: devchain.TokenVersion,
+			},
+		},
+	}
+}
+
+// verifyRequest returns the verify request of a settle request
+func verifyRequest(request *models.SettleRequest) *models.VerifyRequest {
+	return &models.VerifyRequest{
+		X402Version:         request.X402Version,
+		PaymentPayload:      request.PaymentPayload,
+		PaymentRequirements: request.PaymentRequirements,
+	}
+}
+
+// tokenBalance returns the test token balance of account on the flow's chain
+func (f *flow) tokenBalance(t *testing.T, account common.Address) *big.Int {
+	t.Helper()
+
+	client, err := f.chain.GetClient(testNetwork)
+	if err != nil {
+		t.Fatalf("get client: %v", err)
+	}
+	token, err := contract.NewERC20(devchain.TokenAddress, client)
+	if err != nil {
+		t.Fatalf("bind token: %v", err)
+	}
+	balance, err := token.BalanceOf(&bind.CallOpts{}, account)
+	if err != nil {
+		t.Fatalf("balanceOf: %v", err)
+	}
+	return balance
+}
+
+func TestVerifyThenSettle(t *testing.T) {
+	f := newFlow(t)
+	ctx := context.Background()
+	payer := devchain.Payers()[0]
+	request := paymentRequest(t, payer, 1_000_000, common.HexToHash("0x01"))
+
+	verifyResponse := f.verify.Verify(ctx, verifyRequest(request))
+	if !verifyResponse.IsValid {
+		t.Fatalf("verify: invalid payment, reason %s", verifyResponse.InvalidReason)
+	}
+	if verifyResponse.Payer != payer.Address.Hex() {
+		t.Fatalf("verify: payer %s, want %s", verifyResponse.Payer, payer.Address.Hex())
+	}
+
+	response := f.settle.Settle(ctx, request, "")
+	if !response.Success {
+		t.Fatalf("settle: failed with %s", response.ErrorReason)
+	}
+	if response.Transaction == nil {
+		t.Fatal("settle: no transaction hash")
+	}
+	if response.Network != testNetwork || response.Payer != payer.Address.Hex() {
+		t.Fatalf("settle: network %s payer %s", response.Network, response.Payer)
+	}
+
+	if balance := f.tokenBalance(t, testPayTo); balance.Cmp(big.NewInt(1_000_000)) != 0 {
+		t.Fatalf("payTo balance %s, want 1000000", balance)
+	}
+	want := new(big.Int).Sub(devchain.PayerTokenBalance, big.NewInt(1_000_000))
+	if balance := f.tokenBalance(t, payer.Address); balance.Cmp(want) != 0 {
+		t.Fatalf("payer balance %s, want %s", balance, want)
+	}
+
+	records, err := f.ledger.Query(ctx, storage.Filter{TxHash: *response.Transaction})
+	if err != nil {
+		t.Fatalf("query ledger: %v", err)
+	}
+	if len(records) != 1 || records[0].Status != storage.StatusConfirmed {
+		t.Fatalf("ledger records %+v, want one confirmed record", records)
+	}
+}
+
+func TestSettleRepeatReturnsOriginalTransaction(t *testing.T) {
+	f := newFlow(t)
+	ctx := context.Background()
+	request := paymentRequest(t, devchain.Payers()[1], 250_000, common.HexToHash("0x02"))
+
+	first := f.settle.Settle(ctx, request, "")
+	if !first.Success {
+		t.Fatalf("first settle: failed with %s", first.ErrorReason)
+	}
+	repeat := f.settle.Settle(ctx, request, "")
+	if !repeat.Success || repeat.Transaction == nil || *repeat.Transaction != *first.Transaction {
+		t.Fatalf("repeat settle: %+v, want the original transaction %s", repeat, *first.Transaction)
+	}
+	if balance := f.tokenBalance(t, testPayTo); balance.Cmp(big.NewInt(250_000)) != 0 {
+		t.Fatalf("payTo balance %s, want a single transfer of 250000", balance)
+	}
+}
+
+func TestVerifyRejectsTamperedPayment(t *testing.T) {
+	f := newFlow(t)
+	ctx := context.Background()
+	request := paymentRequest(t, devchain.Payers()[2], 1_000, common.HexToHash("0x03"))
+	request.PaymentPayload.Payload.Authorization.Value = "2000"
+	request.PaymentRequirements.MaxAmountRequired = "2000"
+
+	verifyResponse := f.verify.Verify(ctx, verifyRequest(request))
+	if verifyResponse.IsValid {
+		t.Fatal("verify: tampered payment accepted")
+	}
+	if verifyResponse.InvalidReason != errors.ErrorInvalidExactEVMPayloadSignature.Code() {
+		t.Fatalf("verify: reason %s, want %s", verifyResponse.InvalidReason, errors.ErrorInvalidExactEVMPayloadSignature.Code())
+	}
+
+	response := f.settle.Settle(ctx, request, "")
+	if response.Success || response.Transaction != nil {
+		t.Fatalf("settle: tampered payment settled: %+v", response)
+	}
+	if balance := f.tokenBalance(t, testPayTo); balance.Sign() != 0 {
+		t.Fatalf("payTo balance %s, want 0", balance)
+	}
+}
+
+func TestVerifyRejectsInsufficientBalance(t *testing.T) {
+	f := newFlow(t)
+	ctx := context.Background()
+	value := new(big.Int).Add(devchain.PayerTokenBalance, big.NewInt(1)).Int64()
+	request := paymentRequest(t, devchain.Payers()[3], value, common.HexToHash("0x04"))
+
+	verifyResponse := f.verify.Verify(ctx, verifyRequest(request))
+	if verifyResponse.IsValid {
+		t.Fatal("verify: payment above the payer's balance accepted")
+	}
+	if verifyResponse.InvalidReason != errors.ErrorInsufficientFunds.Code() {
+		t.Fatalf("verify: reason %s, want %s", verifyResponse.InvalidReason, errors.ErrorInsufficientFunds.Code())
+	}
+}
+
+func TestVerifyRejectsSettledAuthorization(t *testing.T) {
+	f := newFlow(t)
+	ctx := context.Background()
+	request := paymentRequest(t, devchain.Payers()[0], 10_000, common.HexToHash("0x05"))
+
+	if response := f.settle.Settle(ctx, request, ""); !response.Success {
+		t.Fatalf("settle: failed with %s", response.ErrorReason)
+	}
+
+	verifyResponse := f.verify.Verify(ctx, verifyRequest(request))
+	if verifyResponse.IsValid {
+		t.Fatal("verify: settled authorization accepted")
+	}
+	if verifyResponse.InvalidReason != errors.ErrorAuthorizationUsed.Code() {
+		t.Fatalf("verify: reason %s, want %s", verifyResponse.InvalidReason, errors.ErrorAuthorizationUsed.Code())
+	}
+}
+
+func TestRefusedSettleKeepsAnotherCallersHold(t *testing.T) {
+	f := newFlow(t, func(cfg *config.Config) {
+		cfg.Verification.Reservations.Enabled = true
+	})
+	ctx := context.Background()
+	payer := devchain.Payers()[1]
+	value := new(big.Int).Sub(devchain.PayerTokenBalance, big.NewInt(1_000)).Int64()
+	request := paymentRequest(t, payer, value, common.HexToHash("0x06"))
+
+	if response := f.verify.Verify(ctx, verifyRequest(request)); !response.IsValid {
+		t.Fatalf("verify: invalid payment, reason %s", response.InvalidReason)
+	}
+
+	// Another caller settles the same authorization for another resource, the reservation verifier refuses it
+	// after the balance verifier refreshed the first caller's hold
+	other := *request
+	other.PaymentRequirements.Resource = "https://example.com/other"
+	if response := f.settle.Settle(ctx, &other, ""); response.Success || response.ErrorReason != errors.ErrorAuthorizationReserved.Code() {
+		t.Fatalf("settle for another resource: %+v, want %s", response, errors.ErrorAuthorizationReserved.Code())
+	}
+
+	// The first caller's hold still counts against the payer's balance
+	next := paymentRequest(t, payer, 2_000, common.HexToHash("0x07"))
+	response := f.verify.Verify(ctx, verifyRequest(next))
+	if response.IsValid || response.InvalidReason != errors.ErrorInsufficientFunds.Code() {
+		t.Fatalf("verify of a payment beyond the held balance: valid %v reason %s, want %s",
+			response.IsValid, response.InvalidReason, errors.ErrorInsufficientFunds.Code())
+	}
+}
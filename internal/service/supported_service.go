@@ -3,17 +3,25 @@ package service
 import (
 	"x402-facilitator-go/internal/config"
 	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/settlement"
+	"x402-facilitator-go/pkg/attestation"
 )
 
 // SupportedService provides information about supported schemes and networks
 type SupportedService struct {
 	NetworkInfos []config.NetworkInfo
+	gasMonitor   *settlement.GasMonitor
+	attester     *attestation.Signer
 }
 
 // NewSupportedService creates a new SupportedService
-func NewSupportedService(networkInfos []config.NetworkInfo) *SupportedService {
+// Networks where the settlement signer is underfunded are left out until its balance is restored
+// attester is nil unless settlement attestations are enabled
+func NewSupportedService(networkInfos []config.NetworkInfo, gasMonitor *settlement.GasMonitor, attester *attestation.Signer) *SupportedService {
 	return &SupportedService{
 		NetworkInfos: networkInfos,
+		gasMonitor:   gasMonitor,
+		attester:     attester,
 	}
 }
 
@@ -22,6 +30,9 @@ func (s *SupportedService) Supported() *models.SupportedResponse {
 	kinds := make([]models.SupportedKind, 0, len(s.NetworkInfos))
 
 	for _, networkInfo := range s.NetworkInfos {
+		if !s.gasMonitor.Funded(networkInfo.Name) {
+			continue
+		}
 		kinds = append(kinds, models.SupportedKind{
 			X402Version: networkInfo.X402Version,
 			Scheme:      networkInfo.Scheme,
@@ -29,7 +40,11 @@ func (s *SupportedService) Supported() *models.SupportedResponse {
 		})
 	}
 
-	return &models.SupportedResponse{
+	response := &models.SupportedResponse{
 		Kinds: kinds,
 	}
+	if s.attester != nil {
+		response.AttestationSigner = s.attester.Address().Hex()
+	}
+	return response
 }
@@ -3,7 +3,9 @@ package service
 import (
 	"context"
 	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/storage"
 	"x402-facilitator-go/internal/verifier"
+	"x402-facilitator-go/internal/web3"
 	"x402-facilitator-go/pkg/errors"
 
 	"go.uber.org/zap"
@@ -11,27 +13,58 @@ import (
 
 // VerifyService handles payment verification
 type VerifyService struct {
-	verifiers []verifier.Verifier
-	logger    *zap.Logger
+	verifiers  []verifier.Verifier
+	prefetcher *web3.Prefetcher
+	ledger     storage.Ledger
+	record     bool
+	logger     *zap.Logger
 }
 
 // NewVerifyService creates a new VerifyService
-func NewVerifyService(verifiers []verifier.Verifier, logger *zap.Logger) *VerifyService {
+// prefetcher batches the reads of chain-reading verifiers, it may be nil to let every verifier read on its own
+// record saves every /verify attempt in ledger, otherwise verifications are not recorded
+func NewVerifyService(verifiers []verifier.Verifier, prefetcher *web3.Prefetcher, ledger storage.Ledger, record bool, logger *zap.Logger) *VerifyService {
 	logger.Debug("Verify service initialized",
 		zap.Int("verifierCount", len(verifiers)),
 	)
 
 	return &VerifyService{
-		verifiers: verifiers,
-		logger:    logger,
+		verifiers:  verifiers,
+		prefetcher: prefetcher,
+		ledger:     ledger,
+		record:     record,
+		logger:     logger,
 	}
 }
 
-// Verify verifies a payment request
+// Verify verifies a payment request and, when verifications are recorded, records the attempt in the ledger
 func (s *VerifyService) Verify(ctx context.Context, request *models.VerifyRequest) *models.VerifyResponse {
+	response := s.verify(ctx, request)
+	if !s.record {
+		return response
+	}
+
+	record := newLedgerRecord(storage.KindVerify, request.PaymentPayload, request.PaymentRequirements)
+	switch {
+	case response.IsValid:
+		record.Transition(storage.StatusVerified, "")
+	case errors.X402Error(response.InvalidReason).FacilitatorSide():
+		// The payment was not judged, so it is not recorded as invalid
+		record.Transition(storage.StatusFailed, response.InvalidReason)
+	default:
+		record.Transition(storage.StatusInvalid, response.InvalidReason)
+	}
+	saveLedgerRecord(ctx, s.ledger, record, s.logger)
+
+	return response
+}
+
+// verify runs the verifier chain on a payment request
+func (s *VerifyService) verify(ctx context.Context, request *models.VerifyRequest) *models.VerifyResponse {
 	// Run all verifiers in order, return the first failure if any
 	payer := request.PaymentPayload.Payload.Authorization.From
-	for _, v := range s.verifiers {
+	prefetched := false
+	for i, v := range s.verifiers {
 		// Check if context is cancelled
 		select {
 		case <-ctx.Done():
@@ -49,6 +82,12 @@ func (s *VerifyService) Verify(ctx context.Context, request *models.VerifyReques
 		default:
 		}
 
+		// Verifiers before the first chain reader validate the request, so only well-formed requests are prefetched
+		if _, ok := v.(verifier.ChainReader); ok && !prefetched {
+			ctx = s.prefetch(ctx, request, s.verifiers[i:])
+			prefetched = true
+		}
+
 		s.logger.Debug("Running verification",
 			zap.String("verifier", v.Type().String()),
 			zap.String("network", request.PaymentRequirements.Network),
@@ -87,3 +126,29 @@ func (s *VerifyService) Verify(ctx context.Context, request *models.VerifyReques
 		Payer:   payer,
 	}
 }
+
+// prefetch gathers the reads of the chain-reading verifiers and sends them as one batch
+// A failed prefetch is not fatal, the verifiers then read on their own
+func (s *VerifyService) prefetch(ctx context.Context, request *models.VerifyRequest, verifiers []verifier.Verifier) context.Context {
+	if s.prefetcher == nil {
+		return ctx
+	}
+
+	var calls []web3.Call
+	for _, v := range verifiers {
+		if reader, ok := v.(verifier.ChainReader); ok {
+			calls = append(calls, reader.Reads(request)...)
+		}
+	}
+
+	prefetchedCtx, err := s.prefetcher.Prefetch(ctx, request.PaymentRequirements.Network, calls)
+	if err != nil {
+		s.logger.Warn("Failed to prefetch verification reads",
+			zap.Error(err),
+			zap.String("network", request.PaymentRequirements.Network),
+			zap.String("payer", request.PaymentPayload.Payload.Authorization.From),
+		)
+		return ctx
+	}
+	return prefetchedCtx
+}
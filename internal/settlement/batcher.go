@@ -0,0 +1,378 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/web3"
+	"x402-facilitator-go/internal/web3/contract"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.uber.org/zap"
+)
+
+// batchGasMarginPercent is the margin added to a batch's gas estimate, a limit at the estimate's exact boundary
+// leaves the last sub-call short of gas once the allowFailure flags make the calldata slightly more expensive
+const batchGasMarginPercent = 10
+
+// ErrBatchCallFailed is returned for an authorization whose sub-call in a settlement batch did not succeed
+var ErrBatchCallFailed = errors.New("authorization sub-call failed in settlement batch")
+
+// Authorization is a verified EIP-3009 transferWithAuthorization call waiting to be settled
+type Authorization struct {
+	From        common.Address
+	To          common.Address
+	Value       *big.Int
+	ValidAfter  *big.Int
+	ValidBefore *big.Int
+	Nonce       [32]byte
+	Signature   []byte
+}
+
+// BatchResult is the outcome of one authorization in a settlement batch
+type BatchResult struct {
+	// TxHash is the batch transaction, zero if the authorization was never broadcast
+	TxHash common.Hash
+	// Receipt is the batch transaction's receipt, nil if it was not mined
+	Receipt       *types.Receipt
+	Confirmations uint64
+	Attempts      []TxAttempt
+	// RevertData is the sub-call's revert data when it failed while simulating the batch
+	RevertData []byte
+	Err        error
+}
+
+// Batcher gathers verified authorizations per (network, asset) for a short window and submits them
+// as one Multicall3 aggregate3 transaction, mapping each sub-call result back to its caller
+type Batcher struct {
+	web3Client   web3.Chain
+	tracker      *Tracker
+	multicall    common.Address
+	window       time.Duration
+	maxSize      int
+	privateKey   string
+	tokenABI     *abi.ABI
+	multicallABI *abi.ABI
+	logger       *zap.Logger
+
+	mu      sync.Mutex
+	pending map[batchKey]*pendingBatch
+}
+
+// batchKey identifies the batch an authorization belongs to
+type batchKey struct {
+	network string
+	asset   common.Address
+}
+
+// pendingBatch is a batch still gathering authorizations
+type pendingBatch struct {
+	entries []*batchEntry
+	timer   *time.Timer
+}
+
+// batchEntry is an authorization waiting for its batch result
+type batchEntry struct {
+	authorization Authorization
+	onMined       func()
+	result        chan BatchResult
+}
+
+// NewBatcher creates a new Batcher
+func NewBatcher(
+	cfg config.BatchingConfig,
+	web3Client web3.Chain,
+	tracker *Tracker,
+	privateKey string,
+	logger *zap.Logger,
+) (*Batcher, error) {
+	tokenABI, err := contract.EIP3009TokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse EIP3009Token ABI: %w", err)
+	}
+	multicallABI, err := contract.Multicall3MetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Multicall3 ABI: %w", err)
+	}
+
+	return &Batcher{
+		web3Client:   web3Client,
+		tracker:      tracker,
+		multicall:    common.HexToAddress(cfg.Multicall3Address),
+		window:       time.Duration(cfg.WindowMillis) * time.Millisecond,
+		maxSize:      cfg.MaxBatchSize,
+		privateKey:   privateKey,
+		tokenABI:     tokenABI,
+		multicallABI: multicallABI,
+		logger:       logger,
+		pending:      make(map[batchKey]*pendingBatch),
+	}, nil
+}
+
+// Submit adds an authorization to the current batch of its (network, asset) and waits for its result
+// onMined, when set, is called once the batch is mined with the authorization's transfer, before its confirmations
+func (b *Batcher) Submit(ctx context.Context, network string, asset common.Address, authorization Authorization, onMined func()) BatchResult {
+	entry := &batchEntry{
+		authorization: authorization,
+		onMined:       onMined,
+		result:        make(chan BatchResult, 1),
+	}
+	key := batchKey{network: network, asset: asset}
+
+	b.mu.Lock()
+	batch, ok := b.pending[key]
+	if !ok {
+		batch = &pendingBatch{}
+		b.pending[key] = batch
+		batch.timer = time.AfterFunc(b.window, func() { b.flush(key, batch) })
+	}
+	batch.entries = append(batch.entries, entry)
+	if len(batch.entries) >= b.maxSize {
+		delete(b.pending, key)
+		batch.timer.Stop()
+		go b.submit(key, batch.entries)
+	}
+	b.mu.Unlock()
+
+	select {
+	case result := <-entry.result:
+		return result
+	case <-ctx.Done():
+		return BatchResult{Err: ctx.Err()}
+	}
+}
+
+// flush submits a batch when its window elapses, unless it was already submitted for being full
+func (b *Batcher) flush(key batchKey, batch *pendingBatch) {
+	b.mu.Lock()
+	if b.pending[key] != batch {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.pending, key)
+	b.mu.Unlock()
+
+	b.submit(key, batch.entries)
+}
+
+// submit simulates the batch, drops the authorizations that would fail, broadcasts the rest
+// as one aggregate3 transaction and delivers every entry's result
+func (b *Batcher) submit(key batchKey, entries []*batchEntry) {
+	ctx := context.Background()
+	logger := b.logger.With(
+		zap.String("network", key.network),
+		zap.String("asset", key.asset.Hex()),
+		zap.Int("batchSize", len(entries)),
+	)
+
+	client, err := b.web3Client.GetClient(key.network)
+	if err != nil {
+		deliver(entries, BatchResult{Err: err})
+		return
+	}
+	chainID, err := b.web3Client.GetChainID(key.network)
+	if err != nil {
+		logger.Error("Failed to resolve chain ID of settlement batch network", zap.Error(err))
+		deliver(entries, BatchResult{Err: err})
+		return
+	}
+	confirmations, err := b.web3Client.GetConfirmations(key.network)
+	if err != nil {
+		logger.Error("Failed to resolve confirmations of settlement batch network", zap.Error(err))
+		deliver(entries, BatchResult{Err: err})
+		return
+	}
+
+	privateKey, err := crypto.HexToECDSA(b.privateKey)
+	if err != nil {
+		logger.Error("Invalid facilitator private key")
+		deliver(entries, BatchResult{Err: fmt.Errorf("invalid facilitator private key")})
+		return
+	}
+
+	calls := make([]contract.Multicall3Call3, 0, len(entries))
+	for _, entry := range entries {
+		callData, err := b.tokenABI.Pack("transferWithAuthorization",
+			entry.authorization.From,
+			entry.authorization.To,
+			entry.authorization.Value,
+			entry.authorization.ValidAfter,
+			entry.authorization.ValidBefore,
+			entry.authorization.Nonce,
+			entry.authorization.Signature,
+		)
+		if err != nil {
+			deliver(entries, BatchResult{Err: fmt.Errorf("failed to pack transferWithAuthorization: %w", err)})
+			return
+		}
+		calls = append(calls, contract.Multicall3Call3{
+			Target:       key.asset,
+			AllowFailure: true,
+			CallData:     callData,
+		})
+	}
+
+	multicall, err := contract.NewMulticall3(b.multicall, client)
+	if err != nil {
+		deliver(entries, BatchResult{Err: fmt.Errorf("failed to bind Multicall3: %w", err)})
+		return
+	}
+
+	// Simulate the batch so that authorizations that would fail are not paid for on-chain
+	var out []interface{}
+	callOpts := &bind.CallOpts{Context: ctx, From: crypto.PubkeyToAddress(privateKey.PublicKey)}
+	if err := (&contract.Multicall3Raw{Contract: multicall}).Call(callOpts, &out, "aggregate3", calls); err != nil {
+		logger.Warn("Failed to simulate settlement batch", zap.Error(err))
+		deliver(entries, BatchResult{Err: fmt.Errorf("failed to simulate settlement batch: %w", err)})
+		return
+	}
+	simulated := *abi.ConvertType(out[0], new([]contract.Multicall3Result)).(*[]contract.Multicall3Result)
+
+	passing := make([]*batchEntry, 0, len(entries))
+	passingCalls := make([]contract.Multicall3Call3, 0, len(entries))
+	latestValidBefore := new(big.Int)
+	for i, entry := range entries {
+		if !simulated[i].Success {
+			entry.result <- BatchResult{RevertData: simulated[i].ReturnData, Err: ErrBatchCallFailed}
+			continue
+		}
+		passing = append(passing, entry)
+		passingCalls = append(passingCalls, calls[i])
+		latestValidBefore = maxBig(latestValidBefore, entry.authorization.ValidBefore)
+	}
+	if len(passing) == 0 {
+		logger.Warn("Every authorization in settlement batch failed simulation")
+		return
+	}
+
+	gasLimit, err := b.estimateGas(ctx, client, callOpts.From, passingCalls)
+	if err != nil {
+		logger.Warn("Failed to estimate settlement batch gas", zap.Error(err))
+		deliver(passing, BatchResult{Err: fmt.Errorf("failed to estimate settlement batch gas: %w", err)})
+		return
+	}
+
+	transactOpts, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	if err != nil {
+		deliver(passing, BatchResult{Err: fmt.Errorf("failed to create transactor: %w", err)})
+		return
+	}
+	transactOpts.Context = ctx
+	transactOpts.GasLimit = gasLimit
+	// Sign without sending, so that the transaction hash is known before the broadcast
+	transactOpts.NoSend = true
+
+	tx, err := multicall.Aggregate3(transactOpts, passingCalls)
+	if err == nil {
+		err = client.SendTransaction(ctx, tx)
+	}
+	if err != nil && !errors.Is(err, web3.ErrBroadcastUnknown) {
+		logger.Warn("Settlement batch transaction rejected", zap.Error(err))
+		deliver(passing, BatchResult{Err: fmt.Errorf("settlement batch transaction rejected: %w", err)})
+		return
+	}
+	if err != nil {
+		// The node may have accepted the transaction, tracking its hash rebroadcasts it if it was lost
+		logger.Warn("Settlement batch broadcast outcome unknown, tracking the signed transaction",
+			zap.Error(err),
+			zap.String("txHash", tx.Hash().Hex()),
+		)
+	}
+
+	logger.Info("Settlement batch sent, waiting for confirmation",
+		zap.String("txHash", tx.Hash().Hex()),
+		zap.Int("calls", len(passingCalls)),
+	)
+
+	// The latest validBefore keeps the batch alive while any of its authorizations can still succeed
+	trackResult, err := b.tracker.Track(ctx, TrackRequest{
+		Network:       key.network,
+		Backend:       client,
+		Signer:        privateKey,
+		ChainID:       chainID,
+		Tx:            tx,
+		ValidBefore:   ValidBeforeTime(latestValidBefore),
+		Confirmations: confirmations,
+		OnMined: func(receipt *types.Receipt) {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return
+			}
+			for _, entry := range passing {
+				if entry.onMined != nil && AuthorizationUsed(receipt, key.asset, entry.authorization) {
+					entry.onMined()
+				}
+			}
+		},
+	})
+	if err != nil {
+		logger.Warn("Failed while waiting for settlement batch receipt",
+			zap.Error(err),
+			zap.String("txHash", tx.Hash().Hex()),
+		)
+		deliver(passing, BatchResult{TxHash: tx.Hash(), Attempts: trackResult.Attempts, Err: err})
+		return
+	}
+
+	receipt := trackResult.Receipt
+	for _, entry := range passing {
+		result := BatchResult{
+			TxHash:        receipt.TxHash,
+			Receipt:       receipt,
+			Confirmations: trackResult.Confirmations,
+			Attempts:      trackResult.Attempts,
+		}
+		switch {
+		case receipt.Status != types.ReceiptStatusSuccessful:
+			result.Err = ErrBatchCallFailed
+		case !AuthorizationUsed(receipt, key.asset, entry.authorization):
+			// aggregate3 allows failures, a sub-call that reverted on-chain leaves no AuthorizationUsed event
+			result.Err = ErrBatchCallFailed
+		default:
+			result.Err = VerifyReceiptEvents(receipt, key.asset, entry.authorization)
+		}
+		entry.result <- result
+	}
+
+	logger.Info("Settlement batch confirmed",
+		zap.String("txHash", receipt.TxHash.Hex()),
+		zap.Uint64("blockNumber", receipt.BlockNumber.Uint64()),
+		zap.Uint64("gasUsed", receipt.GasUsed),
+	)
+}
+
+// estimateGas returns the gas limit of an aggregate3 call of calls
+// aggregate3 lets sub-calls fail, so estimating the batch itself may settle for a limit at which the last sub-calls
+// run out of gas while the transaction succeeds. The calls are estimated as required to succeed instead,
+// with batchGasMarginPercent on top
+func (b *Batcher) estimateGas(ctx context.Context, client web3.Backend, from common.Address, calls []contract.Multicall3Call3) (uint64, error) {
+	required := make([]contract.Multicall3Call3, len(calls))
+	for i, call := range calls {
+		call.AllowFailure = false
+		required[i] = call
+	}
+	data, err := b.multicallABI.Pack("aggregate3", required)
+	if err != nil {
+		return 0, fmt.Errorf("failed to pack aggregate3: %w", err)
+	}
+	gas, err := client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &b.multicall, Data: data})
+	if err != nil {
+		return 0, err
+	}
+	return gas + gas*batchGasMarginPercent/100, nil
+}
+
+// deliver sends the same result to every entry
+func deliver(entries []*batchEntry, result BatchResult) {
+	for _, entry := range entries {
+		entry.result <- result
+	}
+}
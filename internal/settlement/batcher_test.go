@@ -0,0 +1,394 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/devchain"
+	"x402-facilitator-go/internal/util/eip3009"
+	"x402-facilitator-go/internal/web3"
+	"x402-facilitator-go/internal/web3/contract"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/jinzhu/configor"
+	"go.uber.org/zap"
+)
+
+// testNetwork is the dev network the settlement tests run on
+const testNetwork = "local"
+
+// testPayTo is the recipient of the settlement tests' transfers
+var testPayTo = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+
+// testChain is a simulated dev chain whose broadcasts and chain ID lookups tests can intercept
+type testChain struct {
+	*web3.Simulated
+	backend *web3.SimulatedBackend
+
+	mu         sync.Mutex
+	beforeSend func(tx *types.Transaction) (send bool, err error)
+	chainIDErr error
+}
+
+// newTestChain creates a fresh simulated dev chain with one network
+func newTestChain(t *testing.T) *testChain {
+	t.Helper()
+
+	backend, err := devchain.NewBackend(devchain.Facilitator().Address)
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	chain := &testChain{
+		Simulated: web3.NewSimulated(web3.SimulatedNetwork{Name: testNetwork, Backend: backend, Confirmations: 1}),
+		backend:   backend,
+	}
+	t.Cleanup(func() { chain.Close() })
+	return chain
+}
+
+// GetClient returns the network's backend with broadcasts going through the beforeSend hook
+func (c *testChain) GetClient(networkName string) (web3.Backend, error) {
+	backend, err := c.Simulated.GetClient(networkName)
+	if err != nil {
+		return nil, err
+	}
+	return &hookedBackend{Backend: backend, chain: c}, nil
+}
+
+// GetChainID fails with chainIDErr when it is set
+func (c *testChain) GetChainID(networkName string) (*big.Int, error) {
+	c.mu.Lock()
+	err := c.chainIDErr
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return c.Simulated.GetChainID(networkName)
+}
+
+// onSend sets the hook called before every broadcast, which decides whether the transaction reaches the chain
+// and what error the broadcast returns
+func (c *testChain) onSend(hook func(tx *types.Transaction) (send bool, err error)) {
+	c.mu.Lock()
+	c.beforeSend = hook
+	c.mu.Unlock()
+}
+
+// hookedBackend is a backend calling its chain's beforeSend hook before each broadcast
+type hookedBackend struct {
+	web3.Backend
+	chain *testChain
+}
+
+// SendTransaction calls the beforeSend hook and broadcasts the transaction when the hook lets it through
+func (b *hookedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.chain.mu.Lock()
+	hook := b.chain.beforeSend
+	b.chain.mu.Unlock()
+	if hook == nil {
+		return b.Backend.SendTransaction(ctx, tx)
+	}
+	send, err := hook(tx)
+	if send {
+		if sendErr := b.Backend.SendTransaction(ctx, tx); sendErr != nil {
+			return sendErr
+		}
+	}
+	return err
+}
+
+// testConfig returns the configuration defaults
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	cfg := &config.Config{}
+	if err := configor.Load(cfg); err != nil {
+		t.Fatalf("load config defaults: %v", err)
+	}
+	return cfg
+}
+
+// newTestTracker creates a Tracker over chain with its confirmation watcher running until the test ends
+func newTestTracker(t *testing.T, chain web3.Chain, cfg config.SettlementConfig) *Tracker {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	watcher := NewConfirmationWatcher(chain, cfg, zap.NewNop())
+	go watcher.Run(ctx)
+	return NewTracker(cfg, watcher, web3.SystemClock{}, zap.NewNop())
+}
+
+// newTestBatcher creates a Batcher settling on chain with the given window and maximum batch size
+func newTestBatcher(t *testing.T, chain *testChain, window time.Duration, maxSize int) *Batcher {
+	t.Helper()
+
+	cfg := testConfig(t)
+	cfg.Settlement.Batching.WindowMillis = int(window / time.Millisecond)
+	cfg.Settlement.Batching.MaxBatchSize = maxSize
+	batcher, err := NewBatcher(cfg.Settlement.Batching, chain, newTestTracker(t, chain, cfg.Settlement),
+		devchain.Facilitator().PrivateKey, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new batcher: %v", err)
+	}
+	return batcher
+}
+
+// signAuthorization signs a transfer of value test token units from payer to testPayTo with signer's key
+func signAuthorization(t *testing.T, payer, signer devchain.Account, value int64, nonce common.Hash) Authorization {
+	t.Helper()
+
+	validAfter := big.NewInt(time.Now().Add(-time.Hour).Unix())
+	validBefore := big.NewInt(time.Now().Add(time.Hour).Unix())
+	hash := eip3009.ComputeTransferWithAuthorizationHash(eip3009.TransferWithAuthorizationParams{
+		ChainId:           big.NewInt(devchain.ChainID),
+		VerifyingContract: devchain.TokenAddress.Hex(),
+		DomainName:        devchain.TokenName,
+		DomainVersion:     devchain.TokenVersion,
+		From:              payer.Address.Hex(),
+		To:                testPayTo.Hex(),
+		Value:             big.NewInt(value).String(),
+		ValidAfter:        validAfter.String(),
+		ValidBefore:       validBefore.String(),
+		Nonce:             nonce.Hex(),
+	})
+	key, err := crypto.HexToECDSA(signer.PrivateKey)
+	if err != nil {
+		t.Fatalf("signer key: %v", err)
+	}
+	signature, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatalf("sign authorization: %v", err)
+	}
+	signature[64] += 27
+
+	return Authorization{
+		From:        payer.Address,
+		To:          testPayTo,
+		Value:       big.NewInt(value),
+		ValidAfter:  validAfter,
+		ValidBefore: validBefore,
+		Nonce:       nonce,
+		Signature:   signature,
+	}
+}
+
+// submitAll submits the authorizations concurrently and returns their results in order
+func submitAll(t *testing.T, batcher *Batcher, authorizations ...Authorization) []BatchResult {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	results := make([]BatchResult, len(authorizations))
+	var wg sync.WaitGroup
+	for i, authorization := range authorizations {
+		wg.Add(1)
+		go func(i int, authorization Authorization) {
+			defer wg.Done()
+			results[i] = batcher.Submit(ctx, testNetwork, devchain.TokenAddress, authorization, nil)
+		}(i, authorization)
+	}
+	wg.Wait()
+	return results
+}
+
+// tokenBalance returns the test token balance of account
+func tokenBalance(t *testing.T, chain *testChain, account common.Address) *big.Int {
+	t.Helper()
+
+	erc20, err := contract.NewERC20(devchain.TokenAddress, chain.backend)
+	if err != nil {
+		t.Fatalf("bind erc20: %v", err)
+	}
+	balance, err := erc20.BalanceOf(&bind.CallOpts{}, account)
+	if err != nil {
+		t.Fatalf("balanceOf: %v", err)
+	}
+	return balance
+}
+
+func TestBatcherMapsEveryAuthorizationToItsResult(t *testing.T) {
+	chain := newTestChain(t)
+	batcher := newTestBatcher(t, chain, time.Hour, 3)
+	payers := devchain.Payers()
+
+	results := submitAll(t, batcher,
+		signAuthorization(t, payers[0], payers[0], 100, common.HexToHash("0x01")),
+		signAuthorization(t, payers[1], payers[1], 200, common.HexToHash("0x02")),
+		signAuthorization(t, payers[2], payers[2], 300, common.HexToHash("0x03")),
+	)
+
+	for i, result := range results {
+		if result.Err != nil {
+			t.Fatalf("result %d: %v", i, result.Err)
+		}
+		if result.Receipt == nil || result.Receipt.Status != types.ReceiptStatusSuccessful {
+			t.Fatalf("result %d: want a successful receipt, got %+v", i, result.Receipt)
+		}
+		if result.TxHash != results[0].TxHash {
+			t.Fatalf("result %d: want the batch transaction %s, got %s", i, results[0].TxHash, result.TxHash)
+		}
+	}
+	if got := tokenBalance(t, chain, testPayTo); got.Cmp(big.NewInt(600)) != 0 {
+		t.Fatalf("want recipient balance 600, got %s", got)
+	}
+}
+
+func TestBatcherDropsAuthorizationsFailingSimulation(t *testing.T) {
+	chain := newTestChain(t)
+	batcher := newTestBatcher(t, chain, time.Hour, 3)
+	payers := devchain.Payers()
+	valid := signAuthorization(t, payers[0], payers[0], 100, common.HexToHash("0x01"))
+
+	results := submitAll(t, batcher,
+		valid,
+		// Signed by another account than the payer
+		signAuthorization(t, payers[1], payers[2], 200, common.HexToHash("0x02")),
+		// Replays the first authorization, which the simulation has already used
+		valid,
+	)
+
+	if results[0].Err != nil && results[2].Err != nil {
+		t.Fatalf("want one of the duplicate authorizations settled, got %v and %v", results[0].Err, results[2].Err)
+	}
+	settled, replayed := results[0], results[2]
+	if settled.Err != nil {
+		settled, replayed = replayed, settled
+	}
+	if settled.Receipt == nil {
+		t.Fatalf("want the settled authorization's receipt")
+	}
+	for _, tc := range []struct {
+		name   string
+		result BatchResult
+		reason string
+	}{
+		{"invalid signature", results[1], "invalid signature"},
+		{"replayed authorization", replayed, "authorization is used"},
+	} {
+		if !errors.Is(tc.result.Err, ErrBatchCallFailed) {
+			t.Fatalf("%s: want ErrBatchCallFailed, got %v", tc.name, tc.result.Err)
+		}
+		if tc.result.TxHash != (common.Hash{}) || tc.result.Receipt != nil {
+			t.Fatalf("%s: want no broadcast, got transaction %s", tc.name, tc.result.TxHash)
+		}
+		if reason := DecodeRevertData(tc.result.RevertData); !strings.Contains(reason, tc.reason) {
+			t.Fatalf("%s: want revert reason containing %q, got %q", tc.name, tc.reason, reason)
+		}
+	}
+	if got := tokenBalance(t, chain, testPayTo); got.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("want recipient balance 100, got %s", got)
+	}
+}
+
+func TestBatcherReportsSubCallRevertedOnChain(t *testing.T) {
+	chain := newTestChain(t)
+	batcher := newTestBatcher(t, chain, time.Hour, 2)
+	payers := devchain.Payers()
+	kept := signAuthorization(t, payers[0], payers[0], 100, common.HexToHash("0x01"))
+	frontRun := signAuthorization(t, payers[1], payers[1], 200, common.HexToHash("0x02"))
+
+	// The payer settles the second authorization itself between the simulation and the batch broadcast
+	var once sync.Once
+	chain.onSend(func(*types.Transaction) (bool, error) {
+		var err error
+		once.Do(func() {
+			token, bindErr := contract.NewEIP3009Token(devchain.TokenAddress, chain.backend)
+			if bindErr != nil {
+				err = bindErr
+				return
+			}
+			key, keyErr := crypto.HexToECDSA(payers[1].PrivateKey)
+			if keyErr != nil {
+				err = keyErr
+				return
+			}
+			opts, optsErr := bind.NewKeyedTransactorWithChainID(key, big.NewInt(devchain.ChainID))
+			if optsErr != nil {
+				err = optsErr
+				return
+			}
+			_, err = token.TransferWithAuthorization(opts, frontRun.From, frontRun.To, frontRun.Value,
+				frontRun.ValidAfter, frontRun.ValidBefore, frontRun.Nonce, frontRun.Signature)
+		})
+		return err == nil, err
+	})
+
+	results := submitAll(t, batcher, kept, frontRun)
+
+	if results[0].Err != nil {
+		t.Fatalf("kept authorization: %v", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, ErrBatchCallFailed) {
+		t.Fatalf("front-run authorization: want ErrBatchCallFailed, got %v", results[1].Err)
+	}
+	if results[1].Receipt == nil || results[1].Receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("front-run authorization: want the successful batch receipt, got %+v", results[1].Receipt)
+	}
+	if results[1].TxHash != results[0].TxHash {
+		t.Fatalf("want both authorizations in the batch transaction %s, got %s", results[0].TxHash, results[1].TxHash)
+	}
+}
+
+func TestBatcherFlushes(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		window  time.Duration
+		maxSize int
+		count   int
+	}{
+		{"when full before the window elapses", time.Hour, 2, 2},
+		{"when the window elapses before it is full", 50 * time.Millisecond, 50, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			chain := newTestChain(t)
+			batcher := newTestBatcher(t, chain, tc.window, tc.maxSize)
+			payers := devchain.Payers()
+
+			authorizations := make([]Authorization, 0, tc.count)
+			for i := 0; i < tc.count; i++ {
+				authorizations = append(authorizations,
+					signAuthorization(t, payers[i], payers[i], 100, common.BigToHash(big.NewInt(int64(i+1)))))
+			}
+			results := submitAll(t, batcher, authorizations...)
+
+			for i, result := range results {
+				if result.Err != nil {
+					t.Fatalf("result %d: %v", i, result.Err)
+				}
+				if result.TxHash != results[0].TxHash {
+					t.Fatalf("result %d: want one batch transaction %s, got %s", i, results[0].TxHash, result.TxHash)
+				}
+			}
+		})
+	}
+}
+
+func TestBatcherDeliversNetworkErrorsToEveryEntry(t *testing.T) {
+	chain := newTestChain(t)
+	chainIDErr := errors.New("chain ID not configured")
+	chain.chainIDErr = chainIDErr
+	batcher := newTestBatcher(t, chain, time.Hour, 2)
+	payers := devchain.Payers()
+
+	results := submitAll(t, batcher,
+		signAuthorization(t, payers[0], payers[0], 100, common.HexToHash("0x01")),
+		signAuthorization(t, payers[1], payers[1], 100, common.HexToHash("0x02")),
+	)
+
+	for i, result := range results {
+		if !errors.Is(result.Err, chainIDErr) {
+			t.Fatalf("result %d: want the chain ID error, got %v", i, result.Err)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/web3"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxReceiptBatch caps the number of receipts fetched in one JSON-RPC batch
+	maxReceiptBatch = 100
+	// resubscribeInterval is how long a network polls for heads before subscribing again
+	resubscribeInterval = 30 * time.Second
+)
+
+// ReceiptUpdate is the state of a watched transaction at a chain head
+type ReceiptUpdate struct {
+	// Receipt is the receipt of the first mined of the watched hashes, nil while none is mined
+	Receipt *types.Receipt
+	// Head is the number of the chain head the receipt was fetched at
+	Head uint64
+}
+
+// ReceiptWatch receives the receipt updates of one settlement transaction and its replacements
+type ReceiptWatch struct {
+	network string
+	watcher *ConfirmationWatcher
+	updates chan ReceiptUpdate
+
+	mu     sync.Mutex
+	hashes []common.Hash
+}
+
+// Updates returns the channel the watch's updates are sent on, only the latest update is kept
+func (w *ReceiptWatch) Updates() <-chan ReceiptUpdate {
+	return w.updates
+}
+
+// Add watches another hash of the transaction, such as a fee-bumped replacement
+func (w *ReceiptWatch) Add(hash common.Hash) {
+	w.mu.Lock()
+	w.hashes = append(w.hashes, hash)
+	w.mu.Unlock()
+
+	w.watcher.refresh(w.network)
+}
+
+// Close stops the watch
+func (w *ReceiptWatch) Close() {
+	w.watcher.unwatch(w)
+}
+
+// watched returns the hashes of the watch
+func (w *ReceiptWatch) watched() []common.Hash {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return append([]common.Hash(nil), w.hashes...)
+}
+
+// deliver replaces any unread update with update
+func (w *ReceiptWatch) deliver(update ReceiptUpdate) {
+	select {
+	case <-w.updates:
+	default:
+	}
+	w.updates <- update
+}
+
+// networkWatches are the watches of one network
+type networkWatches struct {
+	watches map[*ReceiptWatch]struct{}
+	// wake asks the network's follower to check the watches without waiting for the next head
+	wake chan struct{}
+}
+
+// ConfirmationWatcher follows the heads of every network and fetches the receipts of all pending settlement
+// transactions of a network in one batch per new head
+// Heads come from a new-heads subscription where the network has a WebSocket endpoint, otherwise from polling
+// the block number while transactions are pending
+type ConfirmationWatcher struct {
+	web3Client   web3.Chain
+	pollInterval time.Duration
+	logger       *zap.Logger
+
+	mu       sync.Mutex
+	networks map[string]*networkWatches
+}
+
+// NewConfirmationWatcher creates a new ConfirmationWatcher
+func NewConfirmationWatcher(web3Client web3.Chain, cfg config.SettlementConfig, logger *zap.Logger) *ConfirmationWatcher {
+	networks := make(map[string]*networkWatches)
+	for _, network := range web3Client.Networks() {
+		networks[network] = &networkWatches{
+			watches: make(map[*ReceiptWatch]struct{}),
+			wake:    make(chan struct{}, 1),
+		}
+	}
+
+	return &ConfirmationWatcher{
+		web3Client:   web3Client,
+		pollInterval: time.Duration(cfg.ReceiptPollIntervalMillis) * time.Millisecond,
+		logger:       logger,
+		networks:     networks,
+	}
+}
+
+// Watch starts watching the receipts of a transaction broadcast on network
+// The watch receives an update on every new head until it is closed
+func (w *ConfirmationWatcher) Watch(network string, hashes ...common.Hash) *ReceiptWatch {
+	watch := &ReceiptWatch{
+		network: network,
+		watcher: w,
+		updates: make(chan ReceiptUpdate, 1),
+		hashes:  append([]common.Hash(nil), hashes...),
+	}
+
+	w.mu.Lock()
+	if watches, ok := w.networks[network]; ok {
+		watches.watches[watch] = struct{}{}
+	}
+	w.mu.Unlock()
+
+	w.refresh(network)
+	return watch
+}
+
+// unwatch removes a watch
+func (w *ConfirmationWatcher) unwatch(watch *ReceiptWatch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if watches, ok := w.networks[watch.network]; ok {
+		delete(watches.watches, watch)
+	}
+}
+
+// refresh wakes the follower of network so that newly watched hashes are checked at the current head
+func (w *ConfirmationWatcher) refresh(network string) {
+	w.mu.Lock()
+	watches, ok := w.networks[network]
+	w.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case watches.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pending returns the watches of network
+func (w *ConfirmationWatcher) pending(network string) []*ReceiptWatch {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	watches := make([]*ReceiptWatch, 0, len(w.networks[network].watches))
+	for watch := range w.networks[network].watches {
+		watches = append(watches, watch)
+	}
+	return watches
+}
+
+// Run follows the heads of every network until ctx is cancelled
+func (w *ConfirmationWatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for network := range w.networks {
+		wg.Add(1)
+		go func(network string) {
+			defer wg.Done()
+			w.follow(ctx, network)
+		}(network)
+	}
+	wg.Wait()
+}
+
+// follow subscribes to the new heads of network, polling for them while no subscription can be made
+func (w *ConfirmationWatcher) follow(ctx context.Context, network string) {
+	for ctx.Err() == nil {
+		err := w.subscribe(ctx, network)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+			w.logger.Debug("New-heads subscription unsupported, polling for new heads",
+				zap.String("network", network),
+			)
+		} else {
+			w.logger.Warn("New-heads subscription failed, polling for new heads",
+				zap.Error(err),
+				zap.String("network", network),
+			)
+		}
+		w.poll(ctx, network, resubscribeInterval)
+	}
+}
+
+// subscribe checks the watches of network on every head of a new-heads subscription until it fails
+func (w *ConfirmationWatcher) subscribe(ctx context.Context, network string) error {
+	client, err := w.web3Client.GetClient(network)
+	if err != nil {
+		return err
+	}
+
+	headers := make(chan *types.Header, 16)
+	sub, err := client.SubscribeNewHead(ctx, headers)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	w.logger.Info("Following new heads", zap.String("network", network))
+	wake := w.networks[network].wake
+	var head uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-sub.Err():
+			return err
+		case header := <-headers:
+			head = header.Number.Uint64()
+			w.check(ctx, network, client, head)
+		case <-wake:
+			if head == 0 {
+				if head, err = client.BlockNumber(ctx); err != nil {
+					head = 0
+					continue
+				}
+			}
+			w.check(ctx, network, client, head)
+		}
+	}
+}
+
+// poll checks the watches of network whenever its block number advances, for the given duration
+// The block number is only polled while the network has watches
+func (w *ConfirmationWatcher) poll(ctx context.Context, network string, duration time.Duration) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(duration)
+	defer deadline.Stop()
+
+	wake := w.networks[network].wake
+	var lastHead uint64
+	for {
+		woken := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-wake:
+			woken = true
+		case <-ticker.C:
+		}
+
+		if len(w.pending(network)) == 0 {
+			continue
+		}
+		client, err := w.web3Client.GetClient(network)
+		if err != nil {
+			continue
+		}
+		head, err := client.BlockNumber(ctx)
+		if err != nil {
+			w.logger.Debug("Failed to fetch block number",
+				zap.Error(err),
+				zap.String("network", network),
+			)
+			continue
+		}
+		if head != lastHead || woken {
+			lastHead = head
+			w.check(ctx, network, client, head)
+		}
+	}
+}
+
+// check fetches the receipts of every watched hash of network and sends each watch its update at head
+func (w *ConfirmationWatcher) check(ctx context.Context, network string, client web3.Backend, head uint64) {
+	watches := w.pending(network)
+	if len(watches) == 0 {
+		return
+	}
+
+	seen := make(map[common.Hash]bool)
+	hashes := make([]common.Hash, 0, len(watches))
+	for _, watch := range watches {
+		for _, hash := range watch.watched() {
+			if !seen[hash] {
+				seen[hash] = true
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+
+	receipts := make(map[common.Hash]*types.Receipt, len(hashes))
+	for start := 0; start < len(hashes); start += maxReceiptBatch {
+		end := start + maxReceiptBatch
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		batch, err := client.TransactionReceipts(ctx, hashes[start:end])
+		if err != nil {
+			w.logger.Debug("Failed to fetch settlement transaction receipts",
+				zap.Error(err),
+				zap.String("network", network),
+				zap.Int("transactions", end-start),
+			)
+			return
+		}
+		for i, receipt := range batch {
+			receipts[hashes[start+i]] = receipt
+		}
+	}
+
+	for _, watch := range watches {
+		update := ReceiptUpdate{Head: head}
+		for _, hash := range watch.watched() {
+			if receipt := receipts[hash]; receipt != nil {
+				update.Receipt = receipt
+				break
+			}
+		}
+		watch.deliver(update)
+	}
+}
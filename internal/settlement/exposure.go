@@ -0,0 +1,175 @@
+package settlement
+
+import (
+	"expvar"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+	"x402-facilitator-go/internal/models"
+)
+
+// exposureSweepInterval bounds how often expired holds are purged
+const exposureSweepInterval = time.Minute
+
+// heldMetric is the number of authorizations currently held against their payers' balances
+var heldMetric = expvar.NewInt("exposure_holds")
+
+// Hold is the value of one authorization counted against its payer's balance until it is settled
+type Hold struct {
+	Network string
+	Asset   string
+	Payer   string
+	Nonce   string
+	Value   *big.Int
+	// ValidBefore is when the authorization expires, no hold outlives it
+	ValidBefore time.Time
+}
+
+// NewHold describes the hold of a payment's authorization
+func NewHold(requirements models.PaymentRequirements, authorization models.Authorization) Hold {
+	value, ok := new(big.Int).SetString(authorization.Value, 10)
+	if !ok {
+		value = new(big.Int)
+	}
+	validBefore, _ := new(big.Int).SetString(authorization.ValidBefore, 10)
+	return Hold{
+		Network:     requirements.Network,
+		Asset:       requirements.Asset,
+		Payer:       authorization.From,
+		Nonce:       authorization.Nonce,
+		Value:       value,
+		ValidBefore: ValidBeforeTime(validBefore),
+	}
+}
+
+// account identifies the balance a hold is counted against by (network, asset, payer)
+func (h Hold) account() string {
+	return strings.Join([]string{h.Network, strings.ToLower(h.Asset), strings.ToLower(h.Payer)}, ":")
+}
+
+// heldAuthorization is a hold being counted
+type heldAuthorization struct {
+	value     *big.Int
+	settling  bool
+	expiresAt time.Time
+}
+
+// Exposure tracks the value of verified but unsettled and in-flight authorizations per (network, asset, payer),
+// so that a balance covering one payment does not pass verification for several payments at the same time
+// Verified holds expire after the hold TTL unless settlement starts, settling holds when released or at
+// the authorization's validBefore
+type Exposure struct {
+	holdTTL time.Duration
+
+	mu        sync.Mutex
+	accounts  map[string]map[string]*heldAuthorization
+	lastSweep time.Time
+}
+
+// NewExposure creates a new Exposure holding verified authorizations for holdTTL
+func NewExposure(holdTTL time.Duration) *Exposure {
+	return &Exposure{
+		holdTTL:   holdTTL,
+		accounts:  make(map[string]map[string]*heldAuthorization),
+		lastSweep: time.Now(),
+	}
+}
+
+// Reserve holds a verified authorization when balance covers its value on top of the payer's other holds
+// It returns the value of the other holds, and false without holding anything when balance does not cover both
+// Reserving an authorization again refreshes its hold instead of counting it twice
+func (e *Exposure) Reserve(hold Hold, balance *big.Int) (*big.Int, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sweep()
+
+	now := time.Now()
+	pending := new(big.Int)
+	for nonce, held := range e.accounts[hold.account()] {
+		if nonce != strings.ToLower(hold.Nonce) && now.Before(held.expiresAt) {
+			pending.Add(pending, held.value)
+		}
+	}
+	if balance.Cmp(new(big.Int).Add(pending, hold.Value)) < 0 {
+		return pending, false
+	}
+
+	held := e.held(hold)
+	if !held.settling {
+		held.expiresAt = earliest(now.Add(e.holdTTL), hold.ValidBefore)
+	}
+	return pending, true
+}
+
+// Settling keeps the hold of an authorization whose settlement started until it is released or expires
+func (e *Exposure) Settling(hold Hold) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	held := e.held(hold)
+	held.settling = true
+	held.expiresAt = hold.ValidBefore
+}
+
+// Release drops the hold of an authorization once its settlement completed or failed
+func (e *Exposure) Release(hold Hold) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	account := hold.account()
+	if _, ok := e.accounts[account][strings.ToLower(hold.Nonce)]; !ok {
+		return
+	}
+	delete(e.accounts[account], strings.ToLower(hold.Nonce))
+	if len(e.accounts[account]) == 0 {
+		delete(e.accounts, account)
+	}
+	heldMetric.Add(-1)
+}
+
+// held returns the counted hold of an authorization, adding it with its value when missing
+// Callers must hold the lock
+func (e *Exposure) held(hold Hold) *heldAuthorization {
+	account := hold.account()
+	if e.accounts[account] == nil {
+		e.accounts[account] = make(map[string]*heldAuthorization)
+	}
+	held, ok := e.accounts[account][strings.ToLower(hold.Nonce)]
+	if !ok {
+		held = &heldAuthorization{}
+		e.accounts[account][strings.ToLower(hold.Nonce)] = held
+		heldMetric.Add(1)
+	}
+	held.value = hold.Value
+	return held
+}
+
+// sweep purges expired holds, callers must hold the lock
+func (e *Exposure) sweep() {
+	now := time.Now()
+	if now.Sub(e.lastSweep) < exposureSweepInterval {
+		return
+	}
+	e.lastSweep = now
+
+	for account, holds := range e.accounts {
+		for nonce, held := range holds {
+			if !now.Before(held.expiresAt) {
+				delete(holds, nonce)
+				heldMetric.Add(-1)
+			}
+		}
+		if len(holds) == 0 {
+			delete(e.accounts, account)
+		}
+	}
+}
+
+// earliest returns the earlier of a and b
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
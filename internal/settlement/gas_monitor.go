@@ -0,0 +1,191 @@
+package settlement
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/web3"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.uber.org/zap"
+)
+
+var (
+	// gasBalanceMetric exposes the settlement signer's native balance in wei per network
+	gasBalanceMetric = expvar.NewMap("settlement_gas_balance_wei")
+	// gasFundedMetric exposes 1 per network while the settlement signer is funded above its threshold, 0 otherwise
+	gasFundedMetric = expvar.NewMap("settlement_gas_funded")
+)
+
+// GasBalance is the latest native balance check of the settlement signer on a network
+type GasBalance struct {
+	Network   string    `json:"network"`
+	Address   string    `json:"address"`
+	Balance   string    `json:"balance,omitempty"`
+	Threshold string    `json:"threshold"`
+	Funded    bool      `json:"funded"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// GasMonitor periodically checks the settlement signer's native balance on every network
+// Networks whose balance is below their configured minimum are reported as underfunded
+type GasMonitor struct {
+	web3Client web3.Chain
+	address    common.Address
+	interval   time.Duration
+	logger     *zap.Logger
+
+	mu       sync.RWMutex
+	balances map[string]GasBalance
+}
+
+// NewGasMonitor creates a new GasMonitor for the signer of privateKey
+func NewGasMonitor(web3Client web3.Chain, cfg config.GasMonitorConfig, privateKey string, logger *zap.Logger) (*GasMonitor, error) {
+	key, err := crypto.HexToECDSA(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid facilitator private key: %w", err)
+	}
+
+	return &GasMonitor{
+		web3Client: web3Client,
+		address:    crypto.PubkeyToAddress(key.PublicKey),
+		interval:   time.Duration(cfg.CheckIntervalSeconds) * time.Second,
+		logger:     logger,
+		balances:   make(map[string]GasBalance),
+	}, nil
+}
+
+// Run checks balances immediately and then on every interval until ctx is cancelled
+func (m *GasMonitor) Run(ctx context.Context) {
+	m.checkAll(ctx)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkAll(ctx)
+		}
+	}
+}
+
+// Funded reports whether the settlement signer can pay for gas on the network
+// Networks not checked yet, or whose last check failed, keep their previous state and start out funded
+func (m *GasMonitor) Funded(network string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	balance, ok := m.balances[network]
+	return !ok || balance.Funded
+}
+
+// Balances returns the latest balance check of every network
+func (m *GasMonitor) Balances() []GasBalance {
+	balances := make([]GasBalance, 0)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, network := range m.web3Client.Networks() {
+		if balance, ok := m.balances[network]; ok {
+			balances = append(balances, balance)
+		}
+	}
+	return balances
+}
+
+// checkAll checks the balance on every configured network
+func (m *GasMonitor) checkAll(ctx context.Context) {
+	for _, network := range m.web3Client.Networks() {
+		if ctx.Err() != nil {
+			return
+		}
+		m.check(ctx, network)
+	}
+}
+
+// check updates the balance of the settlement signer on a network
+func (m *GasMonitor) check(ctx context.Context, network string) {
+	threshold, _ := m.web3Client.GetMinGasBalance(network)
+
+	m.mu.RLock()
+	previous, checked := m.balances[network]
+	m.mu.RUnlock()
+
+	current := GasBalance{
+		Network:   network,
+		Address:   m.address.Hex(),
+		Threshold: threshold.String(),
+		Funded:    !checked || previous.Funded,
+		CheckedAt: time.Now().UTC(),
+	}
+
+	balance, err := m.balanceAt(ctx, network)
+	if err != nil {
+		m.logger.Warn("Failed to check settlement signer gas balance",
+			zap.Error(err),
+			zap.String("network", network),
+			zap.String("address", m.address.Hex()),
+		)
+		current.Balance = previous.Balance
+		current.Error = err.Error()
+	} else {
+		current.Balance = balance.String()
+		current.Funded = balance.Cmp(threshold) >= 0
+		gasBalanceMetric.Set(network, floatVar(new(big.Float).SetInt(balance)))
+	}
+
+	funded := new(expvar.Int)
+	if current.Funded {
+		funded.Set(1)
+	}
+	gasFundedMetric.Set(network, funded)
+
+	switch {
+	case !current.Funded && (!checked || previous.Funded):
+		m.logger.Error("Settlement signer gas balance below threshold, network disabled for settlement",
+			zap.String("network", network),
+			zap.String("address", m.address.Hex()),
+			zap.String("balance", current.Balance),
+			zap.String("threshold", current.Threshold),
+		)
+	case checked && !previous.Funded && current.Funded:
+		m.logger.Info("Settlement signer gas balance restored, network enabled for settlement",
+			zap.String("network", network),
+			zap.String("address", m.address.Hex()),
+			zap.String("balance", current.Balance),
+		)
+	}
+
+	m.mu.Lock()
+	m.balances[network] = current
+	m.mu.Unlock()
+}
+
+// balanceAt returns the settlement signer's latest native balance on a network
+func (m *GasMonitor) balanceAt(ctx context.Context, network string) (*big.Int, error) {
+	client, err := m.web3Client.GetClient(network)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.interval)
+	defer cancel()
+	return client.BalanceAt(ctx, m.address, nil)
+}
+
+// floatVar converts a balance to an expvar value
+func floatVar(value *big.Float) *expvar.Float {
+	f, _ := value.Float64()
+	v := new(expvar.Float)
+	v.Set(f)
+	return v
+}
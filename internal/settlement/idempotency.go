@@ -0,0 +1,231 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"x402-facilitator-go/internal/models"
+)
+
+// ErrIdempotencyKeyReused is returned when an Idempotency-Key is presented with a different authorization
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different authorization")
+
+// ErrRequirementsMismatch is returned when an authorization already settled or being settled is presented
+// with different payment requirements, so that another resource server is never told it was paid
+var ErrRequirementsMismatch = errors.New("authorization was settled for different payment requirements")
+
+// idempotencySweepInterval bounds how often expired outcomes are purged
+const idempotencySweepInterval = time.Minute
+
+// Outcome tells what repeats of a settlement attempt get
+type Outcome int
+
+const (
+	// OutcomeRetry means nothing was broadcast, a repeat settles again
+	OutcomeRetry Outcome = iota
+	// OutcomePending means the payment was queued or broadcast but is not settled yet, repeats get the pending
+	// response until Resolve records the outcome
+	OutcomePending
+	// OutcomeFinal means the outcome is known, repeats get it until it expires
+	OutcomeFinal
+)
+
+// SettleFunc performs one settlement attempt, passing broadcast its pending response once its transaction is sent
+type SettleFunc func(broadcast func(pending *models.SettleResponse)) (*models.SettleResponse, Outcome)
+
+// Idempotency makes settlement idempotent on the authorization and on an optional Idempotency-Key
+// Attempts run in the background, concurrent duplicates wait on the in-flight attempt and later repeats get the
+// recorded outcome
+type Idempotency struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	calls     map[string]*idempotentCall
+	keys      map[string]string
+	lastSweep time.Time
+}
+
+// idempotentCall is an in-flight or completed settlement attempt
+type idempotentCall struct {
+	done     chan struct{}
+	response *models.SettleResponse
+	outcome  Outcome
+	// pending is the attempt's response once its transaction was broadcast, for callers giving up on the outcome
+	pending *models.SettleResponse
+	// resolved is the outcome recorded by Resolve while the attempt was still running
+	resolved       *models.SettleResponse
+	resolvedAs     Outcome
+	fingerprint    string
+	idempotencyKey string
+	expiresAt      time.Time
+}
+
+// NewIdempotency creates a new Idempotency that retains final and pending outcomes for ttl
+func NewIdempotency(ttl time.Duration) *Idempotency {
+	return &Idempotency{
+		ttl:       ttl,
+		calls:     make(map[string]*idempotentCall),
+		keys:      make(map[string]string),
+		lastSweep: time.Now(),
+	}
+}
+
+// AuthorizationKey identifies an EIP-3009 authorization by (network, asset, from, nonce)
+func AuthorizationKey(network, asset, from, nonce string) string {
+	return strings.Join([]string{
+		network,
+		strings.ToLower(asset),
+		strings.ToLower(from),
+		strings.ToLower(nonce),
+	}, ":")
+}
+
+// RequirementsFingerprint identifies the payment requirements an authorization is settled for
+func RequirementsFingerprint(requirements models.PaymentRequirements) string {
+	return strings.Join([]string{
+		requirements.Scheme,
+		requirements.Network,
+		strings.ToLower(requirements.Asset),
+		strings.ToLower(requirements.PayTo),
+		requirements.MaxAmountRequired,
+		requirements.Resource,
+	}, "|")
+}
+
+// Do runs settle unless an attempt for the same authorization or Idempotency-Key is in flight or recorded,
+// in which case it waits for and returns that attempt's outcome
+// An attempt is only shared with requests carrying the same requirements fingerprint, others fail with
+// ErrRequirementsMismatch. The attempt does not belong to any caller: a caller whose ctx is done first gets the
+// attempt's pending response once its transaction was broadcast, or ctx's error, while the attempt carries on
+func (i *Idempotency) Do(ctx context.Context, authorizationKey, fingerprint, idempotencyKey string, settle SettleFunc) (*models.SettleResponse, error) {
+	i.mu.Lock()
+	i.sweep()
+
+	if idempotencyKey != "" {
+		if existing, ok := i.keys[idempotencyKey]; ok && existing != authorizationKey {
+			i.mu.Unlock()
+			return nil, ErrIdempotencyKeyReused
+		}
+	}
+
+	if call, ok := i.calls[authorizationKey]; ok {
+		if call.fingerprint != fingerprint {
+			i.mu.Unlock()
+			return nil, ErrRequirementsMismatch
+		}
+		if idempotencyKey != "" && call.idempotencyKey == "" {
+			call.idempotencyKey = idempotencyKey
+			i.keys[idempotencyKey] = authorizationKey
+		}
+		i.mu.Unlock()
+		return i.wait(ctx, call)
+	}
+
+	call := &idempotentCall{
+		done:           make(chan struct{}),
+		fingerprint:    fingerprint,
+		idempotencyKey: idempotencyKey,
+	}
+	i.calls[authorizationKey] = call
+	if idempotencyKey != "" {
+		i.keys[idempotencyKey] = authorizationKey
+	}
+	i.mu.Unlock()
+
+	go i.run(authorizationKey, call, settle)
+	return i.wait(ctx, call)
+}
+
+// run performs the attempt of call and records its outcome
+func (i *Idempotency) run(authorizationKey string, call *idempotentCall, settle SettleFunc) {
+	response, outcome := settle(func(pending *models.SettleResponse) {
+		i.mu.Lock()
+		call.pending = pending
+		i.mu.Unlock()
+	})
+
+	i.mu.Lock()
+	if outcome == OutcomePending && call.resolved != nil {
+		response, outcome = call.resolved, call.resolvedAs
+	}
+	i.record(authorizationKey, call, response, outcome)
+	i.mu.Unlock()
+	close(call.done)
+}
+
+// Resolve records the outcome of a pending attempt once it is known
+// A retry outcome forgets the attempt, so that a repeat settles the payment again
+func (i *Idempotency) Resolve(authorizationKey string, response *models.SettleResponse, outcome Outcome) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	call, ok := i.calls[authorizationKey]
+	if !ok {
+		return
+	}
+	select {
+	case <-call.done:
+		if call.outcome == OutcomePending {
+			i.record(authorizationKey, call, response, outcome)
+		}
+	default:
+		// The attempt has not returned its pending response yet, it takes the resolved one instead
+		call.resolved = response
+		call.resolvedAs = outcome
+	}
+}
+
+// record sets the outcome of call, callers must hold the lock
+func (i *Idempotency) record(authorizationKey string, call *idempotentCall, response *models.SettleResponse, outcome Outcome) {
+	call.response = response
+	call.outcome = outcome
+	call.expiresAt = time.Now().Add(i.ttl)
+	if outcome == OutcomeRetry {
+		i.remove(authorizationKey, call)
+	}
+}
+
+// wait returns the outcome of call, or its pending response when ctx is done first
+func (i *Idempotency) wait(ctx context.Context, call *idempotentCall) (*models.SettleResponse, error) {
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	select {
+	case <-call.done:
+		return call.response, nil
+	default:
+	}
+	if call.pending != nil {
+		return call.pending, nil
+	}
+	return nil, ctx.Err()
+}
+
+// sweep purges expired outcomes, callers must hold the lock
+func (i *Idempotency) sweep() {
+	now := time.Now()
+	if now.Sub(i.lastSweep) < idempotencySweepInterval {
+		return
+	}
+	i.lastSweep = now
+
+	for authorizationKey, call := range i.calls {
+		if call.response != nil && now.After(call.expiresAt) {
+			i.remove(authorizationKey, call)
+		}
+	}
+}
+
+// remove drops a call and its Idempotency-Key mapping, callers must hold the lock
+func (i *Idempotency) remove(authorizationKey string, call *idempotentCall) {
+	delete(i.calls, authorizationKey)
+	if call.idempotencyKey != "" {
+		delete(i.keys, call.idempotencyKey)
+	}
+}
@@ -0,0 +1,282 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"x402-facilitator-go/internal/models"
+)
+
+const (
+	testAuthorizationKey = "local:0xasset:0xpayer:0x01"
+	testFingerprint      = "exact|local|0xasset|0xpayto|100|"
+)
+
+// settleOnce returns a SettleFunc counting its calls and returning response with the given outcome
+func settleOnce(calls *atomic.Int32, response *models.SettleResponse, outcome Outcome) SettleFunc {
+	return func(func(*models.SettleResponse)) (*models.SettleResponse, Outcome) {
+		calls.Add(1)
+		return response, outcome
+	}
+}
+
+func TestIdempotencySharesInFlightAttempt(t *testing.T) {
+	idempotency := NewIdempotency(time.Minute)
+	ctx := context.Background()
+	transaction := "0x01"
+	response := &models.SettleResponse{Success: true, Transaction: &transaction}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls atomic.Int32
+	settle := func(func(*models.SettleResponse)) (*models.SettleResponse, Outcome) {
+		calls.Add(1)
+		close(started)
+		<-release
+		return response, OutcomeFinal
+	}
+
+	const duplicates = 5
+	results := make([]*models.SettleResponse, duplicates+1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _ = idempotency.Do(ctx, testAuthorizationKey, testFingerprint, "", settle)
+	}()
+	<-started
+	for i := 1; i <= duplicates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			results[i], err = idempotency.Do(ctx, testAuthorizationKey, testFingerprint, "", settle)
+			if err != nil {
+				t.Errorf("duplicate %d: %v", i, err)
+			}
+		}(i)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("settled %d times, want once", got)
+	}
+	for i, result := range results {
+		if result != response {
+			t.Fatalf("result %d: want the in-flight attempt's response, got %+v", i, result)
+		}
+	}
+}
+
+func TestIdempotencyCachesOnlyFinalAndPendingOutcomes(t *testing.T) {
+	transaction := "0x01"
+	for _, tc := range []struct {
+		name      string
+		response  *models.SettleResponse
+		outcome   Outcome
+		wantCalls int32
+	}{
+		{"final outcome is replayed", &models.SettleResponse{Success: true, Transaction: &transaction}, OutcomeFinal, 1},
+		{"pending outcome is replayed", &models.SettleResponse{Transaction: &transaction, Status: models.SettleStatusPending}, OutcomePending, 1},
+		{"retry outcome is settled again", &models.SettleResponse{ErrorReason: "insufficient_funds"}, OutcomeRetry, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			idempotency := NewIdempotency(time.Minute)
+			var calls atomic.Int32
+			for i := 0; i < 2; i++ {
+				response, err := idempotency.Do(context.Background(), testAuthorizationKey, testFingerprint, "",
+					settleOnce(&calls, tc.response, tc.outcome))
+				if err != nil {
+					t.Fatalf("do %d: %v", i, err)
+				}
+				if response != tc.response {
+					t.Fatalf("do %d: got %+v, want %+v", i, response, tc.response)
+				}
+			}
+			if got := calls.Load(); got != tc.wantCalls {
+				t.Fatalf("settled %d times, want %d", got, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotencySweepsExpiredOutcomes(t *testing.T) {
+	idempotency := NewIdempotency(time.Millisecond)
+	transaction := "0x01"
+	response := &models.SettleResponse{Success: true, Transaction: &transaction}
+	var calls atomic.Int32
+
+	if _, err := idempotency.Do(context.Background(), testAuthorizationKey, testFingerprint, "key", settleOnce(&calls, response, OutcomeFinal)); err != nil {
+		t.Fatalf("do: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	// Let the next call sweep without waiting for the sweep interval
+	idempotency.lastSweep = time.Time{}
+
+	if _, err := idempotency.Do(context.Background(), testAuthorizationKey, testFingerprint, "", settleOnce(&calls, response, OutcomeFinal)); err != nil {
+		t.Fatalf("do after expiry: %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("settled %d times, want the expired outcome settled again", got)
+	}
+	if _, ok := idempotency.keys["key"]; ok {
+		t.Fatal("want the expired outcome's Idempotency-Key released")
+	}
+}
+
+func TestIdempotencyRejectsMismatches(t *testing.T) {
+	transaction := "0x01"
+	response := &models.SettleResponse{Success: true, Transaction: &transaction}
+
+	for _, tc := range []struct {
+		name             string
+		authorizationKey string
+		fingerprint      string
+		idempotencyKey   string
+		wantErr          error
+	}{
+		{"different requirements", testAuthorizationKey, "exact|local|0xasset|0xother|100|", "", ErrRequirementsMismatch},
+		{"key reused for another authorization", "local:0xasset:0xpayer:0x02", testFingerprint, "key", ErrIdempotencyKeyReused},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			idempotency := NewIdempotency(time.Minute)
+			var calls atomic.Int32
+			if _, err := idempotency.Do(context.Background(), testAuthorizationKey, testFingerprint, "key", settleOnce(&calls, response, OutcomeFinal)); err != nil {
+				t.Fatalf("first do: %v", err)
+			}
+
+			_, err := idempotency.Do(context.Background(), tc.authorizationKey, tc.fingerprint, tc.idempotencyKey, settleOnce(&calls, response, OutcomeFinal))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want %v, got %v", tc.wantErr, err)
+			}
+			if got := calls.Load(); got != 1 {
+				t.Fatalf("settled %d times, want once", got)
+			}
+		})
+	}
+}
+
+func TestIdempotencyCallersGiveUpWithoutStoppingAttempt(t *testing.T) {
+	idempotency := NewIdempotency(time.Minute)
+	transaction := "0x01"
+	pending := &models.SettleResponse{Transaction: &transaction, Status: models.SettleStatusPending}
+	settled := &models.SettleResponse{Success: true, Transaction: &transaction}
+
+	broadcast := make(chan func(*models.SettleResponse))
+	release := make(chan struct{})
+	var calls atomic.Int32
+	settle := func(report func(*models.SettleResponse)) (*models.SettleResponse, Outcome) {
+		calls.Add(1)
+		broadcast <- report
+		<-release
+		return settled, OutcomeFinal
+	}
+
+	// The first caller gives up before anything was broadcast
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := idempotency.Do(ctx, testAuthorizationKey, testFingerprint, "", settle)
+		first <- err
+	}()
+	report := <-broadcast
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("first caller: want context.Canceled, got %v", err)
+	}
+
+	// A duplicate giving up after the broadcast gets the pending response
+	report(pending)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	response, err := idempotency.Do(ctx, testAuthorizationKey, testFingerprint, "", settle)
+	if err != nil || response != pending {
+		t.Fatalf("duplicate after broadcast: got %+v, %v, want the pending response", response, err)
+	}
+
+	// The attempt carries on and later repeats get its outcome
+	close(release)
+	response, err = idempotency.Do(context.Background(), testAuthorizationKey, testFingerprint, "", settle)
+	if err != nil || response != settled {
+		t.Fatalf("repeat after the outcome: got %+v, %v, want the settled response", response, err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("settled %d times, want once", got)
+	}
+}
+
+func TestIdempotencyResolvesPendingOutcomes(t *testing.T) {
+	transaction := "0x01"
+	pending := &models.SettleResponse{Transaction: &transaction, Status: models.SettleStatusPending}
+	settled := &models.SettleResponse{Success: true, Transaction: &transaction}
+	dropped := &models.SettleResponse{ErrorReason: "SETTLEMENT_DROPPED"}
+
+	for _, tc := range []struct {
+		name      string
+		response  *models.SettleResponse
+		outcome   Outcome
+		want      *models.SettleResponse
+		wantCalls int32
+	}{
+		{"settled outcome is replayed", settled, OutcomeFinal, settled, 1},
+		{"dropped outcome is settled again", dropped, OutcomeRetry, pending, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			idempotency := NewIdempotency(time.Minute)
+			var calls atomic.Int32
+			if _, err := idempotency.Do(context.Background(), testAuthorizationKey, testFingerprint, "", settleOnce(&calls, pending, OutcomePending)); err != nil {
+				t.Fatalf("first do: %v", err)
+			}
+
+			idempotency.Resolve(testAuthorizationKey, tc.response, tc.outcome)
+			response, err := idempotency.Do(context.Background(), testAuthorizationKey, testFingerprint, "", settleOnce(&calls, pending, OutcomePending))
+			if err != nil || response != tc.want {
+				t.Fatalf("repeat after resolve: got %+v, %v, want %+v", response, err, tc.want)
+			}
+			if got := calls.Load(); got != tc.wantCalls {
+				t.Fatalf("settled %d times, want %d", got, tc.wantCalls)
+			}
+
+			// A final outcome is not resolved again
+			idempotency.Resolve(testAuthorizationKey, dropped, OutcomeRetry)
+			if tc.outcome == OutcomeFinal {
+				if response, _ := idempotency.Do(context.Background(), testAuthorizationKey, testFingerprint, "", settleOnce(&calls, pending, OutcomePending)); response != settled {
+					t.Fatalf("repeat after a late resolve: got %+v, want the settled response", response)
+				}
+			}
+		})
+	}
+}
+
+func TestIdempotencyResolveDuringAttempt(t *testing.T) {
+	idempotency := NewIdempotency(time.Minute)
+	transaction := "0x01"
+	pending := &models.SettleResponse{Transaction: &transaction, Status: models.SettleStatusPending}
+	settled := &models.SettleResponse{Success: true, Transaction: &transaction}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	settle := func(func(*models.SettleResponse)) (*models.SettleResponse, Outcome) {
+		close(started)
+		<-release
+		return pending, OutcomePending
+	}
+
+	result := make(chan *models.SettleResponse)
+	go func() {
+		response, _ := idempotency.Do(context.Background(), testAuthorizationKey, testFingerprint, "", settle)
+		result <- response
+	}()
+	<-started
+	// The outcome is found before the attempt returns its pending response
+	idempotency.Resolve(testAuthorizationKey, settled, OutcomeFinal)
+	close(release)
+
+	if response := <-result; response != settled {
+		t.Fatalf("got %+v, want the resolved response", response)
+	}
+}
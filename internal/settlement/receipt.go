@@ -0,0 +1,67 @@
+package settlement
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrReceiptEventMismatch is returned when a successful settlement receipt lacks the events the asset
+// must emit for the authorization, or emits them with unexpected values
+var ErrReceiptEventMismatch = errors.New("settlement receipt events do not match the authorization")
+
+var (
+	// authorizationUsedTopic is the topic of the EIP-3009 AuthorizationUsed(address indexed authorizer, bytes32 indexed nonce) event
+	authorizationUsedTopic = crypto.Keccak256Hash([]byte("AuthorizationUsed(address,bytes32)"))
+	// transferTopic is the topic of the ERC-20 Transfer(address indexed from, address indexed to, uint256 value) event
+	transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+)
+
+// VerifyReceiptEvents checks that the asset emitted AuthorizationUsed(from, nonce) and Transfer(from, to, value)
+// for the authorization in a successful receipt, protecting against non-standard or malicious token contracts
+func VerifyReceiptEvents(receipt *types.Receipt, asset common.Address, authorization Authorization) error {
+	if !AuthorizationUsed(receipt, asset, authorization) {
+		return fmt.Errorf("%w: no AuthorizationUsed event from %s for authorizer %s and nonce %s",
+			ErrReceiptEventMismatch, asset.Hex(), authorization.From.Hex(), common.Hash(authorization.Nonce).Hex())
+	}
+
+	var mismatched *big.Int
+	for _, log := range receipt.Logs {
+		if log.Address != asset || len(log.Topics) != 3 || log.Topics[0] != transferTopic {
+			continue
+		}
+		if common.BytesToAddress(log.Topics[1].Bytes()) != authorization.From ||
+			common.BytesToAddress(log.Topics[2].Bytes()) != authorization.To {
+			continue
+		}
+		value := new(big.Int).SetBytes(log.Data)
+		if len(log.Data) == 32 && value.Cmp(authorization.Value) == 0 {
+			return nil
+		}
+		mismatched = value
+	}
+
+	if mismatched != nil {
+		return fmt.Errorf("%w: Transfer from %s to %s carries value %s, expected %s",
+			ErrReceiptEventMismatch, authorization.From.Hex(), authorization.To.Hex(), mismatched, authorization.Value)
+	}
+	return fmt.Errorf("%w: no Transfer event from %s for %s to %s",
+		ErrReceiptEventMismatch, asset.Hex(), authorization.From.Hex(), authorization.To.Hex())
+}
+
+// AuthorizationUsed reports whether the receipt holds the asset's AuthorizationUsed event for the authorization
+func AuthorizationUsed(receipt *types.Receipt, asset common.Address, authorization Authorization) bool {
+	for _, log := range receipt.Logs {
+		if log.Address != asset || len(log.Topics) != 3 || log.Topics[0] != authorizationUsedTopic {
+			continue
+		}
+		if common.BytesToAddress(log.Topics[1].Bytes()) == authorization.From && log.Topics[2] == authorization.Nonce {
+			return true
+		}
+	}
+	return false
+}
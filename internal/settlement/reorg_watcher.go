@@ -0,0 +1,232 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/web3"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+// WatchedSettlement is a recently confirmed settlement whose receipt is re-checked for reorgs
+type WatchedSettlement struct {
+	Network     string
+	TxHash      common.Hash
+	BlockNumber uint64
+	BlockHash   common.Hash
+	Payer       string
+	// Reorged is set once the receipt vanished from the canonical chain
+	Reorged bool
+}
+
+// ReorgWatcher re-checks the receipts of recent settlements and flags those that vanish after a reorg
+type ReorgWatcher struct {
+	web3Client    web3.Chain
+	watchBlocks   uint64
+	checkInterval time.Duration
+	logger        *zap.Logger
+
+	mu      sync.Mutex
+	watched map[common.Hash]*WatchedSettlement
+	onReorg []func(WatchedSettlement)
+}
+
+// NewReorgWatcher creates a new ReorgWatcher
+func NewReorgWatcher(web3Client web3.Chain, cfg config.SettlementConfig, logger *zap.Logger) *ReorgWatcher {
+	return &ReorgWatcher{
+		web3Client:    web3Client,
+		watchBlocks:   cfg.ReorgWatchBlocks,
+		checkInterval: time.Duration(cfg.ReorgCheckIntervalSeconds) * time.Second,
+		logger:        logger,
+		watched:       make(map[common.Hash]*WatchedSettlement),
+	}
+}
+
+// OnReorg registers a callback invoked whenever a watched settlement is flagged as reorged
+func (w *ReorgWatcher) OnReorg(callback func(WatchedSettlement)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.onReorg = append(w.onReorg, callback)
+}
+
+// Watch starts re-checking the receipt of a confirmed settlement
+func (w *ReorgWatcher) Watch(settlement WatchedSettlement) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.watched[settlement.TxHash] = &settlement
+}
+
+// Flagged returns the watched settlements whose receipts are currently missing after a reorg
+// Flagged settlements are dropped once the chain is reorgWatchBlocks past their original block
+func (w *ReorgWatcher) Flagged() []WatchedSettlement {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	flagged := make([]WatchedSettlement, 0)
+	for _, settlement := range w.watched {
+		if settlement.Reorged {
+			flagged = append(flagged, *settlement)
+		}
+	}
+	return flagged
+}
+
+// Run re-checks watched settlements until ctx is cancelled
+func (w *ReorgWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.check(ctx)
+		}
+	}
+}
+
+// check re-fetches the receipt of every watched settlement once
+func (w *ReorgWatcher) check(ctx context.Context) {
+	w.mu.Lock()
+	pending := make([]WatchedSettlement, 0, len(w.watched))
+	for _, settlement := range w.watched {
+		pending = append(pending, *settlement)
+	}
+	w.mu.Unlock()
+
+	heads := make(map[string]uint64)
+	for _, settlement := range pending {
+		client, err := w.web3Client.GetClient(settlement.Network)
+		if err != nil {
+			continue
+		}
+
+		head, ok := heads[settlement.Network]
+		if !ok {
+			header, err := client.HeaderByNumber(ctx, nil)
+			if err != nil {
+				w.logger.Debug("Failed to fetch chain head for reorg check",
+					zap.Error(err),
+					zap.String("network", settlement.Network),
+				)
+				continue
+			}
+			head = header.Number.Uint64()
+			heads[settlement.Network] = head
+		}
+
+		receipt, err := client.TransactionReceipt(ctx, settlement.TxHash)
+		switch {
+		case errors.Is(err, ethereum.NotFound):
+			if w.replaced(ctx, client, settlement) {
+				w.flag(settlement)
+			}
+			w.expire(settlement, head)
+		case err != nil:
+			w.logger.Debug("Failed to fetch receipt for reorg check",
+				zap.Error(err),
+				zap.String("network", settlement.Network),
+				zap.String("txHash", settlement.TxHash.Hex()),
+			)
+		default:
+			w.update(settlement, receipt.BlockNumber, receipt.BlockHash, head)
+		}
+	}
+}
+
+// replaced reports whether the block the settlement was included in is no longer canonical
+// A missing receipt alone may come from a lagging or load-balanced node, so the canonical block hash at the
+// settlement's block number must have changed before the settlement is flagged
+func (w *ReorgWatcher) replaced(ctx context.Context, client web3.Backend, settlement WatchedSettlement) bool {
+	header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(settlement.BlockNumber))
+	if err != nil {
+		w.logger.Debug("Failed to fetch block for reorg check, receipt may come from a lagging node",
+			zap.Error(err),
+			zap.String("network", settlement.Network),
+			zap.String("txHash", settlement.TxHash.Hex()),
+			zap.Uint64("blockNumber", settlement.BlockNumber),
+		)
+		return false
+	}
+	if header.Hash() == settlement.BlockHash {
+		w.logger.Debug("Receipt missing but inclusion block still canonical, ignoring",
+			zap.String("network", settlement.Network),
+			zap.String("txHash", settlement.TxHash.Hex()),
+			zap.Uint64("blockNumber", settlement.BlockNumber),
+		)
+		return false
+	}
+	return true
+}
+
+// flag marks a settlement as reorged and notifies the registered callbacks
+func (w *ReorgWatcher) flag(settlement WatchedSettlement) {
+	w.mu.Lock()
+	watched, ok := w.watched[settlement.TxHash]
+	if !ok || watched.Reorged {
+		w.mu.Unlock()
+		return
+	}
+	watched.Reorged = true
+	flagged := *watched
+	callbacks := w.onReorg
+	w.mu.Unlock()
+
+	w.logger.Error("Settlement receipt vanished after reorg",
+		zap.String("network", flagged.Network),
+		zap.String("txHash", flagged.TxHash.Hex()),
+		zap.Uint64("blockNumber", flagged.BlockNumber),
+		zap.String("blockHash", flagged.BlockHash.Hex()),
+		zap.String("payer", flagged.Payer),
+	)
+	for _, callback := range callbacks {
+		callback(flagged)
+	}
+}
+
+// update records the settlement's current inclusion block and stops watching it once it is deep enough
+func (w *ReorgWatcher) update(settlement WatchedSettlement, blockNumber *big.Int, blockHash common.Hash, head uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	watched, ok := w.watched[settlement.TxHash]
+	if !ok {
+		return
+	}
+
+	if watched.Reorged || watched.BlockHash != blockHash {
+		w.logger.Warn("Settlement transaction re-included after reorg",
+			zap.String("network", watched.Network),
+			zap.String("txHash", watched.TxHash.Hex()),
+			zap.Uint64("previousBlockNumber", watched.BlockNumber),
+			zap.Uint64("blockNumber", blockNumber.Uint64()),
+			zap.String("payer", watched.Payer),
+		)
+		watched.Reorged = false
+		watched.BlockNumber = blockNumber.Uint64()
+		watched.BlockHash = blockHash
+	}
+
+	if head >= watched.BlockNumber+w.watchBlocks {
+		delete(w.watched, watched.TxHash)
+	}
+}
+
+// expire stops watching a settlement whose receipt is missing once the chain has moved far past its original block
+func (w *ReorgWatcher) expire(settlement WatchedSettlement, head uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if head >= settlement.BlockNumber+w.watchBlocks {
+		delete(w.watched, settlement.TxHash)
+	}
+}
@@ -0,0 +1,130 @@
+package settlement
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"x402-facilitator-go/internal/models"
+)
+
+// ErrAuthorizationReserved is returned when an authorization is held by the verification of another resource
+var ErrAuthorizationReserved = errors.New("authorization is reserved by another verification")
+
+// ErrAuthorizationConsumed is returned when an authorization was already settled
+var ErrAuthorizationConsumed = errors.New("authorization was already settled")
+
+// reservationSweepInterval bounds how often expired reservations are purged
+const reservationSweepInterval = time.Minute
+
+// reservation is the hold of an authorization by the resource that verified it
+// A consumed reservation is the tombstone of a settled authorization, kept until its validBefore
+type reservation struct {
+	owner     string
+	consumed  bool
+	expiresAt time.Time
+}
+
+// Reservations holds verified authorizations for the payTo and resource they were verified for, so that the same
+// signed authorization presented to several resource servers only verifies for the first one until it is settled
+// or its hold expires. Settled authorizations keep failing verification until their validBefore
+type Reservations struct {
+	holdTTL time.Duration
+
+	mu        sync.Mutex
+	holds     map[string]*reservation
+	lastSweep time.Time
+}
+
+// NewReservations creates a new Reservations holding verified authorizations for holdTTL
+func NewReservations(holdTTL time.Duration) *Reservations {
+	return &Reservations{
+		holdTTL:   holdTTL,
+		holds:     make(map[string]*reservation),
+		lastSweep: time.Now(),
+	}
+}
+
+// ReservationOwner identifies the resource an authorization is verified for by its payTo and resource URL
+func ReservationOwner(requirements models.PaymentRequirements) string {
+	return strings.ToLower(requirements.PayTo) + " " + requirements.Resource
+}
+
+// Reserve holds the authorization for owner, or refreshes owner's hold
+// It fails with ErrAuthorizationConsumed once the authorization was settled, and with ErrAuthorizationReserved
+// while another owner holds the authorization
+func (r *Reservations) Reserve(authorizationKey, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweep()
+
+	now := time.Now()
+	held, ok := r.holds[authorizationKey]
+	if ok && held.consumed && now.Before(held.expiresAt) {
+		return ErrAuthorizationConsumed
+	}
+	if ok && held.owner != owner && now.Before(held.expiresAt) {
+		return ErrAuthorizationReserved
+	}
+	if !ok || held.owner != owner || held.consumed {
+		held = &reservation{owner: owner}
+		r.holds[authorizationKey] = held
+	}
+	if expiresAt := now.Add(r.holdTTL); expiresAt.After(held.expiresAt) {
+		held.expiresAt = expiresAt
+	}
+	return nil
+}
+
+// Extend keeps owner's hold of the authorization until at least until, such as while its settlement is queued
+// An unexpired hold of another owner is left alone
+func (r *Reservations) Extend(authorizationKey, owner string, until time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	held, ok := r.holds[authorizationKey]
+	if ok && (held.owner != owner || held.consumed) && time.Now().Before(held.expiresAt) {
+		return
+	}
+	if !ok || held.owner != owner {
+		held = &reservation{owner: owner}
+		r.holds[authorizationKey] = held
+	}
+	if until.After(held.expiresAt) {
+		held.expiresAt = until
+	}
+}
+
+// Consume replaces the hold of a settled authorization with a tombstone kept until validBefore, so that it fails
+// verification for every owner even while the chain read by verification does not reflect the transfer yet
+func (r *Reservations) Consume(authorizationKey, owner string, validBefore time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.holds[authorizationKey] = &reservation{owner: owner, consumed: true, expiresAt: validBefore}
+}
+
+// Release drops owner's hold of the authorization once its settlement failed, another owner's hold is kept
+func (r *Reservations) Release(authorizationKey, owner string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if held, ok := r.holds[authorizationKey]; ok && held.owner == owner && !held.consumed {
+		delete(r.holds, authorizationKey)
+	}
+}
+
+// sweep purges expired reservations, callers must hold the lock
+func (r *Reservations) sweep() {
+	now := time.Now()
+	if now.Sub(r.lastSweep) < reservationSweepInterval {
+		return
+	}
+	r.lastSweep = now
+
+	for authorizationKey, held := range r.holds {
+		if !now.Before(held.expiresAt) {
+			delete(r.holds, authorizationKey)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package settlement
+
+import (
+	"context"
+	stderrors "errors"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// executionRevertedPrefix prefixes the message of RPC errors for reverted calls
+const executionRevertedPrefix = "execution reverted"
+
+// customErrors maps the selectors of well-known token custom errors to their signatures
+var customErrors = func() map[[4]byte]string {
+	signatures := []string{
+		// OpenZeppelin v5 ERC20 and Pausable
+		"ERC20InsufficientBalance(address,uint256,uint256)",
+		"ERC20InvalidSender(address)",
+		"ERC20InvalidReceiver(address)",
+		"EnforcedPause()",
+		// EIP-3009 implementations using custom errors
+		"AuthorizationAlreadyUsed(address,bytes32)",
+		"AuthorizationNotYetValid()",
+		"AuthorizationExpired()",
+		"InvalidSignature()",
+		"Blacklisted(address)",
+	}
+
+	selectors := make(map[[4]byte]string, len(signatures))
+	for _, signature := range signatures {
+		var selector [4]byte
+		copy(selector[:], crypto.Keccak256([]byte(signature))[:4])
+		selectors[selector] = signature
+	}
+	return selectors
+}()
+
+// RevertReason extracts a readable revert reason from an error returned by a call, gas estimation or broadcast
+// It returns "" when err does not carry a revert
+func RevertReason(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var dataErr rpc.DataError
+	if stderrors.As(err, &dataErr) {
+		if data, ok := dataErr.ErrorData().(string); ok {
+			if reason := DecodeRevertData(common.FromHex(data)); reason != "" {
+				return reason
+			}
+		}
+	}
+
+	message := err.Error()
+	index := strings.Index(message, executionRevertedPrefix)
+	if index < 0 {
+		return ""
+	}
+	reason := strings.TrimPrefix(message[index+len(executionRevertedPrefix):], ":")
+	return strings.TrimSpace(reason)
+}
+
+// DecodeRevertData decodes revert data carrying an Error(string), a Panic(uint256) or a well-known custom error
+// Unknown custom errors are returned as their hex selector, empty data as ""
+func DecodeRevertData(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		return reason
+	}
+
+	var selector [4]byte
+	copy(selector[:], data[:4])
+	if signature, ok := customErrors[selector]; ok {
+		return signature
+	}
+	return hexutil.Encode(selector[:])
+}
+
+// ReplayRevertReason re-executes a transaction that failed on-chain as a call at its block to recover the revert reason
+// It returns "" when the replay does not revert
+func ReplayRevertReason(ctx context.Context, caller ethereum.ContractCaller, from common.Address, tx *types.Transaction, blockNumber *big.Int) string {
+	_, err := caller.CallContract(ctx, ethereum.CallMsg{
+		From:  from,
+		To:    tx.To(),
+		Gas:   tx.Gas(),
+		Value: tx.Value(),
+		Data:  tx.Data(),
+	}, blockNumber)
+	return RevertReason(err)
+}
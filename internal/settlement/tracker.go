@@ -0,0 +1,321 @@
+package settlement
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/web3"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.uber.org/zap"
+)
+
+// ErrAuthorizationExpired is returned when the authorization's validBefore passes
+// before any broadcast of the settlement transaction was mined
+var ErrAuthorizationExpired = errors.New("authorization expired before settlement transaction was mined")
+
+// ErrTrackingDeadline is returned when the settlement transaction is still not final after the maximum tracking
+// duration, its outcome is left to status polling
+var ErrTrackingDeadline = errors.New("settlement transaction not final at the tracking deadline")
+
+// farFuture is the time validBefore values beyond any representable time are clamped to
+var farFuture = time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC)
+
+// ValidBeforeTime converts an authorization's validBefore in unix seconds to a time
+// Values too large for a time, such as the max uint256 some clients send for "never expires", are clamped to a far-future time
+func ValidBeforeTime(validBefore *big.Int) time.Time {
+	if validBefore == nil || validBefore.Sign() <= 0 {
+		return time.Unix(0, 0)
+	}
+	if !validBefore.IsInt64() || validBefore.Int64() > farFuture.Unix() {
+		return farFuture
+	}
+	return time.Unix(validBefore.Int64(), 0)
+}
+
+// TxBackend is the subset of the Ethereum client API needed to rebroadcast and replace a settlement transaction
+type TxBackend interface {
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+}
+
+// TrackRequest describes a broadcast settlement transaction to be tracked until it is mined
+type TrackRequest struct {
+	Network     string
+	Backend     TxBackend
+	Signer      *ecdsa.PrivateKey
+	ChainID     *big.Int
+	Tx          *types.Transaction
+	ValidBefore time.Time
+	// Confirmations is the number of blocks, including the inclusion block, the transaction must have
+	Confirmations uint64
+	// OnMined, when set, is called with the first receipt seen, before the confirmations are reached
+	OnMined func(receipt *types.Receipt)
+}
+
+// TxAttempt records one broadcast of a settlement transaction
+type TxAttempt struct {
+	Hash        common.Hash
+	GasPrice    *big.Int
+	GasTipCap   *big.Int
+	GasFeeCap   *big.Int
+	Replacement bool
+	SentAt      time.Time
+}
+
+// TrackResult holds the outcome of tracking a settlement transaction
+type TrackResult struct {
+	// Receipt is the receipt of whichever broadcast was mined, nil if none was
+	Receipt *types.Receipt
+	// Confirmations is the number of blocks the receipt had when tracking stopped
+	Confirmations uint64
+	// Attempts is the broadcast history, the original transaction first
+	Attempts []TxAttempt
+}
+
+// Tracker waits for settlement transactions to be mined, rebroadcasting or replacing
+// them with bumped fees when they stay pending for too long
+type Tracker struct {
+	replacementDelay time.Duration
+	pollInterval     time.Duration
+	feeBumpPercent   int64
+	maxReplacements  int
+	maxTracking      time.Duration
+	watcher          *ConfirmationWatcher
+	clock            web3.Clock
+	logger           *zap.Logger
+}
+
+// NewTracker creates a new Tracker receiving receipts from watcher
+// Authorization expiry is checked against clock, the same clock verification checks validity windows against
+func NewTracker(cfg config.SettlementConfig, watcher *ConfirmationWatcher, clock web3.Clock, logger *zap.Logger) *Tracker {
+	return &Tracker{
+		replacementDelay: time.Duration(cfg.ReplacementDelaySeconds) * time.Second,
+		pollInterval:     time.Duration(cfg.ReceiptPollIntervalMillis) * time.Millisecond,
+		feeBumpPercent:   int64(cfg.FeeBumpPercent),
+		maxReplacements:  cfg.MaxReplacements,
+		maxTracking:      time.Duration(cfg.MaxTrackingSeconds) * time.Second,
+		watcher:          watcher,
+		clock:            clock,
+		logger:           logger,
+	}
+}
+
+// Track waits until one of the broadcasts of the request's transaction is mined and has the requested confirmations
+// The receipts come from the network's shared ConfirmationWatcher on every new head, so a broadcast reorged out
+// before reaching its confirmations is treated as pending again. It stops with ErrAuthorizationExpired once
+// ValidBefore has passed while nothing is mined, since the transfer can no longer succeed, and with
+// ErrTrackingDeadline once the maximum tracking duration has passed, however far ValidBefore is
+func (t *Tracker) Track(ctx context.Context, request TrackRequest) (*TrackResult, error) {
+	current := request.Tx
+	result := &TrackResult{
+		Attempts: []TxAttempt{newTxAttempt(current, false)},
+	}
+	lastBroadcast := time.Now()
+	deadline := lastBroadcast.Add(t.maxTracking)
+	replacements := 0
+	mined := false
+
+	watch := t.watcher.Watch(request.Network, current.Hash())
+	defer watch.Close()
+
+	ticker := time.NewTicker(t.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case update := <-watch.Updates():
+			result.Receipt = update.Receipt
+			result.Confirmations = 0
+			if update.Receipt == nil {
+				continue
+			}
+			if !mined && request.OnMined != nil {
+				request.OnMined(update.Receipt)
+			}
+			mined = true
+			result.Confirmations = confirmationsAt(update.Head, update.Receipt)
+			if result.Confirmations >= request.Confirmations {
+				return result, nil
+			}
+			// Mined but not yet final, keep waiting without rebroadcasting
+			lastBroadcast = time.Now()
+			continue
+		case <-ticker.C:
+		}
+
+		if result.Receipt == nil && t.expired(ctx, request) {
+			t.logger.Warn("Authorization expired while settlement transaction was pending",
+				zap.String("network", request.Network),
+				zap.String("txHash", current.Hash().Hex()),
+				zap.Int("attempts", len(result.Attempts)),
+			)
+			return result, ErrAuthorizationExpired
+		}
+		if !time.Now().Before(deadline) {
+			t.logger.Warn("Settlement transaction not final at the tracking deadline, leaving it to status polling",
+				zap.String("network", request.Network),
+				zap.String("txHash", current.Hash().Hex()),
+				zap.Bool("mined", result.Receipt != nil),
+				zap.Int("attempts", len(result.Attempts)),
+			)
+			return result, ErrTrackingDeadline
+		}
+		if result.Receipt == nil && time.Since(lastBroadcast) >= t.replacementDelay {
+			next, replaced := t.rebroadcast(ctx, request, current, replacements < t.maxReplacements)
+			if replaced {
+				replacements++
+				current = next
+				watch.Add(next.Hash())
+			}
+			result.Attempts = append(result.Attempts, newTxAttempt(current, replaced))
+			lastBroadcast = time.Now()
+		}
+	}
+}
+
+// expired reports whether the request's validBefore has passed on the clock
+// A clock that cannot be read leaves the transaction pending until the next check
+func (t *Tracker) expired(ctx context.Context, request TrackRequest) bool {
+	now, err := t.clock.Now(ctx, request.Network)
+	if err != nil {
+		t.logger.Debug("Failed to read clock for authorization expiry",
+			zap.Error(err),
+			zap.String("network", request.Network),
+		)
+		return false
+	}
+	return !now.Before(request.ValidBefore)
+}
+
+// confirmationsAt returns how many blocks the receipt's block has on top of it at head, including itself
+// A head older than the receipt's block was read before the block arrived and counts as the block itself
+func confirmationsAt(head uint64, receipt *types.Receipt) uint64 {
+	block := receipt.BlockNumber.Uint64()
+	if head < block {
+		return 1
+	}
+	return head - block + 1
+}
+
+// rebroadcast replaces the pending transaction with a fee-bumped one when allowed,
+// otherwise (or when the replacement is rejected) it resends the pending transaction as is
+func (t *Tracker) rebroadcast(ctx context.Context, request TrackRequest, pending *types.Transaction, replace bool) (*types.Transaction, bool) {
+	if replace {
+		replacement, err := t.replacement(ctx, request, pending)
+		if err == nil {
+			err = request.Backend.SendTransaction(ctx, replacement)
+		}
+		if err == nil {
+			t.logger.Info("Replaced pending settlement transaction",
+				zap.String("network", request.Network),
+				zap.String("txHash", pending.Hash().Hex()),
+				zap.String("replacementTxHash", replacement.Hash().Hex()),
+				zap.Uint64("nonce", pending.Nonce()),
+			)
+			return replacement, true
+		}
+		t.logger.Warn("Failed to replace pending settlement transaction, rebroadcasting",
+			zap.Error(err),
+			zap.String("network", request.Network),
+			zap.String("txHash", pending.Hash().Hex()),
+		)
+	}
+
+	if err := request.Backend.SendTransaction(ctx, pending); err != nil && !isKnownTxError(err) {
+		t.logger.Warn("Failed to rebroadcast pending settlement transaction",
+			zap.Error(err),
+			zap.String("network", request.Network),
+			zap.String("txHash", pending.Hash().Hex()),
+		)
+	} else {
+		t.logger.Info("Rebroadcast pending settlement transaction",
+			zap.String("network", request.Network),
+			zap.String("txHash", pending.Hash().Hex()),
+		)
+	}
+	return pending, false
+}
+
+// replacement builds and signs a same-nonce copy of the pending transaction with bumped fees
+func (t *Tracker) replacement(ctx context.Context, request TrackRequest, pending *types.Transaction) (*types.Transaction, error) {
+	suggestedPrice, err := request.Backend.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var inner types.TxData
+	switch pending.Type() {
+	case types.DynamicFeeTxType:
+		suggestedTip, err := request.Backend.SuggestGasTipCap(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tipCap := maxBig(t.bump(pending.GasTipCap()), suggestedTip)
+		feeCap := maxBig(t.bump(pending.GasFeeCap()), new(big.Int).Add(suggestedPrice, tipCap))
+		inner = &types.DynamicFeeTx{
+			ChainID:    request.ChainID,
+			Nonce:      pending.Nonce(),
+			GasTipCap:  tipCap,
+			GasFeeCap:  feeCap,
+			Gas:        pending.Gas(),
+			To:         pending.To(),
+			Value:      pending.Value(),
+			Data:       pending.Data(),
+			AccessList: pending.AccessList(),
+		}
+	default:
+		inner = &types.LegacyTx{
+			Nonce:    pending.Nonce(),
+			GasPrice: maxBig(t.bump(pending.GasPrice()), suggestedPrice),
+			Gas:      pending.Gas(),
+			To:       pending.To(),
+			Value:    pending.Value(),
+			Data:     pending.Data(),
+		}
+	}
+
+	return types.SignNewTx(request.Signer, types.LatestSignerForChainID(request.ChainID), inner)
+}
+
+// bump raises a fee value by the configured percentage
+func (t *Tracker) bump(value *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(value, big.NewInt(100+t.feeBumpPercent))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+// newTxAttempt records a broadcast of tx
+func newTxAttempt(tx *types.Transaction, replacement bool) TxAttempt {
+	return TxAttempt{
+		Hash:        tx.Hash(),
+		GasPrice:    tx.GasPrice(),
+		GasTipCap:   tx.GasTipCap(),
+		GasFeeCap:   tx.GasFeeCap(),
+		Replacement: replacement,
+		SentAt:      time.Now(),
+	}
+}
+
+// isKnownTxError reports whether a send error only means the node already has the transaction
+func isKnownTxError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") ||
+		strings.Contains(msg, "known transaction") ||
+		strings.Contains(msg, "nonce too low")
+}
+
+// maxBig returns the larger of a and b
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,239 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+	"x402-facilitator-go/internal/devchain"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// newFastTracker creates a Tracker over chain polling and rebroadcasting within milliseconds
+func newFastTracker(t *testing.T, chain *testChain, maxReplacements int) *Tracker {
+	t.Helper()
+
+	cfg := testConfig(t).Settlement
+	cfg.ReceiptPollIntervalMillis = 10
+	cfg.MaxReplacements = maxReplacements
+	tracker := newTestTracker(t, chain, cfg)
+	tracker.replacementDelay = 20 * time.Millisecond
+	return tracker
+}
+
+// trackRequest signs a transfer of 1 wei from the facilitator to testPayTo and returns the request tracking it
+// until validBefore, the transaction is not broadcast
+func trackRequest(t *testing.T, chain *testChain, validBefore time.Time) TrackRequest {
+	t.Helper()
+
+	key, err := crypto.HexToECDSA(devchain.Facilitator().PrivateKey)
+	if err != nil {
+		t.Fatalf("facilitator key: %v", err)
+	}
+	nonce, err := chain.backend.PendingNonceAt(context.Background(), devchain.Facilitator().Address)
+	if err != nil {
+		t.Fatalf("pending nonce: %v", err)
+	}
+	chainID := big.NewInt(devchain.ChainID)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1_000_000_000),
+		GasFeeCap: big.NewInt(10_000_000_000),
+		Gas:       21_000,
+		To:        &testPayTo,
+		Value:     big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("sign transaction: %v", err)
+	}
+	client, err := chain.GetClient(testNetwork)
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	return TrackRequest{
+		Network:       testNetwork,
+		Backend:       client,
+		Signer:        key,
+		ChainID:       chainID,
+		Tx:            tx,
+		ValidBefore:   validBefore,
+		Confirmations: 1,
+	}
+}
+
+// track tracks request for at most 10 seconds
+func track(t *testing.T, tracker *Tracker, request TrackRequest) (*TrackResult, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return tracker.Track(ctx, request)
+}
+
+func TestTrackerReplacesStuckTransactionWithBumpedFees(t *testing.T) {
+	chain := newTestChain(t)
+	tracker := newFastTracker(t, chain, 1)
+	request := trackRequest(t, chain, time.Now().Add(time.Hour))
+	var mined atomic.Int32
+	request.OnMined = func(*types.Receipt) { mined.Add(1) }
+
+	// The original transaction never reaches the chain, as if it was underpriced
+	original := request.Tx
+	chain.onSend(func(tx *types.Transaction) (bool, error) {
+		return tx.Hash() != original.Hash(), nil
+	})
+
+	result, err := track(t, tracker, request)
+	if err != nil {
+		t.Fatalf("track: %v", err)
+	}
+	if len(result.Attempts) < 2 || !result.Attempts[1].Replacement {
+		t.Fatalf("want the original followed by a replacement, got %+v", result.Attempts)
+	}
+	replacement := result.Attempts[1]
+	if result.Receipt.TxHash != replacement.Hash {
+		t.Fatalf("mined %s, want the replacement %s", result.Receipt.TxHash, replacement.Hash)
+	}
+	for _, fee := range []struct {
+		name          string
+		original, got *big.Int
+	}{
+		{"tip cap", original.GasTipCap(), replacement.GasTipCap},
+		{"fee cap", original.GasFeeCap(), replacement.GasFeeCap},
+	} {
+		want := new(big.Int).Div(new(big.Int).Mul(fee.original, big.NewInt(100+tracker.feeBumpPercent)), big.NewInt(100))
+		if fee.got.Cmp(want) < 0 {
+			t.Fatalf("replacement %s %s, want at least %s", fee.name, fee.got, want)
+		}
+	}
+	if got := mined.Load(); got != 1 {
+		t.Fatalf("OnMined called %d times, want once", got)
+	}
+}
+
+func TestTrackerRebroadcastsWhenReplacementIsRejected(t *testing.T) {
+	chain := newTestChain(t)
+	tracker := newFastTracker(t, chain, 1)
+	request := trackRequest(t, chain, time.Now().Add(time.Hour))
+
+	original := request.Tx
+	chain.onSend(func(tx *types.Transaction) (bool, error) {
+		if tx.Hash() != original.Hash() {
+			return false, errors.New("replacement transaction underpriced")
+		}
+		return true, nil
+	})
+
+	result, err := track(t, tracker, request)
+	if err != nil {
+		t.Fatalf("track: %v", err)
+	}
+	if result.Receipt.TxHash != original.Hash() {
+		t.Fatalf("mined %s, want the original %s", result.Receipt.TxHash, original.Hash())
+	}
+	if len(result.Attempts) != 2 || result.Attempts[1].Replacement || result.Attempts[1].Hash != original.Hash() {
+		t.Fatalf("want the original rebroadcast as is, got %+v", result.Attempts)
+	}
+}
+
+func TestTrackerKeepsTrackingWhenNodeKnowsTransaction(t *testing.T) {
+	chain := newTestChain(t)
+	tracker := newFastTracker(t, chain, 0)
+	request := trackRequest(t, chain, time.Now().Add(time.Hour))
+
+	// The node reports the transaction as known on the first rebroadcasts, then mines it
+	var sends atomic.Int32
+	chain.onSend(func(tx *types.Transaction) (bool, error) {
+		if sends.Add(1) < 3 {
+			return false, errors.New("already known")
+		}
+		return true, nil
+	})
+
+	result, err := track(t, tracker, request)
+	if err != nil {
+		t.Fatalf("track: %v", err)
+	}
+	if result.Receipt.TxHash != request.Tx.Hash() {
+		t.Fatalf("mined %s, want %s", result.Receipt.TxHash, request.Tx.Hash())
+	}
+	for i, attempt := range result.Attempts {
+		if attempt.Replacement || attempt.Hash != request.Tx.Hash() {
+			t.Fatalf("attempt %d: want a rebroadcast of the original, got %+v", i, attempt)
+		}
+	}
+	if len(result.Attempts) < 4 {
+		t.Fatalf("want the original and 3 rebroadcasts, got %d attempts", len(result.Attempts))
+	}
+}
+
+func TestTrackerStopsWhenAuthorizationExpires(t *testing.T) {
+	chain := newTestChain(t)
+	tracker := newFastTracker(t, chain, 1)
+	request := trackRequest(t, chain, time.Now().Add(100*time.Millisecond))
+
+	// Nothing ever reaches the chain
+	chain.onSend(func(*types.Transaction) (bool, error) {
+		return false, nil
+	})
+
+	result, err := track(t, tracker, request)
+	if !errors.Is(err, ErrAuthorizationExpired) {
+		t.Fatalf("want ErrAuthorizationExpired, got %v", err)
+	}
+	if result.Receipt != nil {
+		t.Fatalf("want no receipt, got %s", result.Receipt.TxHash)
+	}
+	if len(result.Attempts) < 2 {
+		t.Fatalf("want the broadcasts before expiry recorded, got %+v", result.Attempts)
+	}
+}
+
+func TestTrackerHandsOffAtTrackingDeadline(t *testing.T) {
+	chain := newTestChain(t)
+	tracker := newFastTracker(t, chain, 1)
+	tracker.maxTracking = 100 * time.Millisecond
+	// The authorization never expires, only the tracking deadline stops tracking
+	request := trackRequest(t, chain, ValidBeforeTime(new(big.Int).Lsh(big.NewInt(1), 255)))
+
+	chain.onSend(func(*types.Transaction) (bool, error) {
+		return false, nil
+	})
+
+	started := time.Now()
+	result, err := track(t, tracker, request)
+	if !errors.Is(err, ErrTrackingDeadline) {
+		t.Fatalf("want ErrTrackingDeadline, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("tracked for %s past a 100ms deadline", elapsed)
+	}
+	if result.Receipt != nil || len(result.Attempts) < 2 {
+		t.Fatalf("want the broadcasts before the deadline and no receipt, got %+v", result)
+	}
+}
+
+func TestIsKnownTxError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("already known"), true},
+		{errors.New("known transaction: 0xabc"), true},
+		{fmt.Errorf("send: %w", errors.New("nonce too low: next nonce 5, tx nonce 4")), true},
+		{errors.New("Nonce Too Low"), true},
+		{errors.New("replacement transaction underpriced"), false},
+		{errors.New("insufficient funds for gas * price + value"), false},
+		{errors.New("connection refused"), false},
+	} {
+		if got := isKnownTxError(tc.err); got != tc.want {
+			t.Errorf("isKnownTxError(%q) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
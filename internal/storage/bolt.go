@@ -0,0 +1,458 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// settlementsBucket holds settlement records keyed by their time-ordered ID
+	settlementsBucket = []byte("settlements")
+	// deferredBucket holds deferred settlements keyed by their time-ordered ID
+	deferredBucket = []byte("deferred")
+	// screeningsBucket holds compliance screening records keyed by their time-ordered ID
+	screeningsBucket = []byte("screenings")
+	// txHashIndexBucket indexes settlement records by every transaction hash they broadcast
+	txHashIndexBucket = []byte("settlements_by_tx_hash")
+	// nonceIndexBucket indexes settlement records by authorization nonce
+	nonceIndexBucket = []byte("settlements_by_nonce")
+	// deferredStatusIndexBucket indexes deferred settlements by status
+	deferredStatusIndexBucket = []byte("deferred_by_status")
+)
+
+// BoltLedger is a Store backed by an embedded bbolt database file
+type BoltLedger struct {
+	db *bolt.DB
+}
+
+// NewBoltLedger opens or creates the bbolt database at path
+func NewBoltLedger(path string) (*BoltLedger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory for %s: %w", path, err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage at %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		// Ledgers created before the indexes existed get them built from their records
+		reindex := tx.Bucket(txHashIndexBucket) == nil || tx.Bucket(nonceIndexBucket) == nil
+		reindexDeferred := tx.Bucket(deferredStatusIndexBucket) == nil
+		buckets := [][]byte{settlementsBucket, deferredBucket, screeningsBucket, txHashIndexBucket, nonceIndexBucket, deferredStatusIndexBucket}
+		for _, bucket := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		if reindex {
+			err := tx.Bucket(settlementsBucket).ForEach(func(key, data []byte) error {
+				record := &SettlementRecord{}
+				if err := json.Unmarshal(data, record); err != nil {
+					return fmt.Errorf("failed to decode record %s: %w", key, err)
+				}
+				return indexRecord(tx, record)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if reindexDeferred {
+			return tx.Bucket(deferredBucket).ForEach(func(key, data []byte) error {
+				item := &DeferredSettlement{}
+				if err := json.Unmarshal(data, item); err != nil {
+					return fmt.Errorf("failed to decode deferred settlement %s: %w", key, err)
+				}
+				return tx.Bucket(deferredStatusIndexBucket).Put(indexKey(string(item.Status), item.ID), nil)
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize storage at %s: %w", path, err)
+	}
+
+	return &BoltLedger{db: db}, nil
+}
+
+// Save inserts or replaces a record
+func (b *BoltLedger) Save(ctx context.Context, record *SettlementRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode record %s: %w", record.ID, err)
+	}
+
+	// Concurrent saves are coalesced into one transaction and one fsync
+	return b.db.Batch(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(settlementsBucket).Put([]byte(record.ID), data); err != nil {
+			return err
+		}
+		return indexRecord(tx, record)
+	})
+}
+
+// indexKey builds the key of a record in an index, the indexed value followed by the record's ID
+func indexKey(value, id string) []byte {
+	return append([]byte(strings.ToLower(value)+"\x00"), id...)
+}
+
+// indexRecord adds the index entries of a record
+// Entries of transaction hashes a record no longer lists are left behind, queries re-check every indexed record
+func indexRecord(tx *bolt.Tx, record *SettlementRecord) error {
+	hashes := tx.Bucket(txHashIndexBucket)
+	for _, hash := range recordTxHashes(record) {
+		if err := hashes.Put(indexKey(hash, record.ID), nil); err != nil {
+			return err
+		}
+	}
+	if record.Nonce != "" {
+		return tx.Bucket(nonceIndexBucket).Put(indexKey(record.Nonce, record.ID), nil)
+	}
+	return nil
+}
+
+// unindexRecord removes the index entries of a record
+func unindexRecord(tx *bolt.Tx, record *SettlementRecord) error {
+	hashes := tx.Bucket(txHashIndexBucket)
+	for _, hash := range recordTxHashes(record) {
+		if err := hashes.Delete(indexKey(hash, record.ID)); err != nil {
+			return err
+		}
+	}
+	if record.Nonce != "" {
+		return tx.Bucket(nonceIndexBucket).Delete(indexKey(record.Nonce, record.ID))
+	}
+	return nil
+}
+
+// recordTxHashes returns the final and broadcast transaction hashes of a record
+func recordTxHashes(record *SettlementRecord) []string {
+	hashes := record.TxHashes
+	if record.TxHash != "" {
+		hashes = append([]string{record.TxHash}, hashes...)
+	}
+	return hashes
+}
+
+// Get returns the record with the given ID or ErrNotFound
+func (b *BoltLedger) Get(ctx context.Context, id string) (*SettlementRecord, error) {
+	var record *SettlementRecord
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(settlementsBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		record = &SettlementRecord{}
+		return json.Unmarshal(data, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Query returns the records matching the filter, newest first
+// Filters on a transaction hash or nonce are answered from their index, others walk the records from the newest
+func (b *BoltLedger) Query(ctx context.Context, filter Filter) ([]*SettlementRecord, error) {
+	switch {
+	case filter.TxHash != "":
+		return b.queryIndex(ctx, txHashIndexBucket, filter.TxHash, filter)
+	case filter.Nonce != "":
+		return b.queryIndex(ctx, nonceIndexBucket, filter.Nonce, filter)
+	}
+
+	records := make([]*SettlementRecord, 0)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(settlementsBucket).Cursor()
+		// IDs are time-ordered, so walking backwards yields the newest records first
+		for key, data := cursor.Last(); key != nil; key, data = cursor.Prev() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			record := &SettlementRecord{}
+			if err := json.Unmarshal(data, record); err != nil {
+				return fmt.Errorf("failed to decode record %s: %w", key, err)
+			}
+			if !filter.Until.IsZero() && record.CreatedAt.After(filter.Until) {
+				continue
+			}
+			if !filter.Since.IsZero() && record.CreatedAt.Before(filter.Since) {
+				break
+			}
+			if !filter.Matches(record) {
+				continue
+			}
+
+			records = append(records, record)
+			if len(records) >= filter.limit() {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// queryIndex returns the records listed under value in an index that match the filter, newest first
+func (b *BoltLedger) queryIndex(ctx context.Context, index []byte, value string, filter Filter) ([]*SettlementRecord, error) {
+	records := make([]*SettlementRecord, 0)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		settlements := tx.Bucket(settlementsBucket)
+		prefix := indexKey(value, "")
+		cursor := tx.Bucket(index).Cursor()
+		for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			id := key[len(prefix):]
+			data := settlements.Get(id)
+			if data == nil {
+				continue
+			}
+			record := &SettlementRecord{}
+			if err := json.Unmarshal(data, record); err != nil {
+				return fmt.Errorf("failed to decode record %s: %w", id, err)
+			}
+			if filter.Matches(record) {
+				records = append(records, record)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortNewestFirst(records)
+	if len(records) > filter.limit() {
+		records = records[:filter.limit()]
+	}
+	return records, nil
+}
+
+// Prune deletes the records created before before with their index entries and returns how many were deleted
+func (b *BoltLedger) Prune(ctx context.Context, before time.Time) (int, error) {
+	pruned := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(settlementsBucket)
+		cursor := bucket.Cursor()
+		// IDs are time-ordered, so the records to delete are the first ones
+		for key, data := cursor.First(); key != nil && bytes.Compare(key, recordIDBefore(before)) < 0; key, data = cursor.First() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			record := &SettlementRecord{}
+			if err := json.Unmarshal(data, record); err == nil {
+				if err := unindexRecord(tx, record); err != nil {
+					return err
+				}
+			}
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+			pruned++
+		}
+		return nil
+	})
+	return pruned, err
+}
+
+// Close releases the ledger's resources
+func (b *BoltLedger) Close() error {
+	return b.db.Close()
+}
+
+// SaveDeferred inserts or replaces a deferred settlement
+func (b *BoltLedger) SaveDeferred(ctx context.Context, item *DeferredSettlement) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode deferred settlement %s: %w", item.ID, err)
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(deferredBucket)
+		index := tx.Bucket(deferredStatusIndexBucket)
+		if previous := bucket.Get([]byte(item.ID)); previous != nil {
+			stored := &DeferredSettlement{}
+			if err := json.Unmarshal(previous, stored); err == nil && stored.Status != item.Status {
+				if err := index.Delete(indexKey(string(stored.Status), item.ID)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := bucket.Put([]byte(item.ID), data); err != nil {
+			return err
+		}
+		return index.Put(indexKey(string(item.Status), item.ID), nil)
+	})
+}
+
+// GetDeferred returns the deferred settlement with the given ID or ErrNotFound
+func (b *BoltLedger) GetDeferred(ctx context.Context, id string) (*DeferredSettlement, error) {
+	var item *DeferredSettlement
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(deferredBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		item = &DeferredSettlement{}
+		return json.Unmarshal(data, item)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// ListDeferred returns the deferred settlements in the given status, oldest first
+// Only the settlements in the status are read, through the status index
+func (b *BoltLedger) ListDeferred(ctx context.Context, status DeferredStatus) ([]*DeferredSettlement, error) {
+	items := make([]*DeferredSettlement, 0)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(deferredBucket)
+		prefix := indexKey(string(status), "")
+		cursor := tx.Bucket(deferredStatusIndexBucket).Cursor()
+		// IDs are time-ordered, so the index lists the settlements of a status oldest first
+		for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			id := key[len(prefix):]
+			data := bucket.Get(id)
+			if data == nil {
+				continue
+			}
+			item := &DeferredSettlement{}
+			if err := json.Unmarshal(data, item); err != nil {
+				return fmt.Errorf("failed to decode deferred settlement %s: %w", id, err)
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// PruneDeferred deletes the settled and failed deferred settlements last updated before before and returns how
+// many were deleted, pending and settling ones are kept
+func (b *BoltLedger) PruneDeferred(ctx context.Context, before time.Time) (int, error) {
+	pruned := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(deferredBucket)
+		index := tx.Bucket(deferredStatusIndexBucket)
+		for _, status := range []DeferredStatus{DeferredSettled, DeferredFailed} {
+			prefix := indexKey(string(status), "")
+			var expired [][]byte
+			cursor := index.Cursor()
+			for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				item := &DeferredSettlement{}
+				data := bucket.Get(key[len(prefix):])
+				if data != nil && json.Unmarshal(data, item) == nil && !item.UpdatedAt.Before(before) {
+					continue
+				}
+				expired = append(expired, append([]byte(nil), key...))
+			}
+			for _, key := range expired {
+				if err := bucket.Delete(key[len(prefix):]); err != nil {
+					return err
+				}
+				if err := index.Delete(key); err != nil {
+					return err
+				}
+				pruned++
+			}
+		}
+		return nil
+	})
+	return pruned, err
+}
+
+// SaveScreening inserts a screening record
+func (b *BoltLedger) SaveScreening(ctx context.Context, record *ScreeningRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode screening record %s: %w", record.ID, err)
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(screeningsBucket).Put([]byte(record.ID), data)
+	})
+}
+
+// PruneScreenings deletes the screening records created before before and returns how many were deleted
+func (b *BoltLedger) PruneScreenings(ctx context.Context, before time.Time) (int, error) {
+	pruned := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(screeningsBucket)
+		cursor := bucket.Cursor()
+		// IDs are time-ordered, so the records to delete are the first ones
+		for key, _ := cursor.First(); key != nil && bytes.Compare(key, recordIDBefore(before)) < 0; key, _ = cursor.First() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+			pruned++
+		}
+		return nil
+	})
+	return pruned, err
+}
+
+// QueryScreenings returns the screening records matching the filter, newest first
+func (b *BoltLedger) QueryScreenings(ctx context.Context, filter ScreeningFilter) ([]*ScreeningRecord, error) {
+	records := make([]*ScreeningRecord, 0)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(screeningsBucket).Cursor()
+		// IDs are time-ordered, so walking backwards yields the newest records first
+		for key, data := cursor.Last(); key != nil; key, data = cursor.Prev() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			record := &ScreeningRecord{}
+			if err := json.Unmarshal(data, record); err != nil {
+				return fmt.Errorf("failed to decode screening record %s: %w", key, err)
+			}
+			if !filter.Until.IsZero() && record.CreatedAt.After(filter.Until) {
+				continue
+			}
+			if !filter.Since.IsZero() && record.CreatedAt.Before(filter.Since) {
+				break
+			}
+			if !filter.Matches(record) {
+				continue
+			}
+
+			records = append(records, record)
+			if len(records) >= filter.limit() {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
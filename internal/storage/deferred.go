@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/pkg/attestation"
+
+	"github.com/google/uuid"
+)
+
+// DeferredStatus is the state of a deferred settlement
+type DeferredStatus string
+
+const (
+	// DeferredPending means the payment is queued for the next flush
+	DeferredPending DeferredStatus = "pending"
+	// DeferredSettling means the payment is being settled by a flush
+	DeferredSettling DeferredStatus = "settling"
+	// DeferredSettled means the payment was settled on-chain
+	DeferredSettled DeferredStatus = "settled"
+	// DeferredFailed means the payment could not be settled
+	DeferredFailed DeferredStatus = "failed"
+)
+
+// DeferredSettlement is a verified payment accepted for later settlement in bulk
+type DeferredSettlement struct {
+	ID string `json:"id"`
+	// RecordID is the ledger record tracking the payment
+	RecordID string `json:"recordId"`
+	Network  string `json:"network"`
+	Payer    string `json:"payer"`
+	Value    string `json:"value"`
+	// ValidBefore is the authorization's expiry in Unix seconds, far-future values are clamped
+	ValidBefore int64                    `json:"validBefore"`
+	Request     models.SettleRequest     `json:"request"`
+	Status      DeferredStatus           `json:"status"`
+	ErrorReason string                   `json:"errorReason,omitempty"`
+	TxHash      string                   `json:"txHash,omitempty"`
+	Attestation *attestation.Attestation `json:"attestation,omitempty"`
+	CreatedAt   time.Time                `json:"createdAt"`
+	UpdatedAt   time.Time                `json:"updatedAt"`
+}
+
+// NewDeferredSettlement creates a pending deferred settlement with a time-ordered ID
+func NewDeferredSettlement(recordID string, request models.SettleRequest, validBefore time.Time) *DeferredSettlement {
+	now := time.Now().UTC()
+	return &DeferredSettlement{
+		ID:          fmt.Sprintf("%019d-%s", now.UnixNano(), uuid.New().String()[:8]),
+		RecordID:    recordID,
+		Network:     request.PaymentRequirements.Network,
+		Payer:       request.PaymentPayload.Payload.Authorization.From,
+		Value:       request.PaymentPayload.Payload.Authorization.Value,
+		ValidBefore: validBefore.Unix(),
+		Request:     request,
+		Status:      DeferredPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// DeferredQueue durably stores deferred settlements
+type DeferredQueue interface {
+	// SaveDeferred inserts or replaces a deferred settlement
+	SaveDeferred(ctx context.Context, item *DeferredSettlement) error
+	// GetDeferred returns the deferred settlement with the given ID or ErrNotFound
+	GetDeferred(ctx context.Context, id string) (*DeferredSettlement, error)
+	// ListDeferred returns the deferred settlements in the given status, oldest first
+	ListDeferred(ctx context.Context, status DeferredStatus) ([]*DeferredSettlement, error)
+	// PruneDeferred deletes the settled and failed deferred settlements last updated before before and returns how
+	// many were deleted
+	PruneDeferred(ctx context.Context, before time.Time) (int, error)
+}
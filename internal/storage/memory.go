@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryLedger is an in-memory Store, intended for tests and development
+type MemoryLedger struct {
+	mu         sync.RWMutex
+	records    map[string]*SettlementRecord
+	deferred   map[string]*DeferredSettlement
+	screenings []*ScreeningRecord
+}
+
+// NewMemoryLedger creates a new MemoryLedger
+func NewMemoryLedger() *MemoryLedger {
+	return &MemoryLedger{
+		records:  make(map[string]*SettlementRecord),
+		deferred: make(map[string]*DeferredSettlement),
+	}
+}
+
+// Save inserts or replaces a record
+func (m *MemoryLedger) Save(ctx context.Context, record *SettlementRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records[record.ID] = cloneRecord(record)
+	return nil
+}
+
+// Get returns the record with the given ID or ErrNotFound
+func (m *MemoryLedger) Get(ctx context.Context, id string) (*SettlementRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	record, ok := m.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return cloneRecord(record), nil
+}
+
+// Query returns the records matching the filter, newest first
+func (m *MemoryLedger) Query(ctx context.Context, filter Filter) ([]*SettlementRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	records := make([]*SettlementRecord, 0)
+	for _, record := range m.records {
+		if filter.Matches(record) {
+			records = append(records, cloneRecord(record))
+		}
+	}
+
+	sortNewestFirst(records)
+	if len(records) > filter.limit() {
+		records = records[:filter.limit()]
+	}
+	return records, nil
+}
+
+// Prune deletes the records created before before and returns how many were deleted
+func (m *MemoryLedger) Prune(ctx context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pruned := 0
+	for id, record := range m.records {
+		if record.CreatedAt.Before(before) {
+			delete(m.records, id)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// Close releases the ledger's resources
+func (m *MemoryLedger) Close() error {
+	return nil
+}
+
+// SaveDeferred inserts or replaces a deferred settlement
+func (m *MemoryLedger) SaveDeferred(ctx context.Context, item *DeferredSettlement) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clone := *item
+	m.deferred[item.ID] = &clone
+	return nil
+}
+
+// GetDeferred returns the deferred settlement with the given ID or ErrNotFound
+func (m *MemoryLedger) GetDeferred(ctx context.Context, id string) (*DeferredSettlement, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, ok := m.deferred[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	clone := *item
+	return &clone, nil
+}
+
+// ListDeferred returns the deferred settlements in the given status, oldest first
+func (m *MemoryLedger) ListDeferred(ctx context.Context, status DeferredStatus) ([]*DeferredSettlement, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	items := make([]*DeferredSettlement, 0)
+	for _, item := range m.deferred {
+		if item.Status == status {
+			clone := *item
+			items = append(items, &clone)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+	return items, nil
+}
+
+// PruneDeferred deletes the settled and failed deferred settlements last updated before before and returns how
+// many were deleted, pending and settling ones are kept
+func (m *MemoryLedger) PruneDeferred(ctx context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pruned := 0
+	for id, item := range m.deferred {
+		if (item.Status == DeferredSettled || item.Status == DeferredFailed) && item.UpdatedAt.Before(before) {
+			delete(m.deferred, id)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// SaveScreening inserts a screening record
+func (m *MemoryLedger) SaveScreening(ctx context.Context, record *ScreeningRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clone := *record
+	m.screenings = append(m.screenings, &clone)
+	return nil
+}
+
+// PruneScreenings deletes the screening records created before before and returns how many were deleted
+func (m *MemoryLedger) PruneScreenings(ctx context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Records are appended in creation order, so the records to delete are the first ones
+	pruned := 0
+	for pruned < len(m.screenings) && m.screenings[pruned].CreatedAt.Before(before) {
+		pruned++
+	}
+	m.screenings = append([]*ScreeningRecord(nil), m.screenings[pruned:]...)
+	return pruned, nil
+}
+
+// QueryScreenings returns the screening records matching the filter, newest first
+func (m *MemoryLedger) QueryScreenings(ctx context.Context, filter ScreeningFilter) ([]*ScreeningRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	records := make([]*ScreeningRecord, 0)
+	for i := len(m.screenings) - 1; i >= 0 && len(records) < filter.limit(); i-- {
+		if filter.Matches(m.screenings[i]) {
+			clone := *m.screenings[i]
+			records = append(records, &clone)
+		}
+	}
+	return records, nil
+}
+
+// cloneRecord copies a record so stored records are not shared with callers
+func cloneRecord(record *SettlementRecord) *SettlementRecord {
+	clone := *record
+	clone.TxHashes = append([]string(nil), record.TxHashes...)
+	clone.Transitions = append([]StatusTransition(nil), record.Transitions...)
+	return &clone
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// PruneFunc deletes the entries created before before and returns how many were deleted
+type PruneFunc func(ctx context.Context, before time.Time) (int, error)
+
+// retentionPolicy is a kind of stored entries and how long they are kept
+type retentionPolicy struct {
+	name      string
+	retention time.Duration
+	prune     PruneFunc
+}
+
+// Pruner periodically deletes stored entries past their retention
+type Pruner struct {
+	interval time.Duration
+	policies []retentionPolicy
+	logger   *zap.Logger
+}
+
+// NewPruner creates a Pruner running every interval
+func NewPruner(interval time.Duration, logger *zap.Logger) *Pruner {
+	return &Pruner{
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Retain keeps the entries of prune for retention, a retention of 0 keeps them forever
+func (p *Pruner) Retain(name string, retention time.Duration, prune PruneFunc) {
+	if retention <= 0 {
+		return
+	}
+	p.policies = append(p.policies, retentionPolicy{
+		name:      name,
+		retention: retention,
+		prune:     prune,
+	})
+}
+
+// Run prunes on start and then every interval until ctx is cancelled
+func (p *Pruner) Run(ctx context.Context) {
+	if len(p.policies) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.prune(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// prune deletes the expired entries of every policy once
+func (p *Pruner) prune(ctx context.Context) {
+	for _, policy := range p.policies {
+		pruned, err := policy.prune(ctx, time.Now().Add(-policy.retention))
+		if err != nil {
+			p.logger.Error("Failed to prune expired records",
+				zap.Error(err),
+				zap.String("records", policy.name),
+			)
+			continue
+		}
+		if pruned > 0 {
+			p.logger.Info("Pruned expired records",
+				zap.String("records", policy.name),
+				zap.Int("count", pruned),
+				zap.Duration("retention", policy.retention),
+			)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ScreeningDecision is the outcome of screening an address
+type ScreeningDecision string
+
+const (
+	// ScreeningAllowed means no provider listed the address
+	ScreeningAllowed ScreeningDecision = "allowed"
+	// ScreeningBlocked means a provider listed the address and the payment was refused
+	ScreeningBlocked ScreeningDecision = "blocked"
+	// ScreeningUnavailable means a provider could not answer, the payment was refused unless screening fails open
+	ScreeningUnavailable ScreeningDecision = "unavailable"
+)
+
+// ScreeningRecord is the audit entry of one compliance screening decision about an address of a payment
+type ScreeningRecord struct {
+	ID string `json:"id"`
+	// Address is the screened address and Role its part in the payment, "payer" or "payTo"
+	Address  string            `json:"address"`
+	Role     string            `json:"role"`
+	Decision ScreeningDecision `json:"decision"`
+	// Provider is the provider that listed the address or failed to answer, empty when it was allowed
+	Provider string `json:"provider,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// FailOpen reports that an unavailable decision let the payment through
+	FailOpen  bool      `json:"failOpen,omitempty"`
+	Network   string    `json:"network"`
+	Payer     string    `json:"payer"`
+	PayTo     string    `json:"payTo"`
+	Asset     string    `json:"asset"`
+	Amount    string    `json:"amount"`
+	Nonce     string    `json:"nonce"`
+	Resource  string    `json:"resource,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NewScreeningRecord creates a screening record with a time-ordered ID
+func NewScreeningRecord() *ScreeningRecord {
+	now := time.Now().UTC()
+	return &ScreeningRecord{
+		ID:        fmt.Sprintf("%019d-%s", now.UnixNano(), uuid.New().String()[:8]),
+		CreatedAt: now,
+	}
+}
+
+// ScreeningFilter selects screening records in a query, empty fields match everything
+type ScreeningFilter struct {
+	Address  string
+	Decision ScreeningDecision
+	Network  string
+	Since    time.Time
+	Until    time.Time
+	// Limit caps the number of records returned, newest first
+	Limit int
+}
+
+// Matches reports whether a screening record is selected by the filter
+func (f ScreeningFilter) Matches(record *ScreeningRecord) bool {
+	if f.Address != "" && !strings.EqualFold(record.Address, f.Address) {
+		return false
+	}
+	if f.Decision != "" && record.Decision != f.Decision {
+		return false
+	}
+	if f.Network != "" && record.Network != f.Network {
+		return false
+	}
+	if !f.Since.IsZero() && record.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && record.CreatedAt.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// limit returns the effective limit of the filter
+func (f ScreeningFilter) limit() int {
+	if f.Limit <= 0 {
+		return DefaultQueryLimit
+	}
+	return f.Limit
+}
+
+// ScreeningLog durably stores compliance screening decisions for audit
+type ScreeningLog interface {
+	// SaveScreening inserts a screening record
+	SaveScreening(ctx context.Context, record *ScreeningRecord) error
+	// QueryScreenings returns the screening records matching the filter, newest first
+	QueryScreenings(ctx context.Context, filter ScreeningFilter) ([]*ScreeningRecord, error)
+	// PruneScreenings deletes the screening records created before before and returns how many were deleted
+	PruneScreenings(ctx context.Context, before time.Time) (int, error)
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// screeningLogs creates one of each ScreeningLog implementation
+func screeningLogs(t *testing.T) map[string]ScreeningLog {
+	t.Helper()
+
+	bolt, err := NewBoltLedger(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatalf("new bolt ledger: %v", err)
+	}
+	t.Cleanup(func() { bolt.Close() })
+	return map[string]ScreeningLog{
+		"memory": NewMemoryLedger(),
+		"bolt":   bolt,
+	}
+}
+
+// screeningAt creates a screening record created at createdAt
+func screeningAt(createdAt time.Time, address string, decision ScreeningDecision) *ScreeningRecord {
+	return &ScreeningRecord{
+		ID:        newRecordID(createdAt),
+		Address:   address,
+		Decision:  decision,
+		Network:   "local",
+		CreatedAt: createdAt,
+	}
+}
+
+// saveScreenings saves records in order
+func saveScreenings(t *testing.T, log ScreeningLog, records ...*ScreeningRecord) {
+	t.Helper()
+
+	for _, record := range records {
+		if err := log.SaveScreening(context.Background(), record); err != nil {
+			t.Fatalf("save screening %s: %v", record.ID, err)
+		}
+	}
+}
+
+// screeningIDs returns the IDs of records
+func screeningIDs(records []*ScreeningRecord) []string {
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = record.ID
+	}
+	return ids
+}
+
+func TestScreeningLogQueriesNewestFirst(t *testing.T) {
+	now := time.Now().UTC()
+	old := screeningAt(now.Add(-3*time.Hour), "0x00000000000000000000000000000000000000aa", ScreeningAllowed)
+	blocked := screeningAt(now.Add(-2*time.Hour), "0x00000000000000000000000000000000000000BB", ScreeningBlocked)
+	recent := screeningAt(now.Add(-time.Hour), "0x00000000000000000000000000000000000000aa", ScreeningBlocked)
+
+	for name, log := range screeningLogs(t) {
+		t.Run(name, func(t *testing.T) {
+			saveScreenings(t, log, old, blocked, recent)
+
+			for _, tc := range []struct {
+				name   string
+				filter ScreeningFilter
+				want   []string
+			}{
+				{"everything", ScreeningFilter{}, []string{recent.ID, blocked.ID, old.ID}},
+				{"address regardless of case", ScreeningFilter{Address: "0x00000000000000000000000000000000000000bb"}, []string{blocked.ID}},
+				{"decision", ScreeningFilter{Decision: ScreeningBlocked}, []string{recent.ID, blocked.ID}},
+				{"other network", ScreeningFilter{Network: "base"}, []string{}},
+				{"since", ScreeningFilter{Since: now.Add(-150 * time.Minute)}, []string{recent.ID, blocked.ID}},
+				{"until", ScreeningFilter{Until: now.Add(-150 * time.Minute)}, []string{old.ID}},
+				{"limit", ScreeningFilter{Limit: 1}, []string{recent.ID}},
+			} {
+				t.Run(tc.name, func(t *testing.T) {
+					records, err := log.QueryScreenings(context.Background(), tc.filter)
+					if err != nil {
+						t.Fatalf("query screenings: %v", err)
+					}
+					got := screeningIDs(records)
+					if len(got) != len(tc.want) {
+						t.Fatalf("got %v, want %v", got, tc.want)
+					}
+					for i := range got {
+						if got[i] != tc.want[i] {
+							t.Fatalf("got %v, want %v", got, tc.want)
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestScreeningLogPrunesRecordsBeforeCutoff(t *testing.T) {
+	now := time.Now().UTC()
+	cutoff := now.Add(-24 * time.Hour)
+	expired := []*ScreeningRecord{
+		screeningAt(now.Add(-72*time.Hour), "0x00000000000000000000000000000000000000aa", ScreeningAllowed),
+		screeningAt(now.Add(-48*time.Hour), "0x00000000000000000000000000000000000000bb", ScreeningBlocked),
+	}
+	kept := []*ScreeningRecord{
+		screeningAt(cutoff, "0x00000000000000000000000000000000000000cc", ScreeningAllowed),
+		screeningAt(now.Add(-time.Hour), "0x00000000000000000000000000000000000000dd", ScreeningUnavailable),
+	}
+
+	for name, log := range screeningLogs(t) {
+		t.Run(name, func(t *testing.T) {
+			saveScreenings(t, log, append(append([]*ScreeningRecord(nil), expired...), kept...)...)
+
+			pruned, err := log.PruneScreenings(context.Background(), cutoff)
+			if err != nil {
+				t.Fatalf("prune screenings: %v", err)
+			}
+			if pruned != len(expired) {
+				t.Fatalf("pruned %d records, want %d", pruned, len(expired))
+			}
+
+			records, err := log.QueryScreenings(context.Background(), ScreeningFilter{})
+			if err != nil {
+				t.Fatalf("query screenings: %v", err)
+			}
+			got := screeningIDs(records)
+			if len(got) != 2 || got[0] != kept[1].ID || got[1] != kept[0].ID {
+				t.Fatalf("kept %v, want the records created at or after the cutoff", got)
+			}
+
+			// Pruning again finds nothing left to delete
+			if pruned, err := log.PruneScreenings(context.Background(), cutoff); err != nil || pruned != 0 {
+				t.Fatalf("second prune: pruned %d, err %v, want nothing", pruned, err)
+			}
+		})
+	}
+}
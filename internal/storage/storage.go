@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"x402-facilitator-go/internal/config"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when a record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// Kind is the kind of facilitator request a record was created for
+type Kind string
+
+const (
+	// KindVerify records a /verify request
+	KindVerify Kind = "verify"
+	// KindSettle records a /settle request
+	KindSettle Kind = "settle"
+)
+
+// Status is the state of a recorded attempt
+type Status string
+
+const (
+	// StatusReceived means the request was accepted and is being processed
+	StatusReceived Status = "received"
+	// StatusVerified means the payment passed verification
+	StatusVerified Status = "verified"
+	// StatusInvalid means the payment failed verification
+	StatusInvalid Status = "invalid"
+	// StatusQueued means the verified payment was queued for deferred settlement
+	StatusQueued Status = "queued"
+	// StatusSubmitted means the settlement transaction was broadcast
+	StatusSubmitted Status = "submitted"
+	// StatusConfirmed means the settlement transaction was mined successfully with the required confirmations
+	StatusConfirmed Status = "confirmed"
+	// StatusFailed means the settlement failed, before or after broadcasting, or the verification failed on the facilitator's side
+	StatusFailed Status = "failed"
+	// StatusReorged means the confirmed settlement's receipt vanished after a reorg
+	StatusReorged Status = "reorged"
+)
+
+// StatusTransition records one status change of an attempt
+type StatusTransition struct {
+	Status Status    `json:"status"`
+	Reason string    `json:"reason,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// SettlementRecord is the ledger entry of one verify or settle attempt
+type SettlementRecord struct {
+	ID          string             `json:"id"`
+	Kind        Kind               `json:"kind"`
+	Fingerprint string             `json:"fingerprint"`
+	Network     string             `json:"network"`
+	Payer       string             `json:"payer"`
+	PayTo       string             `json:"payTo"`
+	Asset       string             `json:"asset"`
+	Amount      string             `json:"amount"`
+	Nonce       string             `json:"nonce"`
+	Resource    string             `json:"resource,omitempty"`
+	Status      Status             `json:"status"`
+	ErrorReason string             `json:"errorReason,omitempty"`
+	TxHash      string             `json:"txHash,omitempty"`
+	TxHashes    []string           `json:"txHashes,omitempty"`
+	BlockNumber uint64             `json:"blockNumber,omitempty"`
+	GasUsed     uint64             `json:"gasUsed,omitempty"`
+	Transitions []StatusTransition `json:"transitions"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+}
+
+// NewSettlementRecord creates a record in the received state with a time-ordered ID
+func NewSettlementRecord(kind Kind) *SettlementRecord {
+	now := time.Now().UTC()
+	record := &SettlementRecord{
+		ID:        newRecordID(now),
+		Kind:      kind,
+		CreatedAt: now,
+	}
+	record.Transition(StatusReceived, "")
+	return record
+}
+
+// newRecordID returns a record ID starting with its creation time, so that IDs sort by age
+func newRecordID(createdAt time.Time) string {
+	return fmt.Sprintf("%019d-%s", createdAt.UnixNano(), uuid.New().String()[:8])
+}
+
+// recordIDBefore returns the smallest ID of a record created at or after t, every older record's ID sorts before it
+func recordIDBefore(t time.Time) []byte {
+	return []byte(fmt.Sprintf("%019d", t.UnixNano()))
+}
+
+// Transition moves the record to status, keeping the history of transitions
+func (r *SettlementRecord) Transition(status Status, reason string) {
+	now := time.Now().UTC()
+	r.Status = status
+	r.UpdatedAt = now
+	r.Transitions = append(r.Transitions, StatusTransition{
+		Status: status,
+		Reason: reason,
+		At:     now,
+	})
+	if reason != "" {
+		r.ErrorReason = reason
+	}
+}
+
+// Filter selects records in a query, empty fields match everything
+type Filter struct {
+	Kind    Kind
+	Network string
+	Payer   string
+	PayTo   string
+	Asset   string
+	Nonce   string
+	TxHash  string
+	Status  Status
+	Since   time.Time
+	Until   time.Time
+	// Limit caps the number of records returned, newest first
+	Limit int
+}
+
+// DefaultQueryLimit is used when a filter does not set a limit
+const DefaultQueryLimit = 100
+
+// Matches reports whether a record is selected by the filter
+func (f Filter) Matches(record *SettlementRecord) bool {
+	if f.Kind != "" && record.Kind != f.Kind {
+		return false
+	}
+	if f.Network != "" && record.Network != f.Network {
+		return false
+	}
+	if f.Payer != "" && !strings.EqualFold(record.Payer, f.Payer) {
+		return false
+	}
+	if f.PayTo != "" && !strings.EqualFold(record.PayTo, f.PayTo) {
+		return false
+	}
+	if f.Asset != "" && !strings.EqualFold(record.Asset, f.Asset) {
+		return false
+	}
+	if f.Nonce != "" && !strings.EqualFold(record.Nonce, f.Nonce) {
+		return false
+	}
+	if f.TxHash != "" && !matchesTxHash(record, f.TxHash) {
+		return false
+	}
+	if f.Status != "" && record.Status != f.Status {
+		return false
+	}
+	if !f.Since.IsZero() && record.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && record.CreatedAt.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// limit returns the effective limit of the filter
+func (f Filter) limit() int {
+	if f.Limit <= 0 {
+		return DefaultQueryLimit
+	}
+	return f.Limit
+}
+
+// matchesTxHash reports whether txHash is the record's final or any broadcast transaction
+func matchesTxHash(record *SettlementRecord, txHash string) bool {
+	if strings.EqualFold(record.TxHash, txHash) {
+		return true
+	}
+	for _, hash := range record.TxHashes {
+		if strings.EqualFold(hash, txHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortNewestFirst orders records by creation time, newest first
+func sortNewestFirst(records []*SettlementRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID > records[j].ID
+	})
+}
+
+// Ledger stores settlement records
+type Ledger interface {
+	// Save inserts or replaces a record
+	Save(ctx context.Context, record *SettlementRecord) error
+	// Get returns the record with the given ID or ErrNotFound
+	Get(ctx context.Context, id string) (*SettlementRecord, error)
+	// Query returns the records matching the filter, newest first
+	Query(ctx context.Context, filter Filter) ([]*SettlementRecord, error)
+	// Prune deletes the records created before before and returns how many were deleted
+	Prune(ctx context.Context, before time.Time) (int, error)
+	// Close releases the ledger's resources
+	Close() error
+}
+
+// Store is a storage backend holding the settlement ledger, the deferred settlement queue and the compliance
+// screening log
+type Store interface {
+	Ledger
+	DeferredQueue
+	ScreeningLog
+}
+
+// NewStore creates the storage backend selected by the storage configuration
+func NewStore(cfg config.StorageConfig) (Store, error) {
+	switch cfg.Driver {
+	case DriverBolt:
+		return NewBoltLedger(cfg.Path)
+	case DriverMemory:
+		return NewMemoryLedger(), nil
+	default:
+		return nil, fmt.Errorf("unsupported storage driver: %s", cfg.Driver)
+	}
+}
+
+const (
+	// DriverBolt selects the embedded on-disk bbolt backend
+	DriverBolt = "bolt"
+	// DriverMemory selects the in-memory backend
+	DriverMemory = "memory"
+)
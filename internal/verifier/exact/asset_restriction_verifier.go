@@ -0,0 +1,104 @@
+package exact
+
+import (
+	"context"
+	"fmt"
+	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/verifier"
+	"x402-facilitator-go/internal/web3"
+	"x402-facilitator-go/pkg/errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+// AssetRestrictionVerifier verifies that USDC-style transfer restrictions of the asset do not block the payment
+// Assets without paused() or isBlacklisted(address) are not restricted by them
+type AssetRestrictionVerifier struct {
+	logger     *zap.Logger
+	web3Client web3.Chain
+}
+
+// NewAssetRestrictionVerifier creates a new AssetRestrictionVerifier
+func NewAssetRestrictionVerifier(logger *zap.Logger, web3Client web3.Chain) *AssetRestrictionVerifier {
+	return &AssetRestrictionVerifier{
+		logger:     logger,
+		web3Client: web3Client,
+	}
+}
+
+// Verify verifies that the asset is not paused and blacklists neither the payer nor the payTo, which would make
+// transferWithAuthorization revert
+func (a *AssetRestrictionVerifier) Verify(ctx context.Context, request *models.VerifyRequest) verifier.VerificationResult {
+	ethCli, err := a.web3Client.GetClient(request.PaymentRequirements.Network)
+	if err != nil {
+		return verifier.Fail(
+			errors.ErrorNetworkUnavailable,
+			fmt.Sprintf("Failed to get client for network: %v", err),
+		)
+	}
+
+	calls, err := a.restrictionCalls(request)
+	if err != nil {
+		return verifier.Fail(errors.ErrorUnknown, fmt.Sprintf("Failed to pack restriction calls: %v", err))
+	}
+
+	// calls are paused(), isBlacklisted(payer) and isBlacklisted(payTo), in the order of their error codes
+	// Blacklisted accounts get the code a settlement reverting with the asset's blacklist reason maps to
+	failures := []struct {
+		code    errors.X402Error
+		message string
+	}{
+		{errors.ErrorAssetPaused, "Asset transfers are paused"},
+		{errors.ErrorAccountBlacklisted, "Payer is blacklisted by the asset"},
+		{errors.ErrorAccountBlacklisted, "PayTo is blacklisted by the asset"},
+	}
+	caller := web3.PrefetchedCaller(ctx, ethCli)
+	for i, call := range calls {
+		restricted, exposed, err := web3.ReadRestriction(ctx, caller, call)
+		if err != nil {
+			return verifier.FailChainRead(err, fmt.Sprintf("Failed to read asset restrictions: %v", err))
+		}
+		if !exposed {
+			a.logger.Debug("Asset does not expose restriction",
+				zap.String("network", request.PaymentRequirements.Network),
+				zap.String("asset", request.PaymentRequirements.Asset),
+				zap.String("restriction", failures[i].message),
+			)
+			continue
+		}
+		if restricted {
+			return verifier.Fail(failures[i].code, failures[i].message)
+		}
+	}
+
+	return verifier.OK()
+}
+
+// Reads returns the paused() and isBlacklisted calls of the payer and payTo
+func (a *AssetRestrictionVerifier) Reads(request *models.VerifyRequest) []web3.Call {
+	calls, err := a.restrictionCalls(request)
+	if err != nil {
+		return nil
+	}
+	return calls
+}
+
+// restrictionCalls returns the paused(), isBlacklisted(payer) and isBlacklisted(payTo) calls of the request's asset
+func (a *AssetRestrictionVerifier) restrictionCalls(request *models.VerifyRequest) ([]web3.Call, error) {
+	return web3.RestrictionCalls(
+		common.HexToAddress(request.PaymentRequirements.Asset),
+		common.HexToAddress(request.PaymentPayload.Payload.Authorization.From),
+		common.HexToAddress(request.PaymentRequirements.PayTo),
+	)
+}
+
+// Type returns the verification step type
+func (a *AssetRestrictionVerifier) Type() verifier.VerificationStep {
+	return verifier.StepAssetRestrictionsForExactScheme
+}
+
+// Order returns the order in which this verifier should be executed
+func (a *AssetRestrictionVerifier) Order() int {
+	return 7
+}
@@ -0,0 +1,87 @@
+package exact
+
+import (
+	"context"
+	"fmt"
+	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/verifier"
+	"x402-facilitator-go/internal/web3"
+	"x402-facilitator-go/internal/web3/contract"
+	"x402-facilitator-go/pkg/errors"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+// AuthorizationStateVerifier verifies that the authorization's nonce was neither used nor canceled on-chain
+type AuthorizationStateVerifier struct {
+	logger     *zap.Logger
+	web3Client web3.Chain
+}
+
+// NewAuthorizationStateVerifier creates a new AuthorizationStateVerifier
+func NewAuthorizationStateVerifier(logger *zap.Logger, web3Client web3.Chain) *AuthorizationStateVerifier {
+	return &AuthorizationStateVerifier{
+		logger:     logger,
+		web3Client: web3Client,
+	}
+}
+
+// Verify verifies that authorizationState(from, nonce) is false, since transferWithAuthorization reverts otherwise
+func (a *AuthorizationStateVerifier) Verify(ctx context.Context, request *models.VerifyRequest) verifier.VerificationResult {
+	ethCli, err := a.web3Client.GetClient(request.PaymentRequirements.Network)
+	if err != nil {
+		return verifier.Fail(
+			errors.ErrorNetworkUnavailable,
+			fmt.Sprintf("Failed to get client for network: %v", err),
+		)
+	}
+
+	token, err := contract.NewEIP3009TokenCaller(
+		common.HexToAddress(request.PaymentRequirements.Asset),
+		web3.PrefetchedCaller(ctx, ethCli),
+	)
+	if err != nil {
+		return verifier.Fail(errors.ErrorUnknown, fmt.Sprintf("Failed to bind EIP3009 token: %v", err))
+	}
+
+	authorization := request.PaymentPayload.Payload.Authorization
+	used, err := token.AuthorizationState(
+		&bind.CallOpts{Context: ctx},
+		common.HexToAddress(authorization.From),
+		common.HexToHash(authorization.Nonce),
+	)
+	if err != nil {
+		return verifier.FailChainRead(err, fmt.Sprintf("Failed to read authorization state: %v", err))
+	}
+	if used {
+		return verifier.Fail(errors.ErrorAuthorizationUsed, "Authorization nonce is already used or canceled")
+	}
+
+	return verifier.OK()
+}
+
+// Reads returns the authorizationState call of the payer and nonce
+func (a *AuthorizationStateVerifier) Reads(request *models.VerifyRequest) []web3.Call {
+	authorization := request.PaymentPayload.Payload.Authorization
+	call, err := web3.AuthorizationStateCall(
+		common.HexToAddress(request.PaymentRequirements.Asset),
+		common.HexToAddress(authorization.From),
+		common.HexToHash(authorization.Nonce),
+	)
+	if err != nil {
+		return nil
+	}
+	return []web3.Call{call}
+}
+
+// Type returns the verification step type
+func (a *AuthorizationStateVerifier) Type() verifier.VerificationStep {
+	return verifier.StepAuthorizationStateForExactScheme
+}
+
+// Order returns the order in which this verifier should be executed
+func (a *AuthorizationStateVerifier) Order() int {
+	return 5
+}
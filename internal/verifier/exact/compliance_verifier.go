@@ -0,0 +1,58 @@
+package exact
+
+import (
+	"context"
+	"fmt"
+	"x402-facilitator-go/internal/compliance"
+	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/storage"
+	"x402-facilitator-go/internal/verifier"
+	"x402-facilitator-go/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// ComplianceVerifier verifies that neither the payer nor the payTo is listed by a compliance screening provider
+// It runs after the signature check, so that only authorizations actually signed by the payer are screened
+type ComplianceVerifier struct {
+	logger   *zap.Logger
+	screener *compliance.Screener
+}
+
+// NewComplianceVerifier creates a new ComplianceVerifier
+func NewComplianceVerifier(logger *zap.Logger, screener *compliance.Screener) *ComplianceVerifier {
+	return &ComplianceVerifier{
+		logger:   logger,
+		screener: screener,
+	}
+}
+
+// Verify screens the payer and payTo, failing when a provider lists either of them or, unless screening fails
+// open, when a provider cannot answer
+func (c *ComplianceVerifier) Verify(ctx context.Context, request *models.VerifyRequest) verifier.VerificationResult {
+	refusal := c.screener.Screen(ctx, request.PaymentRequirements, request.PaymentPayload.Payload.Authorization)
+	if refusal == nil {
+		return verifier.OK()
+	}
+
+	if refusal.Decision == storage.ScreeningUnavailable {
+		return verifier.Fail(
+			errors.ErrorComplianceUnavailable,
+			fmt.Sprintf("Compliance provider %s could not screen the %s: %s", refusal.Provider, refusal.Role, refusal.Reason),
+		)
+	}
+	return verifier.Fail(
+		errors.ErrorComplianceBlocked,
+		fmt.Sprintf("The %s is listed by compliance provider %s: %s", refusal.Role, refusal.Provider, refusal.Reason),
+	)
+}
+
+// Type returns the verification step type
+func (c *ComplianceVerifier) Type() verifier.VerificationStep {
+	return verifier.StepComplianceForExactScheme
+}
+
+// Order returns the order in which this verifier should be executed
+func (c *ComplianceVerifier) Order() int {
+	return 6
+}
@@ -6,10 +6,8 @@ import (
 	"x402-facilitator-go/internal/models"
 	"x402-facilitator-go/internal/verifier"
 	"x402-facilitator-go/internal/web3"
-	"x402-facilitator-go/internal/web3/contract"
 	"x402-facilitator-go/pkg/errors"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"go.uber.org/zap"
 )
@@ -17,48 +15,47 @@ import (
 // EIP3009AssetVerifier checks the asset supports EIP-3009 transferWithAuthorization
 type EIP3009AssetVerifier struct {
 	logger     *zap.Logger
-	web3Client *web3.Client
+	web3Client web3.Chain
+	assets     *web3.AssetCache
 }
 
 // NewEIP3009AssetVerifier creates a new EIP3009AssetVerifier
-func NewEIP3009AssetVerifier(logger *zap.Logger, web3Client *web3.Client) *EIP3009AssetVerifier {
+func NewEIP3009AssetVerifier(logger *zap.Logger, web3Client web3.Chain, assets *web3.AssetCache) *EIP3009AssetVerifier {
 	return &EIP3009AssetVerifier{
 		logger:     logger,
 		web3Client: web3Client,
+		assets:     assets,
 	}
 }
 
-// Verify verifies the asset contract supports EIP-3009 by probing authorizationState
+// Verify verifies the asset contract supports EIP-3009 by probing authorizationState, answering from the asset cache when possible
 func (v *EIP3009AssetVerifier) Verify(ctx context.Context, request *models.VerifyRequest) verifier.VerificationResult {
-	ethCli, _ := v.web3Client.GetClient(request.PaymentRequirements.Network)
+	network := request.PaymentRequirements.Network
+	if _, err := v.web3Client.GetClient(network); err != nil {
+		return verifier.Fail(
+			errors.ErrorNetworkUnavailable,
+			fmt.Sprintf("Failed to get client for network: %v", err),
+		)
+	}
 
 	contractAddr := common.HexToAddress(request.PaymentRequirements.Asset)
 
-	code, err := ethCli.CodeAt(ctx, contractAddr, nil)
+	hasCode, err := v.assets.HasCode(ctx, network, contractAddr)
 	if err != nil {
-		return verifier.Fail(
-			errors.ErrorUnknown,
-			fmt.Sprintf("Failed to fetch asset bytecode: %v", err),
-		)
+		return verifier.FailChainRead(err, fmt.Sprintf("Failed to fetch asset bytecode: %v", err))
 	}
-	if len(code) == 0 {
+	if !hasCode {
 		return verifier.Fail(
 			errors.ErrorInvalidPayload,
 			"Asset address is not a contract",
 		)
 	}
 
-	tokenContract, err := contract.NewEIP3009Token(contractAddr, ethCli)
+	supported, err := v.assets.SupportsEIP3009(ctx, network, contractAddr)
 	if err != nil {
-		return verifier.Fail(errors.ErrorUnknown, fmt.Sprintf("Failed to create EIP3009Token contract instance: %v", err))
+		return verifier.FailChainRead(err, fmt.Sprintf("Failed to probe EIP-3009 support: %v", err))
 	}
-
-	// Prepare a harmless staticcall to check method existence
-	zeroAddr := common.Address{} // 0x000...00
-	var zeroNonce [32]byte
-
-	_, err = tokenContract.AuthorizationState(&bind.CallOpts{Context: ctx}, zeroAddr, zeroNonce)
-	if err != nil {
+	if !supported {
 		// Method missing or reverted → not EIP-3009
 		return verifier.Fail(
 			errors.ErrorInvalidPayload,
@@ -69,6 +66,15 @@ func (v *EIP3009AssetVerifier) Verify(ctx context.Context, request *models.Verif
 	return verifier.OK()
 }
 
+// Reads returns the EIP-3009 support probe
+func (v *EIP3009AssetVerifier) Reads(request *models.VerifyRequest) []web3.Call {
+	probe, err := web3.EIP3009ProbeCall(common.HexToAddress(request.PaymentRequirements.Asset))
+	if err != nil {
+		return nil
+	}
+	return []web3.Call{probe}
+}
+
 // Type returns the verification step type
 func (v *EIP3009AssetVerifier) Type() verifier.VerificationStep {
 	return verifier.StepPaymentAddressForExactScheme
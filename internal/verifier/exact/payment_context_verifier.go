@@ -21,15 +21,20 @@ const (
 
 // PaymentContextVerifier verifies the payment context for exact scheme
 type PaymentContextVerifier struct {
-	web3Client *web3.Client
-	logger     *zap.Logger
+	web3Client    web3.Chain
+	clock         web3.Clock
+	skewTolerance time.Duration
+	logger        *zap.Logger
 }
 
 // NewPaymentContextVerifier creates a new PaymentContextVerifier
-func NewPaymentContextVerifier(logger *zap.Logger, web3Client *web3.Client) *PaymentContextVerifier {
+// Validity windows are checked against clock, an authorization must be valid throughout skewTolerance around its time
+func NewPaymentContextVerifier(logger *zap.Logger, web3Client web3.Chain, clock web3.Clock, skewTolerance time.Duration) *PaymentContextVerifier {
 	return &PaymentContextVerifier{
-		logger:     logger,
-		web3Client: web3Client,
+		logger:        logger,
+		web3Client:    web3Client,
+		clock:         clock,
+		skewTolerance: skewTolerance,
 	}
 }
 
@@ -69,6 +74,12 @@ func (p *PaymentContextVerifier) Verify(ctx context.Context, request *models.Ver
 			fmt.Sprintf("Network not supported: '%s'", paymentRequirements.Network),
 		)
 	}
+	if !p.web3Client.Available(paymentRequirements.Network) {
+		return verifier.Fail(
+			errors.ErrorNetworkUnavailable,
+			fmt.Sprintf("Network temporarily unavailable: '%s'", paymentRequirements.Network),
+		)
+	}
 	// Networks must match
 	if paymentPayload.Network != paymentRequirements.Network {
 		return verifier.Fail(
@@ -108,18 +119,24 @@ func (p *PaymentContextVerifier) Verify(ctx context.Context, request *models.Ver
 	// Validate validAfter and validBefore (decimal strings, validBefore > validAfter)
 	validAfter, _ := new(big.Int).SetString(authorization.ValidAfter, 10)
 	validBefore, _ := new(big.Int).SetString(authorization.ValidBefore, 10)
-	// Validate current time falls within [validAfter, validBefore]
-	now := big.NewInt(time.Now().Unix())
-	if now.Cmp(validAfter) <= 0 {
+	// Validate the clock's time falls within [validAfter, validBefore], with the skew tolerance on both edges
+	clockTime, err := p.clock.Now(ctx, paymentRequirements.Network)
+	if err != nil {
+		return verifier.FailChainRead(err, fmt.Sprintf("Failed to get the time of network '%s': %v", paymentRequirements.Network, err))
+	}
+	now := big.NewInt(clockTime.Unix())
+	earliest := big.NewInt(clockTime.Add(-p.skewTolerance).Unix())
+	latest := big.NewInt(clockTime.Add(p.skewTolerance).Unix())
+	if earliest.Cmp(validAfter) <= 0 {
 		return verifier.Fail(
 			errors.ErrorInvalidExactEVMPayloadAuthorizationValidAfter,
-			fmt.Sprintf("Authorization not yet valid: now=%d <= validAfter=%s", now, authorization.ValidAfter),
+			fmt.Sprintf("Authorization not yet valid: now=%d <= validAfter=%s (skew tolerance %s)", now, authorization.ValidAfter, p.skewTolerance),
 		)
 	}
-	if now.Cmp(validBefore) >= 0 {
+	if latest.Cmp(validBefore) >= 0 {
 		return verifier.Fail(
 			errors.ErrorInvalidExactEVMPayloadAuthorizationValidBefore,
-			fmt.Sprintf("Authorization expired: now=%d >= validBefore=%s", now, authorization.ValidBefore),
+			fmt.Sprintf("Authorization expired: now=%d >= validBefore=%s (skew tolerance %s)", now, authorization.ValidBefore, p.skewTolerance),
 		)
 	}
 
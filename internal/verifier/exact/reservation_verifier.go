@@ -0,0 +1,62 @@
+package exact
+
+import (
+	"context"
+	stderrors "errors"
+	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/settlement"
+	"x402-facilitator-go/internal/verifier"
+	"x402-facilitator-go/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// ReservationVerifier holds a verified authorization for the payTo and resource of the request
+// It runs last, so that only authorizations that passed every other check are held
+type ReservationVerifier struct {
+	logger       *zap.Logger
+	reservations *settlement.Reservations
+}
+
+// NewReservationVerifier creates a new ReservationVerifier
+func NewReservationVerifier(logger *zap.Logger, reservations *settlement.Reservations) *ReservationVerifier {
+	return &ReservationVerifier{
+		logger:       logger,
+		reservations: reservations,
+	}
+}
+
+// Verify holds the authorization for the request's resource, failing while another resource holds it or once it
+// was settled
+func (r *ReservationVerifier) Verify(ctx context.Context, request *models.VerifyRequest) verifier.VerificationResult {
+	authorization := request.PaymentPayload.Payload.Authorization
+	authorizationKey := settlement.AuthorizationKey(
+		request.PaymentRequirements.Network,
+		request.PaymentRequirements.Asset,
+		authorization.From,
+		authorization.Nonce,
+	)
+
+	err := r.reservations.Reserve(authorizationKey, settlement.ReservationOwner(request.PaymentRequirements))
+	if stderrors.Is(err, settlement.ErrAuthorizationConsumed) {
+		return verifier.Fail(errors.ErrorAuthorizationUsed, "Authorization was already settled")
+	}
+	if err != nil {
+		return verifier.Fail(
+			errors.ErrorAuthorizationReserved,
+			"Authorization is reserved by the verification of another resource",
+		)
+	}
+
+	return verifier.OK()
+}
+
+// Type returns the verification step type
+func (r *ReservationVerifier) Type() verifier.VerificationStep {
+	return verifier.StepReservationForExactScheme
+}
+
+// Order returns the order in which this verifier should be executed
+func (r *ReservationVerifier) Order() int {
+	return 9
+}
@@ -20,14 +20,18 @@ import (
 // SignatureVerifier verifies the signature for exact scheme
 type SignatureVerifier struct {
 	logger     *zap.Logger
-	web3Client *web3.Client
+	web3Client web3.Chain
+	assets     *web3.AssetCache
 }
 
 // NewSignatureVerifier creates a new SignatureVerifier
-func NewSignatureVerifier(logger *zap.Logger, web3Client *web3.Client) *SignatureVerifier {
+// The signature is always checked against the payment requirements' extra name and version, the asset's cached
+// EIP-712 domain only explains a mismatch
+func NewSignatureVerifier(logger *zap.Logger, web3Client web3.Chain, assets *web3.AssetCache) *SignatureVerifier {
 	return &SignatureVerifier{
 		logger:     logger,
 		web3Client: web3Client,
+		assets:     assets,
 	}
 }
 
@@ -53,14 +57,42 @@ func (s *SignatureVerifier) Verify(ctx context.Context, request *models.VerifyRe
 	if !isValid {
 		return verifier.Fail(
 			errors.ErrorInvalidExactEVMPayloadSignature,
-			fmt.Sprintf("Signature mismatch: expected %s, got %s",
+			fmt.Sprintf("Signature mismatch: expected %s, got %s%s",
 				exactPayload.Authorization.From,
-				signerAddress.Hex()),
+				signerAddress.Hex(),
+				s.domainMismatch(ctx, request)),
 		)
 	}
 	return verifier.OK()
 }
 
+// domainMismatch explains a signature mismatch caused by payment requirements whose extra name or version differ from
+// the asset's EIP-712 domain, it is empty when they agree or the domain cannot be read
+// A cached domain that disagrees is read again, the asset may have been upgraded since it was cached
+func (s *SignatureVerifier) domainMismatch(ctx context.Context, request *models.VerifyRequest) string {
+	network := request.PaymentRequirements.Network
+	asset := common.HexToAddress(request.PaymentRequirements.Asset)
+	extra := web3.AssetDomain{
+		Name:    request.PaymentRequirements.Extra.Name,
+		Version: request.PaymentRequirements.Extra.Version,
+	}
+
+	domain, err := s.assets.Domain(ctx, network, asset)
+	if err == nil && domain != extra {
+		s.assets.Invalidate(network, asset)
+		domain, err = s.assets.Domain(ctx, network, asset)
+	}
+	if err != nil {
+		s.logger.Debug("Failed to read asset EIP-712 domain", zap.Error(err), zap.String("asset", asset.Hex()))
+		return ""
+	}
+	if domain == extra {
+		return ""
+	}
+	return fmt.Sprintf(", payment requirements extra name %q and version %q differ from the asset's EIP-712 domain name %q and version %q",
+		extra.Name, extra.Version, domain.Name, domain.Version)
+}
+
 func (s *SignatureVerifier) computeTransferWithAuthorizationHash(req *models.VerifyRequest, chainId *big.Int) []byte {
 	params := eip3009.TransferWithAuthorizationParams{
 		ChainId:           chainId,
@@ -6,11 +6,12 @@ import (
 	"math/big"
 	"strings"
 	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/settlement"
 	"x402-facilitator-go/internal/verifier"
 	"x402-facilitator-go/internal/web3"
+	"x402-facilitator-go/internal/web3/contract"
 	"x402-facilitator-go/pkg/errors"
 
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"go.uber.org/zap"
@@ -19,23 +20,31 @@ import (
 // UserBalanceVerifier verifies that the user has sufficient balance
 type UserBalanceVerifier struct {
 	logger     *zap.Logger
-	web3Client *web3.Client
+	web3Client web3.Chain
+	assets     *web3.AssetCache
+	exposure   *settlement.Exposure
 }
 
 // NewUserBalanceVerifier creates a new UserBalanceVerifier
-func NewUserBalanceVerifier(logger *zap.Logger, web3Client *web3.Client) *UserBalanceVerifier {
+// Balances are checked net of the payer's pending payments tracked by exposure, the asset's cached decimals
+// express the amounts of a rejection in tokens
+func NewUserBalanceVerifier(logger *zap.Logger, web3Client web3.Chain, assets *web3.AssetCache, exposure *settlement.Exposure) *UserBalanceVerifier {
 	return &UserBalanceVerifier{
 		logger:     logger,
 		web3Client: web3Client,
+		assets:     assets,
+		exposure:   exposure,
 	}
 }
 
-// Verify verifies that the user has sufficient balance
+// Verify verifies that the user has sufficient balance for this payment on top of their pending payments
+// A passing payment is held against the balance in turn, this verifier runs after the payment checks so that only
+// valid payments are held
 func (u *UserBalanceVerifier) Verify(ctx context.Context, request *models.VerifyRequest) verifier.VerificationResult {
 	ethCli, err := u.web3Client.GetClient(request.PaymentRequirements.Network)
 	if err != nil {
 		return verifier.Fail(
-			errors.ErrorInvalidNetwork,
+			errors.ErrorNetworkUnavailable,
 			fmt.Sprintf("Failed to get client for network: %v", err),
 		)
 	}
@@ -46,20 +55,17 @@ func (u *UserBalanceVerifier) Verify(ctx context.Context, request *models.Verify
 	// Get user balance using ERC20 balanceOf method
 	balance, err := u.getBalance(ctx, ethCli, contractAddr, userAddr)
 	if err != nil {
-		return verifier.Fail(
-			errors.ErrorUnknown,
-			fmt.Sprintf("Failed to get user balance: %v", err),
-		)
+		return verifier.FailChainRead(err, fmt.Sprintf("Failed to get user balance: %v", err))
 	}
 
-	// Parse the required value
-	requiredValue, _ := new(big.Int).SetString(request.PaymentPayload.Payload.Authorization.Value, 10)
-
-	// Check if balance is sufficient
-	if balance.Cmp(requiredValue) < 0 {
+	// Check if balance is sufficient once the payer's verified and in-flight payments are settled
+	hold := settlement.NewHold(request.PaymentRequirements, request.PaymentPayload.Payload.Authorization)
+	if pending, ok := u.exposure.Reserve(hold, balance); !ok {
+		amount := u.amountFormatter(ctx, request.PaymentRequirements.Network, contractAddr)
 		return verifier.Fail(
 			errors.ErrorInsufficientFunds,
-			fmt.Sprintf("Insufficient balance: user has %s, required %s", balance.String(), requiredValue.String()),
+			fmt.Sprintf("Insufficient balance: user has %s, required %s, pending payments hold %s",
+				amount(balance), amount(hold.Value), amount(pending)),
 		)
 	}
 
@@ -68,31 +74,52 @@ func (u *UserBalanceVerifier) Verify(ctx context.Context, request *models.Verify
 
 // getBalance retrieves the ERC20 token balance for an address
 func (u *UserBalanceVerifier) getBalance(ctx context.Context, client bind.ContractCaller, contractAddr, userAddr common.Address) (*big.Int, error) {
-	// ERC20 balanceOf function signature: balanceOf(address) returns (uint256)
-	balanceOfABI := `[{"constant":true,"inputs":[{"name":"_owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"balance","type":"uint256"}],"type":"function"}]`
-
-	parsedABI, err := abi.JSON(strings.NewReader(balanceOfABI))
+	token, err := contract.NewERC20Caller(contractAddr, web3.PrefetchedCaller(ctx, client))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse ABI: %w", err)
+		return nil, fmt.Errorf("failed to bind ERC20: %w", err)
 	}
 
-	// Create a bound contract
-	boundContract := bind.NewBoundContract(contractAddr, parsedABI, client, nil, nil)
-
-	// Call the contract
-	var result []interface{}
-	callOpts := &bind.CallOpts{Context: ctx}
-	err = boundContract.Call(callOpts, &result, "balanceOf", userAddr)
+	balance, err := token.BalanceOf(&bind.CallOpts{Context: ctx}, userAddr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call balanceOf: %w", err)
 	}
+	return balance, nil
+}
 
-	// Unpack the result
-	balance, ok := result[0].(*big.Int)
-	if !ok {
-		return nil, fmt.Errorf("failed to unpack balance result")
+// amountFormatter returns a formatter of the asset's amounts in base units followed by tokens, or in base units
+// only when its decimals cannot be read
+func (u *UserBalanceVerifier) amountFormatter(ctx context.Context, network string, asset common.Address) func(*big.Int) string {
+	decimals, err := u.assets.Decimals(ctx, network, asset)
+	if err != nil {
+		u.logger.Debug("Failed to read asset decimals", zap.Error(err), zap.String("asset", asset.Hex()))
+		return (*big.Int).String
 	}
-	return balance, nil
+	return func(value *big.Int) string {
+		return fmt.Sprintf("%s (%s tokens)", value.String(), formatUnits(value, decimals))
+	}
+}
+
+// formatUnits formats an amount in base units as a decimal number of tokens with the given decimals
+func formatUnits(value *big.Int, decimals uint8) string {
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	whole, fraction := new(big.Int).QuoRem(value, unit, new(big.Int))
+	if fraction.Sign() == 0 {
+		return whole.String()
+	}
+	digits := fmt.Sprintf("%0*s", int(decimals), fraction.String())
+	return whole.String() + "." + strings.TrimRight(digits, "0")
+}
+
+// Reads returns the payer's balanceOf call
+func (u *UserBalanceVerifier) Reads(request *models.VerifyRequest) []web3.Call {
+	balanceOf, err := web3.BalanceOfCall(
+		common.HexToAddress(request.PaymentRequirements.Asset),
+		common.HexToAddress(request.PaymentPayload.Payload.Authorization.From),
+	)
+	if err != nil {
+		return nil
+	}
+	return []web3.Call{balanceOf}
 }
 
 // Type returns the verification step type
@@ -102,5 +129,5 @@ func (u *UserBalanceVerifier) Type() verifier.VerificationStep {
 
 // Order returns the order in which this verifier should be executed
 func (u *UserBalanceVerifier) Order() int {
-	return 5
+	return 8
 }
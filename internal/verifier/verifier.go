@@ -2,7 +2,9 @@ package verifier
 
 import (
 	"context"
+	stderrors "errors"
 	"x402-facilitator-go/internal/models"
+	"x402-facilitator-go/internal/web3"
 	"x402-facilitator-go/pkg/errors"
 )
 
@@ -16,6 +18,13 @@ type Verifier interface {
 	Order() int
 }
 
+// ChainReader is implemented by verifiers that read chain state through eth_call
+// The verify service gathers the reads of every verifier and prefetches them in one batch before the first one runs
+type ChainReader interface {
+	// Reads returns the calls the verifier will make for a payment request
+	Reads(request *models.VerifyRequest) []web3.Call
+}
+
 // VerificationResult represents the result of a verification process
 type VerificationResult struct {
 	IsValid           bool
@@ -41,6 +50,20 @@ func Fail(errorCode errors.X402Error, errorMessage string) VerificationResult {
 	}
 }
 
+// FailChainRead creates a failed verification result for an error reading chain state
+// Unavailable networks and failed RPC calls get their own error codes, so that an infrastructure failure is not
+// reported as an invalid payment
+func FailChainRead(err error, errorMessage string) VerificationResult {
+	switch {
+	case stderrors.Is(err, web3.ErrNetworkUnavailable):
+		return Fail(errors.ErrorNetworkUnavailable, errorMessage)
+	case stderrors.Is(err, web3.ErrRPCFailure):
+		return Fail(errors.ErrorRPCFailure, errorMessage)
+	default:
+		return Fail(errors.ErrorUnknown, errorMessage)
+	}
+}
+
 // VerificationStep represents a step in the verification process
 type VerificationStep string
 
@@ -57,10 +80,18 @@ const (
 	StepPaymentAddressForExactScheme VerificationStep = "PAYMENT_ADDRESS_FOR_EXACT_SCHEME"
 	// StepDeadlinesForExactScheme checks deadlines for exact scheme
 	StepDeadlinesForExactScheme VerificationStep = "DEADLINES_FOR_EXACT_SCHEME"
+	// StepAuthorizationStateForExactScheme checks the authorization nonce is unused on-chain for exact scheme
+	StepAuthorizationStateForExactScheme VerificationStep = "AUTHORIZATION_STATE_FOR_EXACT_SCHEME"
+	// StepComplianceForExactScheme screens the payer and payTo for exact scheme
+	StepComplianceForExactScheme VerificationStep = "COMPLIANCE_FOR_EXACT_SCHEME"
+	// StepAssetRestrictionsForExactScheme checks the asset's blacklist and paused state for exact scheme
+	StepAssetRestrictionsForExactScheme VerificationStep = "ASSET_RESTRICTIONS_FOR_EXACT_SCHEME"
 	// StepUserBalanceForExactScheme checks user balance for exact scheme
 	StepUserBalanceForExactScheme VerificationStep = "USER_BALANCE_FOR_EXACT_SCHEME"
 	// StepPaymentValueForExactScheme verifies payment value for exact scheme
 	StepPaymentValueForExactScheme VerificationStep = "PAYMENT_VALUE_FOR_EXACT_SCHEME"
+	// StepReservationForExactScheme holds the authorization for the verifying resource for exact scheme
+	StepReservationForExactScheme VerificationStep = "RESERVATION_FOR_EXACT_SCHEME"
 )
 
 // String returns the string representation of the verification step
@@ -0,0 +1,251 @@
+package web3
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+	"x402-facilitator-go/internal/cache"
+	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/web3/contract"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// assetFacts are the kinds of facts cached per asset
+var assetFacts = []string{"code", "eip3009", "domain", "decimals"}
+
+// AssetDomain is the EIP-712 domain name and version an asset reports on-chain
+type AssetDomain struct {
+	Name    string
+	Version string
+}
+
+// AssetCache caches immutable or slow-changing facts about asset contracts: bytecode presence,
+// EIP-3009 support, EIP-712 domain and decimals
+// Facts are cached for the configured TTL, a missing contract or an unsupported asset for the shorter
+// negative TTL since it may be deployed or upgraded later. Invalidate drops an asset's facts before they expire
+type AssetCache struct {
+	chain       Chain
+	cache       *cache.Cache
+	ttl         time.Duration
+	negativeTTL time.Duration
+}
+
+// NewAssetCache creates an AssetCache over the networks of chain
+func NewAssetCache(chain Chain, cfg config.CacheConfig) *AssetCache {
+	return &AssetCache{
+		chain:       chain,
+		cache:       cache.New("assets", cfg.MaxEntries, time.Duration(cfg.LoadTimeoutSeconds)*time.Second),
+		ttl:         time.Duration(cfg.TTLSeconds) * time.Second,
+		negativeTTL: time.Duration(cfg.NegativeTTLSeconds) * time.Second,
+	}
+}
+
+// HasCode reports whether the asset address holds contract bytecode
+func (a *AssetCache) HasCode(ctx context.Context, network string, asset common.Address) (bool, error) {
+	value, err := a.cache.Get(ctx, assetKey("code", network, asset), func(ctx context.Context) (interface{}, time.Duration, error) {
+		client, err := a.chain.GetClient(network)
+		if err != nil {
+			return nil, 0, err
+		}
+		code, err := client.CodeAt(ctx, asset, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		return len(code) > 0, a.factTTL(len(code) > 0), nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return value.(bool), nil
+}
+
+// SupportsEIP3009 reports whether the asset answers the EIP-3009 authorizationState probe
+func (a *AssetCache) SupportsEIP3009(ctx context.Context, network string, asset common.Address) (bool, error) {
+	value, err := a.cache.Get(ctx, assetKey("eip3009", network, asset), func(ctx context.Context) (interface{}, time.Duration, error) {
+		client, err := a.chain.GetClient(network)
+		if err != nil {
+			return nil, 0, err
+		}
+		supported, err := probeAuthorizationState(ctx, client, asset)
+		if err != nil {
+			return nil, 0, err
+		}
+		return supported, a.factTTL(supported), nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return value.(bool), nil
+}
+
+// Domain returns the asset's EIP-712 domain name and version, the version is empty when the asset has no version()
+func (a *AssetCache) Domain(ctx context.Context, network string, asset common.Address) (AssetDomain, error) {
+	value, err := a.cache.Get(ctx, assetKey("domain", network, asset), func(ctx context.Context) (interface{}, time.Duration, error) {
+		token, err := a.erc20(ctx, network, asset)
+		if err != nil {
+			return nil, 0, err
+		}
+		callOpts := &bind.CallOpts{Context: ctx}
+		name, err := token.Name(callOpts)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to call name: %w", err)
+		}
+		version, err := token.Version(callOpts)
+		if err != nil && !isRevert(err) {
+			return nil, 0, fmt.Errorf("failed to call version: %w", err)
+		}
+		return AssetDomain{Name: name, Version: version}, a.ttl, nil
+	})
+	if err != nil {
+		return AssetDomain{}, err
+	}
+	return value.(AssetDomain), nil
+}
+
+// Decimals returns the asset's ERC-20 decimals
+func (a *AssetCache) Decimals(ctx context.Context, network string, asset common.Address) (uint8, error) {
+	value, err := a.cache.Get(ctx, assetKey("decimals", network, asset), func(ctx context.Context) (interface{}, time.Duration, error) {
+		token, err := a.erc20(ctx, network, asset)
+		if err != nil {
+			return nil, 0, err
+		}
+		decimals, err := token.Decimals(&bind.CallOpts{Context: ctx})
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to call decimals: %w", err)
+		}
+		return decimals, a.ttl, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return value.(uint8), nil
+}
+
+// Invalidate drops every cached fact of an asset, such as after it was found to disagree with them
+func (a *AssetCache) Invalidate(network string, asset common.Address) {
+	for _, kind := range assetFacts {
+		a.cache.Invalidate(assetKey(kind, network, asset))
+	}
+}
+
+// erc20 binds the ERC-20 metadata calls of an asset, answering them from prefetched results when available
+func (a *AssetCache) erc20(ctx context.Context, network string, asset common.Address) (*contract.ERC20Caller, error) {
+	client, err := a.chain.GetClient(network)
+	if err != nil {
+		return nil, err
+	}
+	return contract.NewERC20Caller(asset, PrefetchedCaller(ctx, client))
+}
+
+// factTTL returns how long a positive or negative fact is cached
+func (a *AssetCache) factTTL(positive bool) time.Duration {
+	if positive {
+		return a.ttl
+	}
+	return a.negativeTTL
+}
+
+// assetKey identifies a cached fact about an asset
+func assetKey(kind, network string, asset common.Address) string {
+	return kind + ":" + network + ":" + asset.Hex()
+}
+
+// EIP3009ProbeCall returns the authorizationState(0x0, 0x0) call probing an asset for EIP-3009 support
+func EIP3009ProbeCall(asset common.Address) (Call, error) {
+	return packCall(contract.EIP3009TokenMetaData, asset, "authorizationState", common.Address{}, [32]byte{})
+}
+
+// AuthorizationStateCall returns the authorizationState(authorizer, nonce) call of an asset
+func AuthorizationStateCall(asset, authorizer common.Address, nonce common.Hash) (Call, error) {
+	return packCall(contract.EIP3009TokenMetaData, asset, "authorizationState", authorizer, [32]byte(nonce))
+}
+
+// BalanceOfCall returns the balanceOf(owner) call of an asset
+func BalanceOfCall(asset, owner common.Address) (Call, error) {
+	return packCall(contract.ERC20MetaData, asset, "balanceOf", owner)
+}
+
+// RestrictionCalls returns the paused() and isBlacklisted(account) calls reading an asset's USDC-style transfer
+// restrictions for accounts
+func RestrictionCalls(asset common.Address, accounts ...common.Address) ([]Call, error) {
+	paused, err := packCall(contract.RestrictedTokenMetaData, asset, "paused")
+	if err != nil {
+		return nil, err
+	}
+	calls := []Call{paused}
+	for _, account := range accounts {
+		blacklisted, err := packCall(contract.RestrictedTokenMetaData, asset, "isBlacklisted", account)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, blacklisted)
+	}
+	return calls, nil
+}
+
+// ReadRestriction executes a restriction call returned by RestrictionCalls, reporting exposed false when the asset
+// does not expose the function because the call reverts or returns a malformed result, and an error only when the
+// call itself failed
+func ReadRestriction(ctx context.Context, caller bind.ContractCaller, call Call) (restricted, exposed bool, err error) {
+	result, err := caller.CallContract(ctx, ethereum.CallMsg{To: &call.Target, Data: call.Data}, nil)
+	if err != nil {
+		if isRevert(err) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+	if len(result) != 32 {
+		return false, false, nil
+	}
+	switch common.BytesToHash(result) {
+	case common.Hash{}:
+		return false, true, nil
+	case common.BigToHash(common.Big1):
+		return true, true, nil
+	default:
+		return false, false, nil
+	}
+}
+
+// packCall packs a call of a bound contract method
+func packCall(metaData *bind.MetaData, target common.Address, method string, args ...interface{}) (Call, error) {
+	contractABI, err := metaData.GetAbi()
+	if err != nil {
+		return Call{}, err
+	}
+	data, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return Call{}, fmt.Errorf("failed to pack %s: %w", method, err)
+	}
+	return Call{Target: target, Data: data}, nil
+}
+
+// probeAuthorizationState calls authorizationState(0x0, 0x0), reporting false when the asset reverts or
+// returns a malformed result and an error only when the call itself failed
+func probeAuthorizationState(ctx context.Context, client Backend, asset common.Address) (bool, error) {
+	probe, err := EIP3009ProbeCall(asset)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := PrefetchedCaller(ctx, client).CallContract(ctx, ethereum.CallMsg{To: &asset, Data: probe.Data}, nil)
+	if err != nil {
+		if isRevert(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return len(result) == 32, nil
+}
+
+// isRevert reports whether a call error is an execution revert rather than a transport or node failure
+func isRevert(err error) bool {
+	var dataErr rpc.DataError
+	return stderrors.As(err, &dataErr) || strings.Contains(err.Error(), "execution reverted")
+}
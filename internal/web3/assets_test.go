@@ -0,0 +1,103 @@
+package web3_test
+
+import (
+	"context"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/devchain"
+	"x402-facilitator-go/internal/web3"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/jinzhu/configor"
+)
+
+// testNetwork is the dev network the asset cache tests read from
+const testNetwork = "local"
+
+// countingChain is a simulated dev chain counting the contract calls made through its clients
+type countingChain struct {
+	*web3.Simulated
+	calls atomic.Int64
+}
+
+// GetClient returns the network's backend counting its contract calls
+func (c *countingChain) GetClient(networkName string) (web3.Backend, error) {
+	backend, err := c.Simulated.GetClient(networkName)
+	if err != nil {
+		return nil, err
+	}
+	return &countingBackend{Backend: backend, calls: &c.calls}, nil
+}
+
+// countingBackend is a backend counting its contract calls
+type countingBackend struct {
+	web3.Backend
+	calls *atomic.Int64
+}
+
+// CallContract counts the call and executes it
+func (b *countingBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	b.calls.Add(1)
+	return b.Backend.CallContract(ctx, call, blockNumber)
+}
+
+// newAssetCache creates an AssetCache with the configuration defaults over a fresh simulated dev chain
+func newAssetCache(t *testing.T) (*web3.AssetCache, *countingChain) {
+	t.Helper()
+
+	cfg := &config.Config{}
+	if err := configor.Load(cfg); err != nil {
+		t.Fatalf("load config defaults: %v", err)
+	}
+	backend, err := devchain.NewBackend(devchain.Facilitator().Address)
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	chain := &countingChain{Simulated: web3.NewSimulated(web3.SimulatedNetwork{Name: testNetwork, Backend: backend})}
+	t.Cleanup(func() { chain.Close() })
+	return web3.NewAssetCache(chain, cfg.Cache), chain
+}
+
+func TestAssetCacheCachesDomainAndDecimals(t *testing.T) {
+	assets, chain := newAssetCache(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		domain, err := assets.Domain(ctx, testNetwork, devchain.TokenAddress)
+		if err != nil {
+			t.Fatalf("domain: %v", err)
+		}
+		if domain != (web3.AssetDomain{Name: devchain.TokenName, Version: devchain.TokenVersion}) {
+			t.Fatalf("domain %+v, want %s version %s", domain, devchain.TokenName, devchain.TokenVersion)
+		}
+		decimals, err := assets.Decimals(ctx, testNetwork, devchain.TokenAddress)
+		if err != nil {
+			t.Fatalf("decimals: %v", err)
+		}
+		if decimals != devchain.TokenDecimals {
+			t.Fatalf("decimals %d, want %d", decimals, devchain.TokenDecimals)
+		}
+	}
+	// name(), version() and decimals() once
+	if calls := chain.calls.Load(); calls != 3 {
+		t.Fatalf("%d contract calls, want 3", calls)
+	}
+}
+
+func TestAssetCacheInvalidateReloadsFacts(t *testing.T) {
+	assets, chain := newAssetCache(t)
+	ctx := context.Background()
+
+	if _, err := assets.Domain(ctx, testNetwork, devchain.TokenAddress); err != nil {
+		t.Fatalf("domain: %v", err)
+	}
+	assets.Invalidate(testNetwork, devchain.TokenAddress)
+	if _, err := assets.Domain(ctx, testNetwork, devchain.TokenAddress); err != nil {
+		t.Fatalf("domain after invalidate: %v", err)
+	}
+	if calls := chain.calls.Load(); calls != 4 {
+		t.Fatalf("%d contract calls, want the domain read twice", calls)
+	}
+}
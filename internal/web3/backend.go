@@ -0,0 +1,59 @@
+package web3
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Backend is the chain access of one network
+// It covers contract calls and transactions, code and balances, receipts, the head block and the chain ID
+type Backend interface {
+	bind.ContractBackend
+	ethereum.ChainStateReader
+	ethereum.TransactionReader
+	// BlockNumber returns the number of the most recent block
+	BlockNumber(ctx context.Context) (uint64, error)
+	// ChainID returns the chain ID reported by the node
+	ChainID(ctx context.Context) (*big.Int, error)
+	// SubscribeNewHead subscribes to new chain heads, it fails with rpc.ErrNotificationsUnsupported
+	// when the network has no WebSocket endpoint
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+	// TransactionReceipts returns the receipts of several transactions in one batch request,
+	// with a nil receipt for every transaction that is not mined
+	TransactionReceipts(ctx context.Context, hashes []common.Hash) ([]*types.Receipt, error)
+}
+
+// Chain provides the Backend and settings of every configured network
+// Client implements it over RPC endpoints and Simulated over in-process simulated chains
+type Chain interface {
+	// GetClient returns the Backend of a network, or an error wrapping ErrNetworkUnavailable
+	GetClient(networkName string) (Backend, error)
+	// GetChainID returns the configured chain ID of a network
+	GetChainID(networkName string) (*big.Int, error)
+	// GetConfirmations returns the number of confirmations required for settlements on a network
+	GetConfirmations(networkName string) (uint64, error)
+	// GetMinGasBalance returns the native balance, in wei, the settlement signer needs on a network
+	GetMinGasBalance(networkName string) (*big.Int, error)
+	// Available reports whether a network is connected and answering
+	Available(networkName string) bool
+	// Networks returns the names of the configured networks in sorted order
+	Networks() []string
+}
+
+// Manager is a Chain that also maintains its networks and reports their health
+type Manager interface {
+	Chain
+	// Run maintains the networks until ctx is cancelled
+	Run(ctx context.Context)
+	// NetworkStatuses returns the state of every network
+	NetworkStatuses() []NetworkStatus
+	// EndpointStatuses returns the observed health of every RPC endpoint
+	EndpointStatuses() []EndpointStatus
+	// Close releases the networks
+	Close() error
+}
@@ -3,7 +3,11 @@ package web3
 import (
 	"fmt"
 	"math/big"
+	"net/http"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 	"x402-facilitator-go/internal/config"
 
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -11,43 +15,131 @@ import (
 )
 
 // Client manages Web3 clients for different networks
+// Networks connect lazily in the background, a network that cannot be reached is unavailable
+// until a reconnect succeeds while the other networks keep serving
 type Client struct {
-	ClientInfo map[string]ClientInfo
-	logger     *zap.Logger
-	mu         sync.RWMutex
+	ClientInfo  map[string]*ClientInfo
+	logger      *zap.Logger
+	mu          sync.RWMutex
+	healthEvery time.Duration
 }
 
 type ClientInfo struct {
-	client  *ethclient.Client
-	rpcURL  string
-	chainID *big.Int
+	client        *ethclient.Client
+	rpcURL        string
+	chainID       *big.Int
+	confirmations uint64
+	minGasBalance *big.Int
+	// transport fails over between the network's HTTP endpoints, nil for a single WebSocket endpoint
+	transport *failoverTransport
+	// wsURL is the WebSocket endpoint new heads are subscribed on, headClient its connection once dialed
+	wsURL      string
+	headClient *ethclient.Client
+	// backend applies the network's RPC policy to the client's calls
+	backend Backend
+	policy  config.RPCPolicy
+	breaker *circuitBreaker
+	// available is set once the network answered and cleared when it stops answering
+	available bool
+	attempted bool
+	lastError string
+	// changedAt is when the network last became available or unavailable
+	changedAt time.Time
 }
 
 // NewClient creates a new Web3 client manager
-func NewClient(networkInfo []config.NetworkInfo, logger *zap.Logger) (*Client, error) {
-	clientMap := make(map[string]ClientInfo)
+// No connection is made here, Run connects every network and keeps reconnecting unavailable ones
+func NewClient(networkInfo []config.NetworkInfo, rpcConfig config.RPCConfig, logger *zap.Logger) (*Client, error) {
+	clientMap := make(map[string]*ClientInfo)
+	cooldown := time.Duration(rpcConfig.UnhealthyCooldownSeconds) * time.Second
 
 	for _, netInfo := range networkInfo {
-		ethClient, err := ethclient.Dial(netInfo.RPCURL)
-		if err != nil {
-			return nil, fmt.Errorf("failed to connect to %s at %s: %w", netInfo.Name, netInfo.RPCURL, err)
+		readEndpoints := netInfo.ReadEndpoints()
+		if len(readEndpoints) == 0 {
+			return nil, fmt.Errorf("no RPC endpoint configured for %s", netInfo.Name)
+		}
+
+		var transport *failoverTransport
+		if isHTTPEndpoint(readEndpoints[0]) {
+			var err error
+			transport, err = newFailoverTransport(netInfo, cooldown, logger)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		clientMap[netInfo.Name] = ClientInfo{
-			client:  ethClient,
-			rpcURL:  netInfo.RPCURL,
-			chainID: big.NewInt(netInfo.ChainID),
+		minGasBalance, ok := new(big.Int).SetString(netInfo.MinGasBalance, 10)
+		if !ok {
+			minGasBalance = new(big.Int)
+		}
+
+		policy := rpcConfig.PolicyFor(netInfo)
+		clientMap[netInfo.Name] = &ClientInfo{
+			rpcURL:        readEndpoints[0],
+			chainID:       big.NewInt(netInfo.ChainID),
+			confirmations: netInfo.Confirmations,
+			minGasBalance: minGasBalance,
+			transport:     transport,
+			wsURL:         netInfo.WSURL,
+			policy:        policy,
+			breaker:       newCircuitBreaker(netInfo.Name, policy, logger),
+			lastError:     "not connected yet",
+			changedAt:     time.Now().UTC(),
 		}
 	}
 
 	return &Client{
-		ClientInfo: clientMap,
-		logger:     logger,
+		ClientInfo:  clientMap,
+		logger:      logger,
+		healthEvery: time.Duration(rpcConfig.HealthCheckIntervalSeconds) * time.Second,
+	}, nil
+}
+
+// newFailoverTransport creates the failover transport over a network's read and broadcast endpoints
+func newFailoverTransport(netInfo config.NetworkInfo, cooldown time.Duration, logger *zap.Logger) (*failoverTransport, error) {
+	read, err := newEndpointPool(netInfo.ReadEndpoints(), cooldown)
+	if err != nil {
+		return nil, fmt.Errorf("network %s: %w", netInfo.Name, err)
+	}
+	broadcast := read
+	if len(netInfo.BroadcastRPCURLs) > 0 {
+		broadcast, err = newEndpointPool(netInfo.BroadcastRPCURLs, cooldown)
+		if err != nil {
+			return nil, fmt.Errorf("network %s: %w", netInfo.Name, err)
+		}
+	}
+
+	return &failoverTransport{
+		network:   netInfo.Name,
+		chainID:   big.NewInt(netInfo.ChainID),
+		read:      read,
+		broadcast: broadcast,
+		base:      http.DefaultTransport,
+		logger:    logger,
 	}, nil
 }
 
+// isHTTPEndpoint reports whether an RPC URL is an HTTP(S) endpoint
+func isHTTPEndpoint(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "http://") || strings.HasPrefix(rawURL, "https://")
+}
+
+// EndpointStatuses returns the observed health of every network's RPC endpoints
+func (c *Client) EndpointStatuses() []EndpointStatus {
+	statuses := make([]EndpointStatus, 0)
+	for _, network := range c.Networks() {
+		c.mu.RLock()
+		transport := c.ClientInfo[network].transport
+		c.mu.RUnlock()
+		if transport != nil {
+			statuses = append(statuses, transport.statuses()...)
+		}
+	}
+	return statuses
+}
+
 // GetClient returns the Ethereum client for the specified network
-func (c *Client) GetClient(networkName string) (*ethclient.Client, error) {
+func (c *Client) GetClient(networkName string) (Backend, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -55,7 +147,10 @@ func (c *Client) GetClient(networkName string) (*ethclient.Client, error) {
 	if !ok {
 		return nil, fmt.Errorf("network %s not configured", networkName)
 	}
-	return clientInfo.client, nil
+	if !clientInfo.available {
+		return nil, fmt.Errorf("%w: %s: %s", ErrNetworkUnavailable, networkName, clientInfo.lastError)
+	}
+	return clientInfo.backend, nil
 }
 
 // Close closes all Web3 clients
@@ -64,6 +159,12 @@ func (c *Client) Close() error {
 	defer c.mu.Unlock()
 
 	for network, clientInfo := range c.ClientInfo {
+		if clientInfo.headClient != nil {
+			clientInfo.headClient.Close()
+		}
+		if clientInfo.client == nil {
+			continue
+		}
 		clientInfo.client.Close()
 		c.logger.Info("Closed connection to network", zap.String("network", network))
 	}
@@ -89,3 +190,42 @@ func (c *Client) GetChainID(networkName string) (*big.Int, error) {
 
 	return clientInfo.chainID, nil
 }
+
+// GetConfirmations returns the number of confirmations required for settlements on the specified network
+func (c *Client) GetConfirmations(networkName string) (uint64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	clientInfo, ok := c.ClientInfo[networkName]
+	if !ok {
+		return 0, fmt.Errorf("network %s not configured", networkName)
+	}
+
+	return clientInfo.confirmations, nil
+}
+
+// GetMinGasBalance returns the native balance, in wei, the settlement signer needs on the specified network
+func (c *Client) GetMinGasBalance(networkName string) (*big.Int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	clientInfo, ok := c.ClientInfo[networkName]
+	if !ok {
+		return nil, fmt.Errorf("network %s not configured", networkName)
+	}
+
+	return new(big.Int).Set(clientInfo.minGasBalance), nil
+}
+
+// Networks returns the names of the configured networks in sorted order
+func (c *Client) Networks() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	networks := make([]string, 0, len(c.ClientInfo))
+	for network := range c.ClientInfo {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+	return networks
+}
@@ -0,0 +1,82 @@
+package web3
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"x402-facilitator-go/internal/cache"
+	"x402-facilitator-go/internal/config"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// headerLoadTimeout bounds the fetch of a network's latest block header by the block clock
+const headerLoadTimeout = 10 * time.Second
+
+// Clock tells the time authorization validity windows are checked against
+type Clock interface {
+	// Now returns the time a settlement sent now on network is expected to execute at
+	Now(ctx context.Context, network string) (time.Time, error)
+}
+
+// SystemClock is the host clock
+type SystemClock struct{}
+
+// Now returns the host's current time
+func (SystemClock) Now(ctx context.Context, network string) (time.Time, error) {
+	return time.Now(), nil
+}
+
+// BlockClock estimates the block.timestamp a settlement sent now executes at as the timestamp of the latest block
+// plus the network's expected inclusion delay, so that a host clock skew or slow block production on the network
+// does not make verification disagree with the token contract
+// The latest header of a network is reused for the header cache duration, so that bursts of verifications
+// share one header fetch
+type BlockClock struct {
+	chain    Chain
+	delays   map[string]time.Duration
+	headers  *cache.Cache
+	cacheTTL time.Duration
+}
+
+// NewBlockClock creates a BlockClock using the inclusion delay of every configured network
+func NewBlockClock(chain Chain, cfg config.VerificationConfig, networkInfos []config.NetworkInfo) *BlockClock {
+	delays := make(map[string]time.Duration, len(networkInfos))
+	for _, networkInfo := range networkInfos {
+		delays[networkInfo.Name] = cfg.InclusionDelayFor(networkInfo)
+	}
+	return &BlockClock{
+		chain:    chain,
+		delays:   delays,
+		headers:  cache.New("block_headers", len(networkInfos)+1, headerLoadTimeout),
+		cacheTTL: time.Duration(cfg.HeaderCacheMillis) * time.Millisecond,
+	}
+}
+
+// Now returns the timestamp of the network's latest block plus its inclusion delay
+func (c *BlockClock) Now(ctx context.Context, network string) (time.Time, error) {
+	value, err := c.headers.Get(ctx, network, func(ctx context.Context) (interface{}, time.Duration, error) {
+		client, err := c.chain.GetClient(network)
+		if err != nil {
+			return nil, 0, err
+		}
+		header, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to fetch latest block: %w", err)
+		}
+		return header, c.cacheTTL, nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	header := value.(*types.Header)
+	return time.Unix(int64(header.Time), 0).Add(c.delays[network]), nil
+}
+
+// NewClock creates the clock selected by the verification config
+func NewClock(chain Chain, cfg config.VerificationConfig, networkInfos []config.NetworkInfo) Clock {
+	if cfg.Clock == config.ClockBlock {
+		return NewBlockClock(chain, cfg, networkInfos)
+	}
+	return SystemClock{}
+}
@@ -0,0 +1,233 @@
+package web3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.uber.org/zap"
+)
+
+// ErrNetworkUnavailable is returned for a configured network that is not connected or stopped answering
+var ErrNetworkUnavailable = errors.New("network unavailable")
+
+// ErrChainIDMismatch is returned when a network's RPC reports a different chain ID than configured
+var ErrChainIDMismatch = errors.New("chain ID mismatch")
+
+// NetworkStatus is the connection state of a network
+type NetworkStatus struct {
+	Network   string    `json:"network"`
+	Available bool      `json:"available"`
+	LastError string    `json:"lastError,omitempty"`
+	ChangedAt time.Time `json:"changedAt"`
+	// CircuitOpen is set while the network's circuit breaker rejects calls after repeated RPC failures
+	CircuitOpen bool `json:"circuitOpen"`
+}
+
+// Run health checks endpoints and connects every network, then repeats the health checks, probes connected
+// networks and reconnects unavailable ones on every interval until ctx is cancelled
+func (c *Client) Run(ctx context.Context) {
+	c.checkEndpoints(ctx)
+	c.connectAll(ctx)
+
+	ticker := time.NewTicker(c.healthEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.checkEndpoints(ctx)
+			c.connectAll(ctx)
+		}
+	}
+}
+
+// Available reports whether the network is connected and answering
+func (c *Client) Available(networkName string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	clientInfo, ok := c.ClientInfo[networkName]
+	return ok && clientInfo.available
+}
+
+// NetworkStatuses returns the connection state of every network
+func (c *Client) NetworkStatuses() []NetworkStatus {
+	statuses := make([]NetworkStatus, 0)
+	for _, network := range c.Networks() {
+		c.mu.RLock()
+		clientInfo := c.ClientInfo[network]
+		statuses = append(statuses, NetworkStatus{
+			Network:     network,
+			Available:   clientInfo.available,
+			LastError:   clientInfo.lastError,
+			ChangedAt:   clientInfo.changedAt,
+			CircuitOpen: clientInfo.breaker.open(),
+		})
+		c.mu.RUnlock()
+	}
+	return statuses
+}
+
+// checkEndpoints health checks the endpoints of every network
+func (c *Client) checkEndpoints(ctx context.Context) {
+	c.mu.RLock()
+	transports := make([]*failoverTransport, 0, len(c.ClientInfo))
+	for _, clientInfo := range c.ClientInfo {
+		if clientInfo.transport != nil {
+			transports = append(transports, clientInfo.transport)
+		}
+	}
+	c.mu.RUnlock()
+
+	for _, transport := range transports {
+		transport.healthCheck(ctx, c.healthEvery)
+	}
+}
+
+// connectAll connects or probes every network concurrently, so that a slow network does not hold up the others
+func (c *Client) connectAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, network := range c.Networks() {
+		wg.Add(1)
+		go func(network string) {
+			defer wg.Done()
+			c.connect(ctx, network)
+		}(network)
+	}
+	wg.Wait()
+}
+
+// connect dials a network if needed and probes it, updating its availability
+// The probe compares the node's eth_chainId with the configured chain ID, so that a network whose RPC
+// points at another chain is never used for signing or settlement
+func (c *Client) connect(ctx context.Context, networkName string) {
+	c.mu.RLock()
+	clientInfo := c.ClientInfo[networkName]
+	client := clientInfo.client
+	headClient := clientInfo.headClient
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.healthEvery)
+	defer cancel()
+
+	var err error
+	if client == nil {
+		client, err = c.dial(ctx, clientInfo)
+	}
+	if err == nil {
+		err = verifyChainID(ctx, client, clientInfo.chainID)
+	}
+	if err == nil && headClient == nil && clientInfo.wsURL != "" {
+		headClient = c.dialHeads(ctx, networkName, clientInfo)
+	}
+	if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		return
+	}
+	if errors.Is(err, ErrChainIDMismatch) {
+		c.logger.Error("RPC chain ID does not match the configured chain ID, network disabled until they agree",
+			zap.Error(err),
+			zap.String("network", networkName),
+			zap.String("rpcURL", RedactURL(clientInfo.rpcURL)),
+		)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer func() { clientInfo.attempted = true }()
+
+	if err != nil {
+		if client != nil && (clientInfo.transport == nil || errors.Is(err, ErrChainIDMismatch)) {
+			// Drop a broken WebSocket connection or a client on the wrong chain so that the next attempt redials
+			client.Close()
+			client = nil
+		}
+		clientInfo.client = client
+		clientInfo.backend = nil
+		if clientInfo.available || !clientInfo.attempted {
+			c.logger.Error("Network unavailable, requests are rejected until it reconnects",
+				zap.Error(err),
+				zap.String("network", networkName),
+				zap.String("rpcURL", RedactURL(clientInfo.rpcURL)),
+			)
+			clientInfo.changedAt = time.Now().UTC()
+		}
+		clientInfo.available = false
+		clientInfo.lastError = redactError(err.Error())
+		return
+	}
+
+	if clientInfo.client != client || clientInfo.headClient != headClient || clientInfo.backend == nil {
+		clientInfo.backend = newPolicyBackend(client, headClient, networkName, clientInfo.policy, clientInfo.breaker, c.logger)
+	}
+	clientInfo.client = client
+	clientInfo.headClient = headClient
+	if !clientInfo.available {
+		c.logger.Info("Connected to network",
+			zap.String("network", networkName),
+			zap.String("rpcURL", RedactURL(clientInfo.rpcURL)),
+		)
+		clientInfo.available = true
+		clientInfo.lastError = ""
+		clientInfo.changedAt = time.Now().UTC()
+	}
+}
+
+// dialHeads connects the network's WebSocket endpoint for new-heads subscriptions
+// It returns nil when the endpoint cannot be used, leaving the network's confirmations to polling until the next
+// connection attempt
+func (c *Client) dialHeads(ctx context.Context, networkName string, clientInfo *ClientInfo) *ethclient.Client {
+	headClient, err := ethclient.DialContext(ctx, clientInfo.wsURL)
+	if err == nil {
+		err = verifyChainID(ctx, headClient, clientInfo.chainID)
+		if err != nil {
+			headClient.Close()
+		}
+	}
+	if err != nil {
+		c.logger.Warn("WebSocket endpoint unavailable, polling for new heads",
+			zap.Error(err),
+			zap.String("network", networkName),
+			zap.String("wsURL", RedactURL(clientInfo.wsURL)),
+		)
+		return nil
+	}
+	c.logger.Info("Subscribing to new heads over WebSocket",
+		zap.String("network", networkName),
+		zap.String("wsURL", RedactURL(clientInfo.wsURL)),
+	)
+	return headClient
+}
+
+// verifyChainID queries eth_chainId and compares it with the configured chain ID
+func verifyChainID(ctx context.Context, client *ethclient.Client, expected *big.Int) error {
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return err
+	}
+	if chainID.Cmp(expected) != 0 {
+		return fmt.Errorf("%w: configured %s, RPC reports %s", ErrChainIDMismatch, expected, chainID)
+	}
+	return nil
+}
+
+// dial creates the network's client, over the failover transport for HTTP endpoints
+func (c *Client) dial(ctx context.Context, clientInfo *ClientInfo) (*ethclient.Client, error) {
+	if clientInfo.transport == nil {
+		return ethclient.DialContext(ctx, clientInfo.rpcURL)
+	}
+
+	rpcClient, err := rpc.DialOptions(ctx, clientInfo.rpcURL, rpc.WithHTTPClient(&http.Client{Transport: clientInfo.transport}))
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(rpcClient), nil
+}
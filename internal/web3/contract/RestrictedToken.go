@@ -0,0 +1,243 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package contract
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+	_ = abi.ConvertType
+)
+
+// RestrictedTokenMetaData contains all meta data concerning the RestrictedToken contract.
+var RestrictedTokenMetaData = &bind.MetaData{
+	ABI: "[{\"inputs\":[{\"internalType\":\"address\",\"name\":\"account\",\"type\":\"address\"}],\"name\":\"isBlacklisted\",\"outputs\":[{\"internalType\":\"bool\",\"name\":\"\",\"type\":\"bool\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"paused\",\"outputs\":[{\"internalType\":\"bool\",\"name\":\"\",\"type\":\"bool\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]",
+}
+
+// RestrictedTokenABI is the input ABI used to generate the binding from.
+// Deprecated: Use RestrictedTokenMetaData.ABI instead.
+var RestrictedTokenABI = RestrictedTokenMetaData.ABI
+
+// RestrictedToken is an auto generated Go binding around an Ethereum contract.
+type RestrictedToken struct {
+	RestrictedTokenCaller     // Read-only binding to the contract
+	RestrictedTokenTransactor // Write-only binding to the contract
+	RestrictedTokenFilterer   // Log filterer for contract events
+}
+
+// RestrictedTokenCaller is an auto generated read-only Go binding around an Ethereum contract.
+type RestrictedTokenCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// RestrictedTokenTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type RestrictedTokenTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// RestrictedTokenFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type RestrictedTokenFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// RestrictedTokenSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type RestrictedTokenSession struct {
+	Contract     *RestrictedToken  // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// RestrictedTokenCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type RestrictedTokenCallerSession struct {
+	Contract *RestrictedTokenCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts          // Call options to use throughout this session
+}
+
+// RestrictedTokenTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type RestrictedTokenTransactorSession struct {
+	Contract     *RestrictedTokenTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts          // Transaction auth options to use throughout this session
+}
+
+// RestrictedTokenRaw is an auto generated low-level Go binding around an Ethereum contract.
+type RestrictedTokenRaw struct {
+	Contract *RestrictedToken // Generic contract binding to access the raw methods on
+}
+
+// RestrictedTokenCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type RestrictedTokenCallerRaw struct {
+	Contract *RestrictedTokenCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// RestrictedTokenTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type RestrictedTokenTransactorRaw struct {
+	Contract *RestrictedTokenTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewRestrictedToken creates a new instance of RestrictedToken, bound to a specific deployed contract.
+func NewRestrictedToken(address common.Address, backend bind.ContractBackend) (*RestrictedToken, error) {
+	contract, err := bindRestrictedToken(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &RestrictedToken{RestrictedTokenCaller: RestrictedTokenCaller{contract: contract}, RestrictedTokenTransactor: RestrictedTokenTransactor{contract: contract}, RestrictedTokenFilterer: RestrictedTokenFilterer{contract: contract}}, nil
+}
+
+// NewRestrictedTokenCaller creates a new read-only instance of RestrictedToken, bound to a specific deployed contract.
+func NewRestrictedTokenCaller(address common.Address, caller bind.ContractCaller) (*RestrictedTokenCaller, error) {
+	contract, err := bindRestrictedToken(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &RestrictedTokenCaller{contract: contract}, nil
+}
+
+// NewRestrictedTokenTransactor creates a new write-only instance of RestrictedToken, bound to a specific deployed contract.
+func NewRestrictedTokenTransactor(address common.Address, transactor bind.ContractTransactor) (*RestrictedTokenTransactor, error) {
+	contract, err := bindRestrictedToken(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &RestrictedTokenTransactor{contract: contract}, nil
+}
+
+// NewRestrictedTokenFilterer creates a new log filterer instance of RestrictedToken, bound to a specific deployed contract.
+func NewRestrictedTokenFilterer(address common.Address, filterer bind.ContractFilterer) (*RestrictedTokenFilterer, error) {
+	contract, err := bindRestrictedToken(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &RestrictedTokenFilterer{contract: contract}, nil
+}
+
+// bindRestrictedToken binds a generic wrapper to an already deployed contract.
+func bindRestrictedToken(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := RestrictedTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, *parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_RestrictedToken *RestrictedTokenRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _RestrictedToken.Contract.RestrictedTokenCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_RestrictedToken *RestrictedTokenRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _RestrictedToken.Contract.RestrictedTokenTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_RestrictedToken *RestrictedTokenRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _RestrictedToken.Contract.RestrictedTokenTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_RestrictedToken *RestrictedTokenCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _RestrictedToken.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_RestrictedToken *RestrictedTokenTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _RestrictedToken.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_RestrictedToken *RestrictedTokenTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _RestrictedToken.Contract.contract.Transact(opts, method, params...)
+}
+
+// IsBlacklisted is a free data retrieval call binding the contract method 0xfe575a87.
+//
+// Solidity: function isBlacklisted(address account) view returns(bool)
+func (_RestrictedToken *RestrictedTokenCaller) IsBlacklisted(opts *bind.CallOpts, account common.Address) (bool, error) {
+	var out []interface{}
+	err := _RestrictedToken.contract.Call(opts, &out, "isBlacklisted", account)
+
+	if err != nil {
+		return *new(bool), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(bool)).(*bool)
+
+	return out0, err
+
+}
+
+// IsBlacklisted is a free data retrieval call binding the contract method 0xfe575a87.
+//
+// Solidity: function isBlacklisted(address account) view returns(bool)
+func (_RestrictedToken *RestrictedTokenSession) IsBlacklisted(account common.Address) (bool, error) {
+	return _RestrictedToken.Contract.IsBlacklisted(&_RestrictedToken.CallOpts, account)
+}
+
+// IsBlacklisted is a free data retrieval call binding the contract method 0xfe575a87.
+//
+// Solidity: function isBlacklisted(address account) view returns(bool)
+func (_RestrictedToken *RestrictedTokenCallerSession) IsBlacklisted(account common.Address) (bool, error) {
+	return _RestrictedToken.Contract.IsBlacklisted(&_RestrictedToken.CallOpts, account)
+}
+
+// Paused is a free data retrieval call binding the contract method 0x5c975abb.
+//
+// Solidity: function paused() view returns(bool)
+func (_RestrictedToken *RestrictedTokenCaller) Paused(opts *bind.CallOpts) (bool, error) {
+	var out []interface{}
+	err := _RestrictedToken.contract.Call(opts, &out, "paused")
+
+	if err != nil {
+		return *new(bool), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(bool)).(*bool)
+
+	return out0, err
+
+}
+
+// Paused is a free data retrieval call binding the contract method 0x5c975abb.
+//
+// Solidity: function paused() view returns(bool)
+func (_RestrictedToken *RestrictedTokenSession) Paused() (bool, error) {
+	return _RestrictedToken.Contract.Paused(&_RestrictedToken.CallOpts)
+}
+
+// Paused is a free data retrieval call binding the contract method 0x5c975abb.
+//
+// Solidity: function paused() view returns(bool)
+func (_RestrictedToken *RestrictedTokenCallerSession) Paused() (bool, error) {
+	return _RestrictedToken.Contract.Paused(&_RestrictedToken.CallOpts)
+}
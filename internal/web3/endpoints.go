@@ -0,0 +1,420 @@
+package web3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"go.uber.org/zap"
+)
+
+// sendRawTransactionMethod is the JSON-RPC method routed to broadcast endpoints
+const sendRawTransactionMethod = "eth_sendRawTransaction"
+
+// stallTimeout is how long an endpoint's block number may stay unchanged before it is considered stalled
+const stallTimeout = 2 * time.Minute
+
+// latencySmoothing is the weight of the newest sample in an endpoint's moving average latency
+const latencySmoothing = 0.3
+
+// EndpointStatus is the observed health of one RPC endpoint
+type EndpointStatus struct {
+	Network   string `json:"network"`
+	URL       string `json:"url"`
+	Broadcast bool   `json:"broadcast"`
+	Healthy   bool   `json:"healthy"`
+	// LatencyMillis is the moving average latency of successful requests
+	LatencyMillis int64     `json:"latencyMillis"`
+	LastError     string    `json:"lastError,omitempty"`
+	CheckedAt     time.Time `json:"checkedAt"`
+}
+
+// endpoint is one RPC URL of a network with its observed health
+type endpoint struct {
+	url    *url.URL
+	rawURL string
+
+	mu        sync.Mutex
+	latency   time.Duration
+	downUntil time.Time
+	// wrongChain is set while the endpoint reports a different chain ID than configured
+	wrongChain bool
+	lastError  string
+	checkedAt  time.Time
+	// blockNumber is the latest block reported by the endpoint, and blockSeenAt when it last advanced
+	blockNumber uint64
+	blockSeenAt time.Time
+}
+
+// healthy reports whether the endpoint is outside its failure cooldown
+func (e *endpoint) healthy(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.downUntil)
+}
+
+// onWrongChain reports whether the endpoint last reported a different chain ID than configured
+func (e *endpoint) onWrongChain() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.wrongChain
+}
+
+// setWrongChain records whether the endpoint reports a different chain ID than configured
+func (e *endpoint) setWrongChain(wrongChain bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.wrongChain = wrongChain
+}
+
+// observeBlock records the latest block reported by the endpoint and returns how long it has not advanced
+func (e *endpoint) observeBlock(number uint64, now time.Time) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if number > e.blockNumber || e.blockSeenAt.IsZero() {
+		e.blockNumber = number
+		e.blockSeenAt = now
+	}
+	return now.Sub(e.blockSeenAt)
+}
+
+// succeeded records a successful request and its latency
+func (e *endpoint) succeeded(latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(e.latency))
+	}
+	e.downUntil = time.Time{}
+	e.lastError = ""
+	e.checkedAt = time.Now().UTC()
+}
+
+// failed records a failed request, keeping the endpoint out of rotation for cooldown
+func (e *endpoint) failed(err error, cooldown time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.downUntil = time.Now().Add(cooldown)
+	e.lastError = err.Error()
+	e.checkedAt = time.Now().UTC()
+}
+
+// endpointPool selects among the endpoints of one network role by health and latency
+type endpointPool struct {
+	endpoints []*endpoint
+	cooldown  time.Duration
+}
+
+// newEndpointPool creates a pool from RPC URLs
+func newEndpointPool(rawURLs []string, cooldown time.Duration) (*endpointPool, error) {
+	pool := &endpointPool{cooldown: cooldown}
+	for _, rawURL := range rawURLs {
+		parsed, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RPC endpoint %s: %w", rawURL, err)
+		}
+		pool.endpoints = append(pool.endpoints, &endpoint{url: parsed, rawURL: rawURL})
+	}
+	return pool, nil
+}
+
+// ordered returns the endpoints to try in order: healthy ones by ascending latency,
+// then endpoints in cooldown as a last resort
+// Endpoints on the wrong chain are never returned
+func (p *endpointPool) ordered() []*endpoint {
+	now := time.Now()
+	healthy := make([]*endpoint, 0, len(p.endpoints))
+	unhealthy := make([]*endpoint, 0)
+	for _, e := range p.endpoints {
+		if e.onWrongChain() {
+			continue
+		}
+		if e.healthy(now) {
+			healthy = append(healthy, e)
+		} else {
+			unhealthy = append(unhealthy, e)
+		}
+	}
+	sort.SliceStable(healthy, func(i, j int) bool {
+		return healthy[i].averageLatency() < healthy[j].averageLatency()
+	})
+	return append(healthy, unhealthy...)
+}
+
+// averageLatency returns the endpoint's moving average latency, zero until measured
+func (e *endpoint) averageLatency() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.latency
+}
+
+// status returns the endpoint's observed health
+func (e *endpoint) status(network string, broadcast bool) EndpointStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return EndpointStatus{
+		Network:       network,
+		URL:           RedactURL(e.rawURL),
+		Broadcast:     broadcast,
+		Healthy:       !e.wrongChain && !time.Now().Before(e.downUntil),
+		LatencyMillis: e.latency.Milliseconds(),
+		LastError:     redactError(e.lastError),
+		CheckedAt:     e.checkedAt,
+	}
+}
+
+// failoverTransport sends each JSON-RPC request to the best endpoint of a network and fails over to the next
+// on transport errors, rate limiting and server errors
+// Transaction broadcasts are routed to the broadcast endpoints, everything else to the read endpoints
+type failoverTransport struct {
+	network   string
+	chainID   *big.Int
+	read      *endpointPool
+	broadcast *endpointPool
+	base      http.RoundTripper
+	logger    *zap.Logger
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pool := t.read
+	if isBroadcast(body) {
+		pool = t.broadcast
+	}
+
+	endpoints := pool.ordered()
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no RPC endpoint of %s is on chain %s", t.network, t.chainID)
+	}
+
+	var lastErr error
+	for _, e := range endpoints {
+		attempt := req.Clone(req.Context())
+		attempt.URL = e.url
+		attempt.Host = e.url.Host
+		attempt.Body = io.NopCloser(bytes.NewReader(body))
+		attempt.ContentLength = int64(len(body))
+
+		start := time.Now()
+		resp, err := t.base.RoundTrip(attempt)
+		if err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < http.StatusInternalServerError {
+			e.succeeded(time.Since(start))
+			return resp, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("RPC endpoint returned %s", resp.Status)
+		}
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+
+		e.failed(err, pool.cooldown)
+		t.logger.Warn("RPC endpoint failed, failing over",
+			zap.Error(err),
+			zap.String("network", t.network),
+			zap.String("endpoint", RedactURL(e.rawURL)),
+		)
+		lastErr = err
+	}
+	return nil, fmt.Errorf("all RPC endpoints of %s failed: %w", t.network, lastErr)
+}
+
+// isBroadcast reports whether a JSON-RPC request body, single or batched, sends a transaction
+func isBroadcast(body []byte) bool {
+	var single struct {
+		Method string `json:"method"`
+	}
+	if json.Unmarshal(body, &single) == nil {
+		return single.Method == sendRawTransactionMethod
+	}
+
+	var batch []struct {
+		Method string `json:"method"`
+	}
+	if json.Unmarshal(body, &batch) == nil {
+		for _, message := range batch {
+			if message.Method == sendRawTransactionMethod {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// healthCheck measures every endpoint with eth_chainId and eth_blockNumber requests, takes endpoints that
+// report a different chain ID than configured out of rotation and puts stalled endpoints in cooldown
+func (t *failoverTransport) healthCheck(ctx context.Context, timeout time.Duration) {
+	seen := make(map[*endpoint]bool)
+	for _, pool := range []*endpointPool{t.read, t.broadcast} {
+		for _, e := range pool.endpoints {
+			if seen[e] {
+				continue
+			}
+			seen[e] = true
+
+			start := time.Now()
+			err := t.ping(ctx, e, timeout)
+			if errors.Is(err, ErrChainIDMismatch) {
+				t.logger.Error("RPC endpoint is on the wrong chain, endpoint disabled until it reports the configured chain ID",
+					zap.Error(err),
+					zap.String("network", t.network),
+					zap.String("endpoint", RedactURL(e.rawURL)),
+				)
+				e.setWrongChain(true)
+				e.failed(err, pool.cooldown)
+				continue
+			}
+			if err != nil {
+				if e.healthy(time.Now()) {
+					t.logger.Warn("RPC endpoint health check failed",
+						zap.Error(err),
+						zap.String("network", t.network),
+						zap.String("endpoint", RedactURL(e.rawURL)),
+					)
+				}
+				e.failed(err, pool.cooldown)
+				continue
+			}
+			e.setWrongChain(false)
+			e.succeeded(time.Since(start))
+		}
+	}
+}
+
+// ping checks one endpoint directly: eth_chainId must match the configured chain ID and eth_blockNumber
+// must have advanced within stallTimeout, nodes answer eth_chainId from their configuration even when they stopped syncing
+func (t *failoverTransport) ping(ctx context.Context, e *endpoint, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	chainID, err := t.probe(ctx, e, "eth_chainId")
+	if err != nil {
+		return err
+	}
+	if chainID.Cmp(t.chainID) != 0 {
+		return fmt.Errorf("%w: configured %s, RPC reports %s", ErrChainIDMismatch, t.chainID, chainID)
+	}
+
+	blockNumber, err := t.probe(ctx, e, "eth_blockNumber")
+	if err != nil {
+		return err
+	}
+	if !blockNumber.IsUint64() {
+		return fmt.Errorf("invalid JSON-RPC response: block number %s", blockNumber)
+	}
+	if stalledFor := e.observeBlock(blockNumber.Uint64(), time.Now()); stalledFor > stallTimeout {
+		return fmt.Errorf("RPC endpoint stalled at block %s for %s", blockNumber, stalledFor.Round(time.Second))
+	}
+	return nil
+}
+
+// probe sends a JSON-RPC request without parameters directly to one endpoint and decodes its quantity result
+func (t *failoverTransport) probe(ctx context.Context, e *endpoint, method string) (*big.Int, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  []interface{}{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("RPC endpoint returned %s", resp.Status)
+	}
+	var result struct {
+		Result *hexutil.Big `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid JSON-RPC response: %w", err)
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("JSON-RPC error: %s", result.Error.Message)
+	}
+	if result.Result == nil {
+		return nil, fmt.Errorf("invalid JSON-RPC response: missing %s result", method)
+	}
+	return result.Result.ToInt(), nil
+}
+
+// statuses returns the observed health of every endpoint
+func (t *failoverTransport) statuses() []EndpointStatus {
+	statuses := make([]EndpointStatus, 0, len(t.read.endpoints)+len(t.broadcast.endpoints))
+	for _, e := range t.read.endpoints {
+		statuses = append(statuses, e.status(t.network, false))
+	}
+	if t.broadcast != t.read {
+		for _, e := range t.broadcast.endpoints {
+			statuses = append(statuses, e.status(t.network, true))
+		}
+	}
+	return statuses
+}
+
+// urlPattern matches the URLs embedded in error messages
+var urlPattern = regexp.MustCompile(`(?i)\b(?:https?|wss?)://[^\s"'<>]+`)
+
+// RedactURL reduces an RPC URL to its scheme and host
+// Provider URLs usually embed an API key in their path, query or user info, which must not be exposed
+func RedactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return "[redacted]"
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+// maxReportedErrorLength caps the length of reported errors, which may quote a provider's response body
+const maxReportedErrorLength = 200
+
+// redactError reduces every URL in an error message to its scheme and host and truncates it
+func redactError(message string) string {
+	message = urlPattern.ReplaceAllStringFunc(message, RedactURL)
+	if runes := []rune(message); len(runes) > maxReportedErrorLength {
+		message = string(runes[:maxReportedErrorLength]) + "..."
+	}
+	return message
+}
@@ -0,0 +1,462 @@
+package web3
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+	"x402-facilitator-go/internal/config"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.uber.org/zap"
+)
+
+// ErrRPCFailure is returned when a network's RPC failed or timed out on every attempt of a call
+// It marks infrastructure failures, as opposed to the node answering with an error such as a revert
+var ErrRPCFailure = errors.New("RPC failure")
+
+// ErrBroadcastUnknown is returned when sending a transaction failed without a definite answer from the node,
+// such as a timeout or a dropped connection, so the node may have accepted it and the transaction may still be mined
+var ErrBroadcastUnknown = errors.New("transaction broadcast outcome unknown")
+
+// ErrCircuitOpen is returned without calling the RPC while a network's circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+var (
+	// retriesMetric counts retried RPC calls, by network
+	retriesMetric = expvar.NewMap("rpc_retries")
+	// failuresMetric counts RPC calls that failed every attempt, by network
+	failuresMetric = expvar.NewMap("rpc_failures")
+	// breakerTripsMetric counts circuit breaker openings, by network
+	breakerTripsMetric = expvar.NewMap("rpc_breaker_trips")
+)
+
+// JSON-RPC error codes of node-side failures, any other JSON-RPC error is the node's answer to the call
+const (
+	rpcInternalError = -32603
+	rpcLimitExceeded = -32005
+)
+
+// policyBackend is the Backend of an RPC network, applying the network's RPC policy to every call: a per-attempt
+// deadline, jittered retries of reads and a circuit breaker shared by all calls of the network
+// Transactions get a deadline but are never retried
+type policyBackend struct {
+	client *ethclient.Client
+	// heads serves head subscriptions, the network's WebSocket client or client itself
+	heads   *ethclient.Client
+	network string
+	policy  config.RPCPolicy
+	breaker *circuitBreaker
+	logger  *zap.Logger
+}
+
+// newPolicyBackend wraps client with a network's policy and circuit breaker
+// heads is the client head subscriptions are made on, client is used when it is nil
+func newPolicyBackend(client, heads *ethclient.Client, network string, policy config.RPCPolicy, breaker *circuitBreaker, logger *zap.Logger) *policyBackend {
+	if heads == nil {
+		heads = client
+	}
+	return &policyBackend{
+		client:  client,
+		heads:   heads,
+		network: network,
+		policy:  policy,
+		breaker: breaker,
+		logger:  logger,
+	}
+}
+
+// read runs an idempotent call with retries
+func (p *policyBackend) read(ctx context.Context, method string, call func(ctx context.Context) error) error {
+	return p.do(ctx, method, p.policy.MaxRetries, call)
+}
+
+// do runs call up to retries+1 times, each attempt bounded by the call timeout
+// Only infrastructure failures are retried and counted by the circuit breaker, an error answered by the node
+// is returned as is
+func (p *policyBackend) do(ctx context.Context, method string, retries int, call func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			retriesMetric.Add(p.network, 1)
+			p.logger.Debug("Retrying RPC call",
+				zap.String("network", p.network),
+				zap.String("method", method),
+				zap.Int("attempt", attempt+1),
+				zap.Error(err),
+			)
+			if sleepErr := sleepContext(ctx, p.backoff(attempt)); sleepErr != nil {
+				return err
+			}
+		}
+
+		if breakerErr := p.breaker.allow(); breakerErr != nil {
+			return breakerErr
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, time.Duration(p.policy.CallTimeoutMillis)*time.Millisecond)
+		err = call(attemptCtx)
+		cancel()
+
+		if err != nil && ctx.Err() != nil {
+			// The caller gave up, which says nothing about the RPC
+			p.breaker.release()
+			return err
+		}
+		if !isTransientError(err) {
+			p.breaker.succeeded()
+			return err
+		}
+		p.breaker.failed(err)
+	}
+
+	failuresMetric.Add(p.network, 1)
+	return fmt.Errorf("%w: %s on %s: %w", ErrRPCFailure, method, p.network, err)
+}
+
+// backoff returns the jittered delay before a retry, doubling the base delay on every attempt
+func (p *policyBackend) backoff(attempt int) time.Duration {
+	delay := time.Duration(p.policy.RetryBackoffMillis) * time.Millisecond << (attempt - 1)
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isTransientError reports whether err is an infrastructure failure worth retrying: a timeout, a transport
+// error, an HTTP 429 or 5xx status or a node-side JSON-RPC error
+// Reverts, missing receipts and other errors the node answered with are not
+func isTransientError(err error) bool {
+	if err == nil || errors.Is(err, ethereum.NotFound) || isRevert(err) {
+		return false
+	}
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= http.StatusInternalServerError
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.ErrorCode() == rpcInternalError || rpcErr.ErrorCode() == rpcLimitExceeded
+	}
+	return true
+}
+
+// isRateLimited reports whether err is an HTTP 429, which the node answers without accepting the request
+func isRateLimited(err error) bool {
+	var httpErr rpc.HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests
+}
+
+// CodeAt returns the code of an account
+func (p *policyBackend) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) (code []byte, err error) {
+	err = p.read(ctx, "CodeAt", func(ctx context.Context) (err error) {
+		code, err = p.client.CodeAt(ctx, account, blockNumber)
+		return err
+	})
+	return code, err
+}
+
+// CallContract executes an eth_call
+func (p *policyBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) (result []byte, err error) {
+	err = p.read(ctx, "CallContract", func(ctx context.Context) (err error) {
+		result, err = p.client.CallContract(ctx, call, blockNumber)
+		return err
+	})
+	return result, err
+}
+
+// HeaderByNumber returns a block header, the latest when number is nil
+func (p *policyBackend) HeaderByNumber(ctx context.Context, number *big.Int) (header *types.Header, err error) {
+	err = p.read(ctx, "HeaderByNumber", func(ctx context.Context) (err error) {
+		header, err = p.client.HeaderByNumber(ctx, number)
+		return err
+	})
+	return header, err
+}
+
+// PendingCodeAt returns the code of an account in the pending state
+func (p *policyBackend) PendingCodeAt(ctx context.Context, account common.Address) (code []byte, err error) {
+	err = p.read(ctx, "PendingCodeAt", func(ctx context.Context) (err error) {
+		code, err = p.client.PendingCodeAt(ctx, account)
+		return err
+	})
+	return code, err
+}
+
+// PendingNonceAt returns the pending nonce of an account
+func (p *policyBackend) PendingNonceAt(ctx context.Context, account common.Address) (nonce uint64, err error) {
+	err = p.read(ctx, "PendingNonceAt", func(ctx context.Context) (err error) {
+		nonce, err = p.client.PendingNonceAt(ctx, account)
+		return err
+	})
+	return nonce, err
+}
+
+// SuggestGasPrice returns the suggested gas price
+func (p *policyBackend) SuggestGasPrice(ctx context.Context) (price *big.Int, err error) {
+	err = p.read(ctx, "SuggestGasPrice", func(ctx context.Context) (err error) {
+		price, err = p.client.SuggestGasPrice(ctx)
+		return err
+	})
+	return price, err
+}
+
+// SuggestGasTipCap returns the suggested priority fee
+func (p *policyBackend) SuggestGasTipCap(ctx context.Context) (tip *big.Int, err error) {
+	err = p.read(ctx, "SuggestGasTipCap", func(ctx context.Context) (err error) {
+		tip, err = p.client.SuggestGasTipCap(ctx)
+		return err
+	})
+	return tip, err
+}
+
+// EstimateGas estimates the gas of a call
+func (p *policyBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (gas uint64, err error) {
+	err = p.read(ctx, "EstimateGas", func(ctx context.Context) (err error) {
+		gas, err = p.client.EstimateGas(ctx, call)
+		return err
+	})
+	return gas, err
+}
+
+// SendTransaction broadcasts a signed transaction once, a lost broadcast is resent by the settlement tracker
+// A failure the node may have accepted the transaction despite is returned wrapping ErrBroadcastUnknown
+func (p *policyBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	err := p.do(ctx, "SendTransaction", 0, func(ctx context.Context) error {
+		return p.client.SendTransaction(ctx, tx)
+	})
+	if err != nil && (errors.Is(err, ErrRPCFailure) || ctx.Err() != nil) && !isRateLimited(err) {
+		return fmt.Errorf("%w: %s: %w", ErrBroadcastUnknown, tx.Hash().Hex(), err)
+	}
+	return err
+}
+
+// FilterLogs returns the logs matching a query
+func (p *policyBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) (logs []types.Log, err error) {
+	err = p.read(ctx, "FilterLogs", func(ctx context.Context) (err error) {
+		logs, err = p.client.FilterLogs(ctx, query)
+		return err
+	})
+	return logs, err
+}
+
+// SubscribeFilterLogs subscribes to logs matching a query, subscriptions are long-lived and not subject to the policy
+func (p *policyBackend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return p.client.SubscribeFilterLogs(ctx, query, ch)
+}
+
+// BalanceAt returns the native balance of an account
+func (p *policyBackend) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (balance *big.Int, err error) {
+	err = p.read(ctx, "BalanceAt", func(ctx context.Context) (err error) {
+		balance, err = p.client.BalanceAt(ctx, account, blockNumber)
+		return err
+	})
+	return balance, err
+}
+
+// StorageAt returns a storage slot of an account
+func (p *policyBackend) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) (value []byte, err error) {
+	err = p.read(ctx, "StorageAt", func(ctx context.Context) (err error) {
+		value, err = p.client.StorageAt(ctx, account, key, blockNumber)
+		return err
+	})
+	return value, err
+}
+
+// NonceAt returns the nonce of an account
+func (p *policyBackend) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (nonce uint64, err error) {
+	err = p.read(ctx, "NonceAt", func(ctx context.Context) (err error) {
+		nonce, err = p.client.NonceAt(ctx, account, blockNumber)
+		return err
+	})
+	return nonce, err
+}
+
+// TransactionByHash returns a transaction and whether it is still pending
+func (p *policyBackend) TransactionByHash(ctx context.Context, txHash common.Hash) (tx *types.Transaction, isPending bool, err error) {
+	err = p.read(ctx, "TransactionByHash", func(ctx context.Context) (err error) {
+		tx, isPending, err = p.client.TransactionByHash(ctx, txHash)
+		return err
+	})
+	return tx, isPending, err
+}
+
+// TransactionReceipt returns the receipt of a mined transaction
+func (p *policyBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (receipt *types.Receipt, err error) {
+	err = p.read(ctx, "TransactionReceipt", func(ctx context.Context) (err error) {
+		receipt, err = p.client.TransactionReceipt(ctx, txHash)
+		return err
+	})
+	return receipt, err
+}
+
+// TransactionReceipts fetches the receipts of several transactions in one JSON-RPC batch
+func (p *policyBackend) TransactionReceipts(ctx context.Context, hashes []common.Hash) (receipts []*types.Receipt, err error) {
+	err = p.read(ctx, "TransactionReceipts", func(ctx context.Context) error {
+		receipts = make([]*types.Receipt, len(hashes))
+		batch := make([]rpc.BatchElem, len(hashes))
+		for i, hash := range hashes {
+			batch[i] = rpc.BatchElem{
+				Method: "eth_getTransactionReceipt",
+				Args:   []interface{}{hash},
+				Result: &receipts[i],
+			}
+		}
+		if err := p.client.Client().BatchCallContext(ctx, batch); err != nil {
+			return err
+		}
+		for _, elem := range batch {
+			if elem.Error != nil {
+				return elem.Error
+			}
+		}
+		return nil
+	})
+	return receipts, err
+}
+
+// SubscribeNewHead subscribes to new chain heads, subscriptions are long-lived and not subject to the policy
+func (p *policyBackend) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	return p.heads.SubscribeNewHead(ctx, ch)
+}
+
+// BlockNumber returns the number of the most recent block
+func (p *policyBackend) BlockNumber(ctx context.Context) (number uint64, err error) {
+	err = p.read(ctx, "BlockNumber", func(ctx context.Context) (err error) {
+		number, err = p.client.BlockNumber(ctx)
+		return err
+	})
+	return number, err
+}
+
+// ChainID returns the chain ID reported by the node
+func (p *policyBackend) ChainID(ctx context.Context) (chainID *big.Int, err error) {
+	err = p.read(ctx, "ChainID", func(ctx context.Context) (err error) {
+		chainID, err = p.client.ChainID(ctx)
+		return err
+	})
+	return chainID, err
+}
+
+// breakerState is the state of a circuit breaker
+type breakerState int
+
+const (
+	// breakerClosed lets every call through
+	breakerClosed breakerState = iota
+	// breakerOpen rejects every call until the cooldown has passed
+	breakerOpen
+	// breakerHalfOpen lets a single trial call through, its outcome closes or reopens the breaker
+	breakerHalfOpen
+)
+
+// circuitBreaker stops calling a network's RPC after consecutive infrastructure failures
+// so that requests fail fast instead of each waiting out timeouts and retries
+type circuitBreaker struct {
+	network   string
+	threshold int
+	cooldown  time.Duration
+	logger    *zap.Logger
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// newCircuitBreaker creates a closed circuit breaker for a network
+func newCircuitBreaker(network string, policy config.RPCPolicy, logger *zap.Logger) *circuitBreaker {
+	return &circuitBreaker{
+		network:   network,
+		threshold: policy.BreakerFailures,
+		cooldown:  time.Duration(policy.BreakerCooldownSeconds) * time.Second,
+		logger:    logger,
+	}
+}
+
+// allow returns an error wrapping ErrNetworkUnavailable and ErrCircuitOpen when a call may not go through
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return fmt.Errorf("%w: %s: %w", ErrNetworkUnavailable, b.network, ErrCircuitOpen)
+		}
+		b.state = breakerHalfOpen
+		b.trial = true
+	case breakerHalfOpen:
+		if b.trial {
+			return fmt.Errorf("%w: %s: %w", ErrNetworkUnavailable, b.network, ErrCircuitOpen)
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// succeeded records a call the RPC answered
+func (b *circuitBreaker) succeeded() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.logger.Info("RPC circuit breaker closed", zap.String("network", b.network))
+	}
+	b.state = breakerClosed
+	b.failures = 0
+	b.trial = false
+}
+
+// failed records an infrastructure failure, opening the breaker at the threshold or when a trial call fails
+func (b *circuitBreaker) failed(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= b.threshold) {
+		b.logger.Warn("RPC circuit breaker opened, calls are rejected until the cooldown has passed",
+			zap.String("network", b.network),
+			zap.Int("consecutiveFailures", b.failures),
+			zap.Duration("cooldown", b.cooldown),
+			zap.Error(err),
+		)
+		breakerTripsMetric.Add(b.network, 1)
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+	b.trial = false
+}
+
+// release ends a call without an outcome, letting another trial call through when half-open
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// open reports whether the breaker currently rejects calls
+func (b *circuitBreaker) open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == breakerOpen && time.Since(b.openedAt) < b.cooldown
+}
@@ -0,0 +1,198 @@
+package web3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+	"x402-facilitator-go/internal/config"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.uber.org/zap"
+)
+
+// testBreakerCooldown is the cooldown of the circuit breakers in the policy tests
+const testBreakerCooldown = 20 * time.Millisecond
+
+// jsonRPCError is a JSON-RPC error answered by a node
+type jsonRPCError struct {
+	code    int
+	message string
+}
+
+func (e jsonRPCError) Error() string  { return e.message }
+func (e jsonRPCError) ErrorCode() int { return e.code }
+
+// revertError is a JSON-RPC revert carrying revert data
+type revertError struct{}
+
+func (revertError) Error() string          { return "execution reverted: FiatTokenV2: invalid signature" }
+func (revertError) ErrorCode() int         { return 3 }
+func (revertError) ErrorData() interface{} { return "0x08c379a0" }
+
+// errTransport is a transport failure such as a refused connection
+var errTransport = errors.New("dial tcp 127.0.0.1:8545: connect: connection refused")
+
+// newTestBreaker creates a circuit breaker opening after threshold consecutive failures
+func newTestBreaker(threshold int) *circuitBreaker {
+	breaker := newCircuitBreaker("test", config.RPCPolicy{BreakerFailures: threshold}, zap.NewNop())
+	breaker.cooldown = testBreakerCooldown
+	return breaker
+}
+
+// newTestPolicyBackend creates a policyBackend without a client, for calls going through do
+func newTestPolicyBackend(maxRetries, breakerFailures int) *policyBackend {
+	policy := config.RPCPolicy{
+		CallTimeoutMillis:  1000,
+		MaxRetries:         maxRetries,
+		RetryBackoffMillis: 1,
+		BreakerFailures:    breakerFailures,
+	}
+	return newPolicyBackend(nil, nil, "test", policy, newTestBreaker(breakerFailures), zap.NewNop())
+}
+
+func TestCircuitBreakerStates(t *testing.T) {
+	const (
+		allow    = "allow"
+		reject   = "reject"
+		fail     = "fail"
+		succeed  = "succeed"
+		release  = "release"
+		cooldown = "cooldown"
+	)
+
+	for _, tc := range []struct {
+		name      string
+		steps     []string
+		wantState breakerState
+	}{
+		{"closed below the threshold", []string{allow, fail, allow, fail}, breakerClosed},
+		{"success resets the failure count", []string{allow, fail, allow, fail, allow, succeed, allow, fail, allow, fail}, breakerClosed},
+		{"opens at the threshold", []string{allow, fail, allow, fail, allow, fail, reject}, breakerOpen},
+		{"half-open after the cooldown lets one trial through", []string{allow, fail, allow, fail, allow, fail, cooldown, allow, reject}, breakerHalfOpen},
+		{"closes when the trial succeeds", []string{allow, fail, allow, fail, allow, fail, cooldown, allow, succeed, allow, allow}, breakerClosed},
+		{"reopens when the trial fails", []string{allow, fail, allow, fail, allow, fail, cooldown, allow, fail, reject}, breakerOpen},
+		{"a released trial lets another through", []string{allow, fail, allow, fail, allow, fail, cooldown, allow, release, allow, reject}, breakerHalfOpen},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			breaker := newTestBreaker(3)
+			for i, step := range tc.steps {
+				switch step {
+				case allow:
+					if err := breaker.allow(); err != nil {
+						t.Fatalf("step %d: want the call allowed, got %v", i, err)
+					}
+				case reject:
+					err := breaker.allow()
+					if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrNetworkUnavailable) {
+						t.Fatalf("step %d: want ErrCircuitOpen and ErrNetworkUnavailable, got %v", i, err)
+					}
+				case fail:
+					breaker.failed(errTransport)
+				case succeed:
+					breaker.succeeded()
+				case release:
+					breaker.release()
+				case cooldown:
+					time.Sleep(testBreakerCooldown)
+				}
+			}
+			if breaker.state != tc.wantState {
+				t.Fatalf("state %d, want %d", breaker.state, tc.wantState)
+			}
+			if open := breaker.open(); open != (tc.wantState == breakerOpen) {
+				t.Fatalf("open() %v in state %d", open, breaker.state)
+			}
+		})
+	}
+}
+
+func TestPolicyRetryBudget(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		maxRetries      int
+		breakerFailures int
+		// errs are the errors of successive attempts, the attempts after the last one succeed
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{"succeeds without retries", 2, 10, nil, 1, nil},
+		{"retries transient failures until success", 2, 10, []error{errTransport, errTransport}, 3, nil},
+		{"gives up after the retry budget", 2, 10, []error{errTransport, errTransport, errTransport, errTransport}, 3, ErrRPCFailure},
+		{"does not retry transactions", 0, 10, []error{errTransport}, 1, ErrRPCFailure},
+		{"does not retry answered errors", 2, 10, []error{revertError{}}, 1, revertError{}},
+		{"stops retrying once the breaker opens", 5, 2, []error{errTransport, errTransport, errTransport}, 2, ErrCircuitOpen},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := newTestPolicyBackend(tc.maxRetries, tc.breakerFailures)
+			attempts := 0
+			err := backend.do(context.Background(), "test", tc.maxRetries, func(ctx context.Context) error {
+				attempts++
+				if attempts <= len(tc.errs) {
+					return tc.errs[attempts-1]
+				}
+				return nil
+			})
+
+			if attempts != tc.wantAttempts {
+				t.Fatalf("%d attempts, want %d", attempts, tc.wantAttempts)
+			}
+			if tc.wantErr == nil && err != nil {
+				t.Fatalf("want success, got %v", err)
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPolicyCancelledCallDoesNotCountAsFailure(t *testing.T) {
+	backend := newTestPolicyBackend(2, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := backend.do(ctx, "test", 2, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+	if backend.breaker.state != breakerClosed || backend.breaker.failures != 0 {
+		t.Fatalf("breaker state %d with %d failures, want closed without failures", backend.breaker.state, backend.breaker.failures)
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"no error", nil, false},
+		{"not found", ethereum.NotFound, false},
+		{"revert with data", revertError{}, false},
+		{"revert message", errors.New("execution reverted"), false},
+		{"wrapped revert", fmt.Errorf("failed to call balanceOf: %w", revertError{}), false},
+		{"nonce too low", jsonRPCError{code: -32000, message: "nonce too low"}, false},
+		{"invalid params", jsonRPCError{code: -32602, message: "invalid argument 0"}, false},
+		{"HTTP 400", rpc.HTTPError{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"}, false},
+		{"node internal error", jsonRPCError{code: rpcInternalError, message: "internal error"}, true},
+		{"node limit exceeded", jsonRPCError{code: rpcLimitExceeded, message: "request limit exceeded"}, true},
+		{"HTTP 429", rpc.HTTPError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"}, true},
+		{"HTTP 502", rpc.HTTPError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}, true},
+		{"deadline exceeded", context.DeadlineExceeded, true},
+		{"transport failure", errTransport, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isTransientError(tc.err); got != tc.want {
+				t.Fatalf("isTransientError(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
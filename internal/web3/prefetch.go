@@ -0,0 +1,142 @@
+package web3
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"math/big"
+	"x402-facilitator-go/internal/config"
+	"x402-facilitator-go/internal/web3/contract"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+// errPrefetchedRevert is returned for a prefetched call that reverted inside the Multicall3 batch
+var errPrefetchedRevert = stderrors.New("execution reverted")
+
+// Call is a read-only eth_call at the latest block
+type Call struct {
+	Target common.Address
+	Data   []byte
+}
+
+// prefetchKey is the context key of prefetched call results
+type prefetchKey struct{}
+
+// prefetchedCalls holds the results of prefetched calls by target and calldata
+type prefetchedCalls map[string]contract.Multicall3Result
+
+// Prefetcher sends the calls a verification needs as one Multicall3 aggregate3 eth_call and carries the results in
+// the context, so that verifiers reading through PrefetchedCaller are answered without another round trip
+type Prefetcher struct {
+	chain     Chain
+	assets    *AssetCache
+	multicall common.Address
+	logger    *zap.Logger
+}
+
+// NewPrefetcher creates a Prefetcher using the configured Multicall3 deployment
+func NewPrefetcher(chain Chain, assets *AssetCache, cfg config.VerificationConfig, logger *zap.Logger) *Prefetcher {
+	return &Prefetcher{
+		chain:     chain,
+		assets:    assets,
+		multicall: common.HexToAddress(cfg.Multicall3Address),
+		logger:    logger,
+	}
+}
+
+// Prefetch executes calls on network in one batch and returns a context carrying their results
+// Networks without the Multicall3 deployment are left to individual calls and the context is returned unchanged
+func (p *Prefetcher) Prefetch(ctx context.Context, network string, calls []Call) (context.Context, error) {
+	if len(calls) < 2 {
+		return ctx, nil
+	}
+
+	deployed, err := p.assets.HasCode(ctx, network, p.multicall)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to check Multicall3 deployment: %w", err)
+	}
+	if !deployed {
+		p.logger.Debug("Multicall3 not deployed, verification reads are not batched",
+			zap.String("network", network),
+			zap.String("multicall3", p.multicall.Hex()),
+		)
+		return ctx, nil
+	}
+
+	client, err := p.chain.GetClient(network)
+	if err != nil {
+		return ctx, err
+	}
+	multicall, err := contract.NewMulticall3Caller(p.multicall, client)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to bind Multicall3: %w", err)
+	}
+
+	calls3 := make([]contract.Multicall3Call3, 0, len(calls))
+	for _, call := range calls {
+		calls3 = append(calls3, contract.Multicall3Call3{
+			Target:       call.Target,
+			AllowFailure: true,
+			CallData:     call.Data,
+		})
+	}
+
+	var out []interface{}
+	if err := (&contract.Multicall3CallerRaw{Contract: multicall}).Call(&bind.CallOpts{Context: ctx}, &out, "aggregate3", calls3); err != nil {
+		return ctx, fmt.Errorf("failed to call Multicall3 aggregate3: %w", err)
+	}
+	results := *abi.ConvertType(out[0], new([]contract.Multicall3Result)).(*[]contract.Multicall3Result)
+	if len(results) != len(calls) {
+		return ctx, fmt.Errorf("Multicall3 returned %d results for %d calls", len(results), len(calls))
+	}
+
+	prefetched := make(prefetchedCalls, len(calls))
+	if existing, ok := ctx.Value(prefetchKey{}).(prefetchedCalls); ok {
+		for key, result := range existing {
+			prefetched[key] = result
+		}
+	}
+	for i, call := range calls {
+		prefetched[callKey(call.Target, call.Data)] = results[i]
+	}
+	return context.WithValue(ctx, prefetchKey{}, prefetched), nil
+}
+
+// PrefetchedCaller returns a caller answering eth_calls at the latest block from the results prefetched into ctx,
+// forwarding every other call to caller
+func PrefetchedCaller(ctx context.Context, caller bind.ContractCaller) bind.ContractCaller {
+	prefetched, ok := ctx.Value(prefetchKey{}).(prefetchedCalls)
+	if !ok {
+		return caller
+	}
+	return &prefetchedCaller{ContractCaller: caller, prefetched: prefetched}
+}
+
+// prefetchedCaller is a bind.ContractCaller consulting prefetched results before the backend
+type prefetchedCaller struct {
+	bind.ContractCaller
+	prefetched prefetchedCalls
+}
+
+// CallContract returns the prefetched result of a latest-block call, or calls the backend
+func (c *prefetchedCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if blockNumber == nil && call.To != nil && (call.Value == nil || call.Value.Sign() == 0) {
+		if result, ok := c.prefetched[callKey(*call.To, call.Data)]; ok {
+			if !result.Success {
+				return nil, errPrefetchedRevert
+			}
+			return result.ReturnData, nil
+		}
+	}
+	return c.ContractCaller.CallContract(ctx, call, blockNumber)
+}
+
+// callKey identifies a call by target and calldata
+func callKey(target common.Address, data []byte) string {
+	return target.Hex() + ":" + common.Bytes2Hex(data)
+}
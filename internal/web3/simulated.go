@@ -0,0 +1,211 @@
+package web3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// simulatedBlockTime is the timestamp gap the simulated backend puts between consecutive blocks
+const simulatedBlockTime = 10 * time.Second
+
+// SimulatedBackend is a Backend on go-ethereum's in-process simulated chain, which always has chain ID 1337
+// Sent transactions are mined right away and block timestamps follow the wall clock, so that
+// authorization validity windows behave like on a live network
+type SimulatedBackend struct {
+	*backends.SimulatedBackend
+	mu sync.Mutex
+}
+
+// NewSimulatedBackend creates a simulated chain with the given genesis allocation and block gas limit
+func NewSimulatedBackend(alloc core.GenesisAlloc, gasLimit uint64) *SimulatedBackend {
+	b := &SimulatedBackend{SimulatedBackend: backends.NewSimulatedBackend(alloc, gasLimit)}
+	b.Mine()
+	return b
+}
+
+// SendTransaction includes the transaction in a new block
+func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.catchUp()
+	if err := b.SimulatedBackend.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	b.SimulatedBackend.Commit()
+	return nil
+}
+
+// Mine commits an empty block, adding a confirmation to every mined transaction
+func (b *SimulatedBackend) Mine() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.catchUp() {
+		b.SimulatedBackend.Commit()
+	}
+}
+
+// catchUp commits an empty block when the chain has fallen behind the wall clock, timestamped so that
+// the next block is timestamped now, and reports whether it did
+func (b *SimulatedBackend) catchUp() bool {
+	head := b.Blockchain().CurrentHeader()
+	lag := time.Until(time.Unix(int64(head.Time), 0).Add(2 * simulatedBlockTime))
+	if lag >= 0 {
+		return false
+	}
+	if err := b.AdjustTime(-lag); err != nil {
+		return false
+	}
+	b.SimulatedBackend.Commit()
+	return true
+}
+
+// BlockNumber returns the number of the most recent block
+func (b *SimulatedBackend) BlockNumber(ctx context.Context) (uint64, error) {
+	return b.Blockchain().CurrentHeader().Number.Uint64(), nil
+}
+
+// ChainID returns the chain ID of the simulated chain
+func (b *SimulatedBackend) ChainID(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(b.Blockchain().Config().ChainID), nil
+}
+
+// TransactionReceipts returns the receipts of several transactions, nil for those not mined
+func (b *SimulatedBackend) TransactionReceipts(ctx context.Context, hashes []common.Hash) ([]*types.Receipt, error) {
+	receipts := make([]*types.Receipt, len(hashes))
+	for i, hash := range hashes {
+		receipt, err := b.TransactionReceipt(ctx, hash)
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+		receipts[i] = receipt
+	}
+	return receipts, nil
+}
+
+// SimulatedNetwork is one network of a Simulated chain
+type SimulatedNetwork struct {
+	Name          string
+	Backend       *SimulatedBackend
+	Confirmations uint64
+}
+
+// Simulated is a Chain of in-process simulated networks, used to run the verify and settle flow without RPC
+type Simulated struct {
+	networks map[string]SimulatedNetwork
+}
+
+// NewSimulated creates a Chain over simulated networks
+func NewSimulated(networks ...SimulatedNetwork) *Simulated {
+	networkMap := make(map[string]SimulatedNetwork, len(networks))
+	for _, network := range networks {
+		if network.Confirmations == 0 {
+			network.Confirmations = 1
+		}
+		networkMap[network.Name] = network
+	}
+	return &Simulated{networks: networkMap}
+}
+
+// GetClient returns the simulated backend of a network
+func (s *Simulated) GetClient(networkName string) (Backend, error) {
+	network, ok := s.networks[networkName]
+	if !ok {
+		return nil, fmt.Errorf("network %s not configured", networkName)
+	}
+	return network.Backend, nil
+}
+
+// GetChainID returns the chain ID of a network's simulated chain
+func (s *Simulated) GetChainID(networkName string) (*big.Int, error) {
+	network, ok := s.networks[networkName]
+	if !ok {
+		return nil, fmt.Errorf("chain ID not configured for network %s", networkName)
+	}
+	return network.Backend.ChainID(context.Background())
+}
+
+// GetConfirmations returns the number of confirmations required for settlements on a network
+func (s *Simulated) GetConfirmations(networkName string) (uint64, error) {
+	network, ok := s.networks[networkName]
+	if !ok {
+		return 0, fmt.Errorf("network %s not configured", networkName)
+	}
+	return network.Confirmations, nil
+}
+
+// GetMinGasBalance returns zero, simulated settlement signers never run out of gas
+func (s *Simulated) GetMinGasBalance(networkName string) (*big.Int, error) {
+	if _, ok := s.networks[networkName]; !ok {
+		return nil, fmt.Errorf("network %s not configured", networkName)
+	}
+	return new(big.Int), nil
+}
+
+// Available reports whether the network is configured, simulated networks are always reachable
+func (s *Simulated) Available(networkName string) bool {
+	_, ok := s.networks[networkName]
+	return ok
+}
+
+// Networks returns the names of the simulated networks in sorted order
+func (s *Simulated) Networks() []string {
+	networks := make([]string, 0, len(s.networks))
+	for network := range s.networks {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+	return networks
+}
+
+// Run mines an empty block on every network each block time until ctx is cancelled, so that settlements
+// requiring more than one confirmation complete and the chain keeps up with the wall clock
+func (s *Simulated) Run(ctx context.Context) {
+	ticker := time.NewTicker(simulatedBlockTime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, network := range s.networks {
+				network.Backend.Mine()
+			}
+		}
+	}
+}
+
+// NetworkStatuses reports every simulated network as available
+func (s *Simulated) NetworkStatuses() []NetworkStatus {
+	statuses := make([]NetworkStatus, 0, len(s.networks))
+	for _, network := range s.Networks() {
+		statuses = append(statuses, NetworkStatus{Network: network, Available: true})
+	}
+	return statuses
+}
+
+// EndpointStatuses returns no endpoints, simulated networks have no RPC endpoints
+func (s *Simulated) EndpointStatuses() []EndpointStatus {
+	return []EndpointStatus{}
+}
+
+// Close stops every simulated chain
+func (s *Simulated) Close() error {
+	for _, network := range s.networks {
+		network.Backend.Close()
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Package attestation signs and verifies EIP-712 settlement attestations issued by the facilitator
+// Resource servers and auditors use Verify to check a settlement offline against the facilitator's attestation signer
+package attestation
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// DomainName is the EIP-712 domain name of settlement attestations
+	DomainName = "x402 Facilitator Attestation"
+	// DomainVersion is the EIP-712 domain version of settlement attestations
+	DomainVersion = "1"
+)
+
+// ErrSignerMismatch is returned when an attestation was not signed by the expected signer
+var ErrSignerMismatch = errors.New("attestation signer mismatch")
+
+// maxUint256 bounds the amounts a uint256 can encode
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// domainTypehash is the EIP-712 typehash of the attestation domain
+var domainTypehash = crypto.Keccak256Hash([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+
+// settlementTypehash is the EIP-712 typehash of the SettlementAttestation message
+var settlementTypehash = crypto.Keccak256Hash([]byte("SettlementAttestation(string network,bytes32 txHash,address payer,address payTo,address asset,uint256 amount,bytes32 nonce)"))
+
+// Settlement is the settled payment covered by an attestation
+type Settlement struct {
+	Network string `json:"network"`
+	// ChainID is the chain of the network, used in the EIP-712 domain
+	ChainID int64  `json:"chainId"`
+	TxHash  string `json:"txHash"`
+	Payer   string `json:"payer"`
+	PayTo   string `json:"payTo"`
+	Asset   string `json:"asset"`
+	// Amount is the settled value in the asset's atomic units
+	Amount string `json:"amount"`
+	Nonce  string `json:"nonce"`
+}
+
+// Attestation is a settlement signed by the facilitator's attestation key
+type Attestation struct {
+	Settlement
+	Signer    string `json:"signer"`
+	Signature string `json:"signature"`
+}
+
+// Hash computes the EIP-712 hash of a settlement
+// The domain has no verifying contract, so the zero address is used
+func Hash(settlement Settlement) ([]byte, error) {
+	amount, ok := new(big.Int).SetString(settlement.Amount, 10)
+	if !ok || amount.Sign() < 0 || amount.Cmp(maxUint256) > 0 {
+		return nil, fmt.Errorf("invalid amount: %s", settlement.Amount)
+	}
+	if settlement.ChainID < 0 {
+		return nil, fmt.Errorf("invalid chain ID: %d", settlement.ChainID)
+	}
+
+	// The package is imported by resource servers and auditors, so the domain is hashed here rather than with the
+	// facilitator's internal EIP-712 helpers
+	domainSeparator := crypto.Keccak256(
+		domainTypehash.Bytes(),
+		crypto.Keccak256([]byte(DomainName)),
+		crypto.Keccak256([]byte(DomainVersion)),
+		common.LeftPadBytes(big.NewInt(settlement.ChainID).Bytes(), 32),
+		common.LeftPadBytes(common.Address{}.Bytes(), 32),
+	)
+	messageHash := crypto.Keccak256(
+		settlementTypehash.Bytes(),
+		crypto.Keccak256([]byte(settlement.Network)),
+		common.HexToHash(settlement.TxHash).Bytes(),
+		common.LeftPadBytes(common.HexToAddress(settlement.Payer).Bytes(), 32),
+		common.LeftPadBytes(common.HexToAddress(settlement.PayTo).Bytes(), 32),
+		common.LeftPadBytes(common.HexToAddress(settlement.Asset).Bytes(), 32),
+		common.LeftPadBytes(amount.Bytes(), 32),
+		common.HexToHash(settlement.Nonce).Bytes(),
+	)
+
+	return crypto.Keccak256([]byte("\x19\x01"), domainSeparator, messageHash), nil
+}
+
+// Signer signs settlement attestations
+type Signer struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// NewSigner creates a Signer from a hex encoded private key
+func NewSigner(privateKey string) (*Signer, error) {
+	key, err := crypto.HexToECDSA(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation private key: %w", err)
+	}
+
+	return &Signer{
+		key:     key,
+		address: crypto.PubkeyToAddress(key.PublicKey),
+	}, nil
+}
+
+// Address returns the address that attestations are signed by
+func (s *Signer) Address() common.Address {
+	return s.address
+}
+
+// Sign signs a settlement
+func (s *Signer) Sign(settlement Settlement) (*Attestation, error) {
+	hash, err := Hash(settlement)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := crypto.Sign(hash, s.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign attestation: %w", err)
+	}
+	// Use v = 27/28 like other Ethereum typed data signatures
+	signature[64] += 27
+
+	return &Attestation{
+		Settlement: settlement,
+		Signer:     s.address.Hex(),
+		Signature:  hexutil.Encode(signature),
+	}, nil
+}
+
+// Verify checks that the attestation's signature over its settlement was made by expectedSigner
+func Verify(attestation *Attestation, expectedSigner common.Address) error {
+	hash, err := Hash(attestation.Settlement)
+	if err != nil {
+		return err
+	}
+
+	signature := common.FromHex(attestation.Signature)
+	if len(signature) != crypto.SignatureLength {
+		return fmt.Errorf("invalid signature length: %d", len(signature))
+	}
+
+	// Signatures use v = 27/28, recovery expects 0/1
+	if signature[64] >= 27 {
+		signature[64] -= 27
+	}
+	publicKey, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return fmt.Errorf("failed to recover attestation signer: %w", err)
+	}
+	if signer := crypto.PubkeyToAddress(*publicKey); signer != expectedSigner {
+		return fmt.Errorf("%w: expected %s, got %s", ErrSignerMismatch, expectedSigner.Hex(), signer.Hex())
+	}
+	return nil
+}
@@ -13,6 +13,32 @@ const (
 	ErrorUnexpectedVerify X402Error = "UNEXPECTED_VERIFY_ERROR"
 	// Unexpected error occurred during payment settlement
 	ErrorUnexpectedSettle X402Error = "UNEXPECTED_SETTLE_ERROR"
+	// Idempotency-Key was already used to settle a different authorization
+	ErrorIdempotencyKeyReused X402Error = "IDEMPOTENCY_KEY_REUSED"
+	// Authorization was already settled, or is being settled, for different payment requirements
+	ErrorSettlementRequirementsMismatch X402Error = "SETTLEMENT_REQUIREMENTS_MISMATCH"
+	// Payment authorization was already used or canceled on-chain
+	ErrorAuthorizationUsed X402Error = "AUTHORIZATION_USED"
+	// Payer or recipient is blacklisted by the asset
+	ErrorAccountBlacklisted X402Error = "ACCOUNT_BLACKLISTED"
+	// Payer or payTo is listed by a compliance screening provider
+	ErrorComplianceBlocked X402Error = "COMPLIANCE_BLOCKED"
+	// Compliance screening provider could not answer, the payment was not judged and the request can be retried later
+	ErrorComplianceUnavailable X402Error = "COMPLIANCE_UNAVAILABLE"
+	// Asset transfers are paused
+	ErrorAssetPaused X402Error = "ASSET_PAUSED"
+	// Recipient cannot receive the asset, such as the zero address
+	ErrorInvalidRecipient X402Error = "INVALID_RECIPIENT"
+	// Settlement transaction succeeded but the asset did not emit the expected AuthorizationUsed and Transfer events
+	ErrorSettlementEventMismatch X402Error = "SETTLEMENT_EVENT_MISMATCH"
+	// Settlement transaction was dropped by the network before being mined, the authorization is still unused
+	ErrorSettlementDropped X402Error = "SETTLEMENT_DROPPED"
+	// Network's RPC is currently unreachable, the request can be retried later
+	ErrorNetworkUnavailable X402Error = "NETWORK_UNAVAILABLE"
+	// Network's RPC failed or timed out on every attempt, the payment was not judged and the request can be retried later
+	ErrorRPCFailure X402Error = "RPC_FAILURE"
+	// Facilitator's settlement account lacks the native balance to pay for gas on the network
+	ErrorFacilitatorInsufficientGas X402Error = "FACILITATOR_INSUFFICIENT_GAS"
 
 	// Verify errors
 	// ErrorUnknown represents an unknown error
@@ -37,9 +63,25 @@ const (
 	ErrorInvalidExactEVMPayloadAuthorizationValidBefore X402Error = "INVALID_EXACT_EVM_PAYLOAD_AUTHORIZATION_VALID_BEFORE"
 	// ErrorInsufficientFunds represents an insufficient funds error
 	ErrorInsufficientFunds X402Error = "INSUFFICIENT_FUNDS"
+	// Authorization is held by the verification of another payTo or resource
+	ErrorAuthorizationReserved X402Error = "AUTHORIZATION_RESERVED"
 )
 
+// facilitatorErrors are failures on the facilitator's side, such as its RPC being down, that say nothing about the payment
+var facilitatorErrors = map[X402Error]bool{
+	ErrorNetworkUnavailable:         true,
+	ErrorRPCFailure:                 true,
+	ErrorFacilitatorInsufficientGas: true,
+	ErrorComplianceUnavailable:      true,
+}
+
 // Code returns the error code string
 func (e X402Error) Code() string {
 	return string(e)
 }
+
+// FacilitatorSide reports whether the error is a facilitator-side failure rather than a problem with the payment
+// Clients should retry the same payment later instead of rejecting it
+func (e X402Error) FacilitatorSide() bool {
+	return facilitatorErrors[e]
+}
@@ -0,0 +1,71 @@
+package errors
+
+import "strings"
+
+// revertReasons maps the exact lowercase revert reasons of well-known tokens, and the names of well-known custom
+// errors, to error codes
+var revertReasons = map[string]X402Error{
+	// FiatToken (USDC) and other EIP-3009 implementations
+	"fiattokenv2: authorization is used or canceled": ErrorAuthorizationUsed,
+	"fiattokenv2: authorization is used":             ErrorAuthorizationUsed,
+	"eip3009: authorization is used or canceled":     ErrorAuthorizationUsed,
+	"eip3009: authorization is used":                 ErrorAuthorizationUsed,
+	"fiattokenv2: authorization is not yet valid":    ErrorInvalidExactEVMPayloadAuthorizationValidAfter,
+	"eip3009: authorization is not yet valid":        ErrorInvalidExactEVMPayloadAuthorizationValidAfter,
+	"fiattokenv2: authorization is expired":          ErrorInvalidExactEVMPayloadAuthorizationValidBefore,
+	"eip3009: authorization is expired":              ErrorInvalidExactEVMPayloadAuthorizationValidBefore,
+	"fiattokenv2: invalid signature":                 ErrorInvalidExactEVMPayloadSignature,
+	"eip3009: invalid signature":                     ErrorInvalidExactEVMPayloadSignature,
+	"ecrecover: invalid signature":                   ErrorInvalidExactEVMPayloadSignature,
+	"ecrecover: invalid signature length":            ErrorInvalidExactEVMPayloadSignature,
+	"ecrecover: invalid signature 's' value":         ErrorInvalidExactEVMPayloadSignature,
+	"ecrecover: invalid signature 'v' value":         ErrorInvalidExactEVMPayloadSignature,
+	"blacklistable: account is blacklisted":          ErrorAccountBlacklisted,
+	"pausable: paused":                               ErrorAssetPaused,
+	"erc20: transfer amount exceeds balance":         ErrorInsufficientFunds,
+	"fiattoken: transfer amount exceeds balance":     ErrorInsufficientFunds,
+	"erc20: transfer to the zero address":            ErrorInvalidRecipient,
+	"fiattoken: transfer to the zero address":        ErrorInvalidRecipient,
+	// Custom errors, matched by name
+	"authorizationalreadyused": ErrorAuthorizationUsed,
+	"authorizationnotyetvalid": ErrorInvalidExactEVMPayloadAuthorizationValidAfter,
+	"authorizationexpired":     ErrorInvalidExactEVMPayloadAuthorizationValidBefore,
+	"invalidsignature":         ErrorInvalidExactEVMPayloadSignature,
+	"blacklisted":              ErrorAccountBlacklisted,
+	"enforcedpause":            ErrorAssetPaused,
+	"erc20insufficientbalance": ErrorInsufficientFunds,
+	"erc20invalidreceiver":     ErrorInvalidRecipient,
+}
+
+// retryableErrors are failures that may succeed when the same payment is settled again later
+var retryableErrors = map[X402Error]bool{
+	ErrorUnexpectedSettle:                              true,
+	ErrorFacilitatorInsufficientGas:                    true,
+	ErrorNetworkUnavailable:                            true,
+	ErrorRPCFailure:                                    true,
+	ErrorComplianceUnavailable:                         true,
+	ErrorAssetPaused:                                   true,
+	ErrorInsufficientFunds:                             true,
+	ErrorInvalidExactEVMPayloadAuthorizationValidAfter: true,
+	ErrorSettlementDropped:                             true,
+}
+
+// FromRevertReason maps a decoded revert reason of a token call to an error code
+// Reasons are matched exactly, custom errors such as "Blacklisted(address)" by their name
+// Unrecognized reasons map to ErrorInvalidTransactionState
+func FromRevertReason(reason string) X402Error {
+	reason = strings.ToLower(strings.TrimSpace(reason))
+	if name, _, ok := strings.Cut(reason, "("); ok && !strings.Contains(name, " ") {
+		reason = name
+	}
+	if code, ok := revertReasons[reason]; ok {
+		return code
+	}
+	return ErrorInvalidTransactionState
+}
+
+// Retryable reports whether settling the same payment again later may succeed
+// Permanent failures need a new payment authorization
+func (e X402Error) Retryable() bool {
+	return retryableErrors[e]
+}